@@ -2,6 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,29 +13,45 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
-	_ "subscription_service/docs"
+	"subscription_service/docs"
 
 	"subscription_service/internal/config"
+	"subscription_service/internal/events"
 	"subscription_service/internal/handler"
+	"subscription_service/internal/logging"
+	"subscription_service/internal/middleware"
+	"subscription_service/internal/notifier"
 	"subscription_service/internal/repository"
 	"subscription_service/internal/service"
+	"subscription_service/internal/store"
+	"subscription_service/internal/version"
+	"subscription_service/internal/worker"
 )
 
 func main() {
+	startedAt := time.Now()
+
 	cfg, err := config.Load()
 	if err != nil {
 		logrus.Fatalf("Failed to load config: %v", err)
 	}
 
 	setupLogging(cfg.LogLevel)
+	logging.InstallPIIHook(cfg.LogPIIMode)
 
-	logrus.Info("Database is ready, skipping migrations")
+	if cfg.SkipMigrations {
+		logrus.Info("SKIP_MIGRATIONS is set, skipping migrations")
+	} else if err := runMigrations(cfg); err != nil {
+		logrus.Fatalf("Failed to run migrations: %v", err)
+	}
 
 	db, err := repository.NewPostgresConnection(cfg.GetPostgresDSN())
 	if err != nil {
@@ -39,20 +59,138 @@ func main() {
 	}
 	defer db.Close()
 
-	subRepo := repository.NewSubscriptionRepository(db)
-	subService := service.NewSubscriptionService(subRepo)
-	subHandler := handler.NewSubscriptionHandler(subService)
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		logrus.Fatalf("Invalid TIMEZONE %q: %v", cfg.Timezone, err)
+	}
+
+	flags := cfg.Flags()
 
-	router := setupRouter(subHandler)
+	subRepo := repository.NewSubscriptionRepository(db, flags.Outbox, cfg.DBStatementTimeout)
+	rateRepo := repository.NewExchangeRateRepository(db)
+	converter := service.NewCurrencyConverter(rateRepo, cfg.BaseCurrency, cfg.RateCacheTTL, cfg.PriceMinorUnits)
+	summaryRepo := repository.NewSummaryRepository(db)
 
-	srv := &http.Server{
-		Addr:    ":" + cfg.ServerPort,
-		Handler: router,
+	// Сводка поддерживается триггером всегда; агрегации читают её только при флаге.
+	var summaries repository.SummaryRepository
+	if flags.Summaries {
+		summaries = summaryRepo
+	}
+
+	// Общее для реплик состояние лимита запросов и кэша агрегаций; без
+	// REDIS_URL оба живут в памяти процесса.
+	var sharedStore store.Store
+	if cfg.RedisURL != "" {
+		redisStore, err := store.NewRedis(cfg.RedisURL)
+		if err != nil {
+			logrus.Fatalf("Failed to configure Redis: %v", err)
+		}
+		defer redisStore.Close()
+
+		// Недоступность при старте не мешает запуску: до ответа Redis
+		// используется локальное состояние.
+		if err := redisStore.Ping(context.Background()); err == nil {
+			logrus.Info("Using Redis for rate limits and aggregate cache")
+		}
+		sharedStore = redisStore
+	}
+
+	// Кэш агрегаций общий для сервиса, фоновых задач и административных
+	// пересчётов: все они меняют данные, из которых считаются итоги.
+	aggregateCache := service.NewAggregateCache(cfg.AggregateCacheTTL, sharedStore)
+
+	subService := service.NewSubscriptionService(subRepo, converter, service.Options{
+		MaxBatchUsers:     cfg.MaxBatchUsers,
+		MaxAggregateYears: cfg.MaxAggregateYears,
+		MonthPrecision:    cfg.DatePrecision == "month",
+		DefaultSort:       cfg.ListDefaultSort,
+		Location:          location,
+
+		MaxFutureStartMonths: cfg.StartMaxFutureMonths,
+		MaxPastStartYears:    cfg.StartMaxPastYears,
+		MaxActivePerUser:     cfg.MaxActiveSubscriptionsPerUser,
+		Warnings: service.WarningRules{
+			PriceAbove:           cfg.WarnPriceAbove,
+			StartOlderThanMonths: cfg.WarnStartOlderThanMonths,
+		},
+		MaxExportRows: cfg.ExportMaxRows,
+		MaxListIDs:    cfg.ListMaxIDs,
+
+		AggregateCache: aggregateCache,
+		UniquePolicy:   cfg.UniquePolicy,
+
+		EndDateRequiredServices: cfg.EndDateRequiredServices,
+		SkipNoopUpdates:         cfg.SkipNoopUpdates,
+		MaxAggregateGroups:      cfg.AggregateMaxGroups,
+		Retry: service.RetryPolicy{
+			Attempts: cfg.DBRetryAttempts,
+			Backoff:  cfg.DBRetryBackoff,
+		},
+
+		MinorUnits: cfg.PriceMinorUnits,
+		Summaries:  summaries,
+		SortFields: cfg.ListSortFields,
+	})
+	subHandler := handler.NewSubscriptionHandler(subService, handler.Options{
+		UnprocessableValidation: cfg.UnprocessableValidation,
+		PricesAsStrings:         cfg.PricesAsStrings,
+		AdminAPIKey:             cfg.AdminAPIKey,
+		LocalizeErrors:          cfg.LocalizeErrors,
+		PriceMinorUnits:         cfg.PriceMinorUnits,
+	})
+
+	maintenanceRepo := repository.NewMaintenanceRepository(db)
+	maintenanceService := service.NewMaintenanceService(maintenanceRepo)
+	recomputeService := service.NewRecomputeService(repository.NewRecomputeRepository(db), cfg.BaseCurrency, aggregateCache)
+	summaryService := service.NewSummaryService(summaryRepo, aggregateCache)
+	adminHandler := handler.NewAdminHandler(maintenanceService, recomputeService, summaryService, cfg.PricesAsStrings)
+
+	healthHandler := handler.NewHealthHandler(db, cfg.ReadyPingTimeout, cfg.ReadyDegradedLatency)
+	diagnosticsHandler := handler.NewDiagnosticsHandler(db, startedAt)
+
+	router := setupRouter(cfg, subHandler, adminHandler, healthHandler, diagnosticsHandler, sharedStore)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	if flags.Prune {
+		pruner := worker.NewPruner(repository.NewPruneRepository(db, cfg.OutboxEnabled), cfg.PruneAfterMonths, cfg.PruneMode != "delete", cfg.PruneBatchSize, aggregateCache)
+		go worker.RunPeriodic(workerCtx, "prune", cfg.PruneInterval, pruner.Run)
+	}
+
+	if flags.Outbox {
+		relay := worker.NewOutboxRelay(repository.NewOutboxRepository(db), events.LogPublisher{}, cfg.OutboxBatchSize, cfg.OutboxRetention)
+		go worker.RunPeriodic(workerCtx, "outbox-relay", cfg.OutboxRelayInterval, relay.Run)
+	}
+
+	if flags.AutoRenewal {
+		renewer := worker.NewRenewer(repository.NewRenewalRepository(db, cfg.OutboxEnabled), cfg.RenewalBatchSize, location, aggregateCache)
+		go worker.RunPeriodic(workerCtx, "auto-renewal", cfg.RenewalInterval, renewer.Run)
 	}
 
+	if flags.ExpiringReport {
+		smtpNotifier := notifier.NewSMTPNotifier(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.ReportRecipients)
+		reporter := worker.NewExpiringReporter(subService, smtpNotifier, cfg.ReportExpiringMonths)
+		schedule, err := cron.ParseStandard(cfg.ReportSchedule)
+		if err != nil {
+			logrus.Fatalf("Invalid REPORT_SCHEDULE %q: %v", cfg.ReportSchedule, err)
+		}
+		go worker.RunScheduled(workerCtx, "expiring-report", schedule, location, reporter.Run)
+	}
+
+	srv := newServer(cfg, router)
+
 	go func() {
-		logrus.Infof("Server starting on port %s", cfg.ServerPort)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.TLSEnabled() {
+			srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			logrus.Infof("Server starting with TLS on port %s", cfg.ServerPort)
+			err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			logrus.Infof("Server starting on port %s", cfg.ServerPort)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logrus.Fatalf("Failed to start server: %v", err)
 		}
 	}()
@@ -61,6 +199,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logrus.Info("Shutting down server...")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
@@ -72,6 +211,46 @@ func main() {
 	logrus.Info("Server exited")
 }
 
+// newServer собирает HTTP-сервер с настройками протоколов и keep-alive из конфигурации.
+func newServer(cfg *config.Config, handler http.Handler) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(cfg.HTTP2Cleartext)
+
+	srv := &http.Server{
+		Addr:           ":" + cfg.ServerPort,
+		Handler:        handler,
+		Protocols:      protocols,
+		IdleTimeout:    cfg.HTTPIdleTimeout,
+		MaxHeaderBytes: cfg.HTTPMaxHeaderBytes,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: cfg.HTTP2MaxConcurrentStreams,
+		},
+	}
+
+	// Без keep-alive клиент переподключается на каждый запрос, и при
+	// перегрузке балансировщик быстрее уводит его на другие экземпляры.
+	srv.SetKeepAlivesEnabled(cfg.HTTPKeepAlives)
+
+	return srv
+}
+
+func runMigrations(cfg *config.Config) error {
+	m, err := migrate.New(cfg.MigrationsPath, cfg.GetPostgresURL())
+	if err != nil {
+		return fmt.Errorf("failed to initialize migrations from %s: %w", cfg.MigrationsPath, err)
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	logrus.Info("Migrations applied")
+	return nil
+}
+
 func setupLogging(level string) {
 	logrus.SetFormatter(&logrus.JSONFormatter{
 		TimestampFormat: time.RFC3339,
@@ -85,13 +264,24 @@ func setupLogging(level string) {
 	logrus.SetLevel(lvl)
 }
 
-func setupRouter(subHandler *handler.SubscriptionHandler) *gin.Engine {
+func setupRouter(cfg *config.Config, subHandler *handler.SubscriptionHandler, adminHandler *handler.AdminHandler, healthHandler *handler.HealthHandler, diagnosticsHandler *handler.DiagnosticsHandler, sharedStore store.Store) *gin.Engine {
 	router := gin.New()
 
+	// Без списка доверенных прокси gin берёт IP из X-Forwarded-For любого
+	// клиента, и подменённый заголовок обходит лимит запросов.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logrus.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	router.Use(gin.Recovery())
-	router.Use(gin.Logger())
+	router.Use(middleware.RequestID())
+	router.Use(middleware.AccessLog(cfg.AccessLogSampleRate))
+	// Экспорт стримит ответ и может законно длиться дольше REQUEST_TIMEOUT.
+	router.Use(middleware.Timeout(cfg.RequestTimeout, "/api/v1/subscriptions/export"))
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/openapi.json", handler.ServeSpec(docs.SpecJSON, "application/json; charset=utf-8", "openapi.json"))
+	router.GET("/openapi.yaml", handler.ServeSpec(docs.SpecYAML, "application/yaml; charset=utf-8", "openapi.yaml"))
 
 	v1 := router.Group("/api/v1")
 	{
@@ -99,16 +289,70 @@ func setupRouter(subHandler *handler.SubscriptionHandler) *gin.Engine {
 		{
 			subscriptions.POST("/", subHandler.CreateSubscription)
 			subscriptions.GET("/", subHandler.ListSubscriptions)
+			subscriptions.POST("/query", subHandler.QuerySubscriptions)
+			subscriptions.GET("/export", subHandler.ExportSubscriptions)
+			subscriptions.GET("/expiring", subHandler.ListExpiringSubscriptions)
+			subscriptions.GET("/top", subHandler.TopSubscriptions)
+			subscriptions.GET("/changes", subHandler.ListSubscriptionChanges)
+			subscriptions.GET("/schema", subHandler.GetSubscriptionSchema)
+			subscriptions.GET("/timeline", subHandler.SubscriptionsTimeline)
 			subscriptions.GET("/aggregate", subHandler.AggregateSubscriptions)
+			subscriptions.POST("/aggregate/batch", subHandler.AggregateSubscriptionsBatch)
+			subscriptions.GET("/aggregate/compare", subHandler.CompareAggregateSubscriptions)
+			subscriptions.POST("/simulate-price-change", subHandler.SimulatePriceChange)
 			subscriptions.GET("/:id", subHandler.GetSubscription)
 			subscriptions.PUT("/:id", subHandler.UpdateSubscription)
+			subscriptions.POST("/:id/shift", subHandler.ShiftSubscription)
+			subscriptions.POST("/:id/transfer", subHandler.TransferSubscription)
 			subscriptions.DELETE("/:id", subHandler.DeleteSubscription)
 		}
+
+		v1.GET("/users/:user_id/subscriptions", subHandler.ListUserSubscriptions)
+		v1.GET("/users/:user_id/deletion-preview", subHandler.PreviewUserDeletion)
+
+		if cfg.AdminAPIKey != "" {
+			admin := v1.Group("/admin")
+			// Лимит стоит до проверки ключа, чтобы ограничивать и перебор ключей.
+			admin.Use(middleware.RateLimit("admin", cfg.AdminRateLimit, cfg.AdminRateWindow, sharedStore))
+			admin.Use(middleware.APIKeyAuth(cfg.AdminAPIKey))
+			{
+				admin.POST("/maintenance", adminHandler.RunMaintenance)
+				admin.POST("/recompute", adminHandler.RecomputeDerived)
+				admin.POST("/rebuild-summaries", adminHandler.RebuildSummaries)
+				admin.GET("/vars", gin.WrapH(expvar.Handler()))
+				admin.GET("/diagnostics", diagnosticsHandler.Diagnostics)
+				admin.GET("/features", handler.ServeFeatures(cfg.Flags()))
+			}
+		} else {
+			logrus.Warn("ADMIN_API_KEY is not set, admin endpoints are disabled")
+		}
 	}
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
+	router.GET("/health/ready", healthHandler.Ready)
+
+	router.GET("/version", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"version":    version.Version,
+			"git_commit": version.GitCommit,
+			"build_time": version.BuildTime,
+		})
+	})
+
+	router.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"service": "subscription-service",
+			"version": version.Version,
+			"links": gin.H{
+				"api":     "/api/v1/subscriptions",
+				"docs":    "/swagger/index.html",
+				"health":  "/health",
+				"version": "/version",
+			},
+		})
+	})
 
 	return router
 }
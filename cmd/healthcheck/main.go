@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"subscription_service/internal/config"
+)
+
+// healthcheck проверяет, что БД доступна и миграции применены до последней,
+// и завершается с кодом 0 или 1, не поднимая HTTP-сервер. Подходит для
+// init-контейнера Kubernetes и smoke-проверок в CI.
+func main() {
+	timeout := flag.Duration("timeout", 5*time.Second, "database ping timeout")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		logrus.Fatalf("Failed to load config: %v", err)
+	}
+
+	if err := run(cfg, *timeout); err != nil {
+		logrus.WithError(err).Error("Healthcheck failed")
+		os.Exit(1)
+	}
+
+	logrus.Info("Healthcheck passed")
+}
+
+func run(cfg *config.Config, timeout time.Duration) error {
+	db, err := sql.Open("postgres", cfg.GetPostgresDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database connection: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	started := time.Now()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database is unreachable: %w", err)
+	}
+	logrus.WithField("latency_ms", time.Since(started).Milliseconds()).Info("Database is reachable")
+
+	m, err := migrate.New(cfg.MigrationsPath, cfg.GetPostgresURL())
+	if err != nil {
+		return fmt.Errorf("failed to initialize migrations from %s: %w", cfg.MigrationsPath, err)
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("failed to read migration version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty, fix it manually and force the version", version)
+	}
+
+	fields := logrus.Fields{"version": version}
+
+	latest, ok, err := latestMigration(cfg.MigrationsPath)
+	if err != nil {
+		return err
+	}
+	if ok {
+		fields["latest"] = latest
+		if version < latest {
+			return fmt.Errorf("migrations are behind: database at %d, latest is %d", version, latest)
+		}
+	}
+
+	logrus.WithFields(fields).Info("Migrations are current")
+	return nil
+}
+
+// latestMigration возвращает номер последней миграции в каталоге file://-источника.
+// Для других источников ok=false: сравнивать не с чем.
+func latestMigration(sourceURL string) (uint, bool, error) {
+	dir, isFile := strings.CutPrefix(sourceURL, "file://")
+	if !isFile {
+		return 0, false, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to list migrations in %s: %w", dir, err)
+	}
+
+	var latest uint
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(file), "_")
+		n, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		if uint(n) > latest {
+			latest = uint(n)
+		}
+	}
+
+	return latest, len(files) > 0, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"subscription_service/internal/config"
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+)
+
+var services = []struct {
+	name  string
+	price int
+}{
+	{"Yandex Plus", 400},
+	{"Netflix", 999},
+	{"Spotify", 299},
+	{"Kinopoisk", 349},
+	{"Okko", 399},
+	{"VK Music", 199},
+	{"IVI", 399},
+	{"Wink", 249},
+}
+
+var tags = []string{"music", "video", "family", "work", "trial"}
+
+// seed заполняет базу демонстрационными подписками для локальной разработки.
+// При одинаковом -seed генерируются одни и те же данные, включая id и user_id.
+func main() {
+	count := flag.Int("count", 100, "number of subscriptions to insert")
+	users := flag.Int("users", 10, "number of distinct users")
+	seed := flag.Int64("seed", 1, "random seed")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		logrus.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.IsProduction() {
+		logrus.Fatal("Refusing to seed demo data when APP_ENV=production")
+	}
+
+	if *count < 1 || *users < 1 {
+		logrus.Fatal("Both -count and -users must be positive")
+	}
+
+	db, err := repository.NewPostgresConnection(cfg.GetPostgresDSN())
+	if err != nil {
+		logrus.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewSubscriptionRepository(db, false, 0)
+	rng := rand.New(rand.NewSource(*seed))
+
+	userIDs := make([]uuid.UUID, *users)
+	for i := range userIDs {
+		userIDs[i] = newUUID(rng)
+	}
+
+	// Даты отсчитываются от фиксированного месяца, а не от time.Now(),
+	// чтобы результат не зависел от дня запуска.
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Now().UTC()
+
+	for i := 0; i < *count; i++ {
+		svc := services[rng.Intn(len(services))]
+		start := base.AddDate(0, rng.Intn(24), 0)
+
+		sub := &model.Subscription{
+			ID:          newUUID(rng),
+			ServiceName: svc.name,
+			Price:       model.Money(svc.price),
+			Currency:    cfg.BaseCurrency,
+			Tags:        randomTags(rng),
+			UserID:      userIDs[rng.Intn(len(userIDs))],
+			StartDate:   start,
+			CreatedAt:   createdAt,
+			UpdatedAt:   createdAt,
+		}
+
+		// Примерно треть подписок бессрочные.
+		if rng.Intn(3) > 0 {
+			end := start.AddDate(0, 1+rng.Intn(12), -1)
+			sub.EndDate = &end
+		}
+
+		if err := repo.Create(context.Background(), sub, repository.UserLimit{}); err != nil {
+			logrus.Fatalf("Failed to insert subscription %d: %v", i+1, err)
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"count": *count,
+		"users": *users,
+		"seed":  *seed,
+	}).Info("Demo data seeded")
+}
+
+func newUUID(rng *rand.Rand) uuid.UUID {
+	id, err := uuid.NewRandomFromReader(rng)
+	if err != nil {
+		logrus.Fatalf("Failed to generate uuid: %v", err)
+	}
+	return id
+}
+
+func randomTags(rng *rand.Rand) []string {
+	result := []string{}
+	for _, tag := range tags {
+		if rng.Intn(4) == 0 {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
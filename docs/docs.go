@@ -15,115 +15,74 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/subscriptions": {
+        "/api/v1/admin/diagnostics": {
             "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
-                ],
-                "summary": "Список подписок с фильтрацией",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Фильтр по ID пользователя",
-                        "name": "user_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Фильтр по названию сервиса",
-                        "name": "service_name",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Фильтр по дате начала (подписки, начавшиеся не раньше)",
-                        "name": "start_date",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Фильтр по дате начала (подписки, начавшиеся не позже)",
-                        "name": "end_date",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Лимит записей (по умолчанию 10)",
-                        "name": "limit",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Смещение (по умолчанию 0)",
-                        "name": "offset",
-                        "in": "query"
-                    }
+                    "admin"
                 ],
+                "summary": "Диагностика процесса: пул соединений с БД, рантайм Go, аптайм",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/handler.diagnosticsResponse"
                         }
                     },
-                    "400": {
-                        "description": "Неверные параметры запроса",
+                    "401": {
+                        "description": "Неверный API-ключ",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "500": {
-                        "description": "Внутренняя ошибка сервера",
+                    "429": {
+                        "description": "Превышен лимит запросов",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     }
                 }
-            },
-            "post": {
-                "consumes": [
-                    "application/json"
+            }
+        },
+        "/api/v1/admin/features": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
-                ],
-                "summary": "Создать новую подписку",
-                "parameters": [
-                    {
-                        "description": "Данные подписки",
-                        "name": "subscription",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.CreateSubscriptionRequest"
-                        }
-                    }
+                    "admin"
                 ],
+                "summary": "Состояние опциональных возможностей сервиса",
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "$ref": "#/definitions/config.Flags"
                         }
                     },
-                    "400": {
-                        "description": "Неверный формат запроса",
+                    "401": {
+                        "description": "Неверный API-ключ",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "500": {
-                        "description": "Внутренняя ошибка сервера",
+                    "429": {
+                        "description": "Превышен лимит запросов",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -132,48 +91,33 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/subscriptions/aggregate": {
-            "get": {
+        "/api/v1/admin/maintenance": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Подсчет суммарной стоимости подписок за период",
+                "summary": "Запустить обслуживание таблицы подписок (ANALYZE, опционально REINDEX)",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Фильтр по ID пользователя",
-                        "name": "user_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Фильтр по названию сервиса",
-                        "name": "service_name",
+                        "type": "boolean",
+                        "description": "Дополнительно выполнить REINDEX",
+                        "name": "reindex",
                         "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Начало периода (YYYY-MM-DD)",
-                        "name": "start_date",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Конец периода (YYYY-MM-DD)",
-                        "name": "end_date",
-                        "in": "query",
-                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.AggregateResponse"
+                            "$ref": "#/definitions/model.MaintenanceResult"
                         }
                     },
                     "400": {
@@ -183,6 +127,20 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "401": {
+                        "description": "Неверный API-ключ",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "429": {
+                        "description": "Превышен лимит запросов",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
@@ -193,40 +151,37 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/subscriptions/{id}": {
-            "get": {
+        "/api/v1/admin/rebuild-summaries": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Сводка subscription_summaries поддерживается триггером при каждой записи; пересборка нужна после изменений в обход триггера. На время пересборки запись в подписки блокируется.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
-                ],
-                "summary": "Получить подписку по ID",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "UUID подписки",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "admin"
                 ],
+                "summary": "Пересобрать помесячную сводку агрегаций",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/model.Subscription"
+                            "$ref": "#/definitions/model.SummaryRebuildResult"
                         }
                     },
-                    "400": {
-                        "description": "Неверный формат ID",
+                    "401": {
+                        "description": "Неверный API-ключ",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "404": {
-                        "description": "Подписка не найдена",
+                    "429": {
+                        "description": "Превышен лимит запросов",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -240,53 +195,60 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "consumes": [
-                    "application/json"
+            }
+        },
+        "/api/v1/admin/recompute": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Приводит tags, currency и description сохранённых подписок к виду, который пишет API (строки, записанные в обход сервиса или до нормализации). Сводку subscription_summaries пересобирает POST /api/v1/admin/rebuild-summaries. Идемпотентно: повторный запуск не меняет актуальные строки. Если completed=false (например, истёк таймаут запроса), продолжите с after_id=last_id.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Обновить подписку",
+                "summary": "Пересчитать производные колонки подписок батчами",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "UUID подписки",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Продолжить после этого id",
+                        "name": "after_id",
+                        "in": "query"
                     },
                     {
-                        "description": "Данные для обновления",
-                        "name": "subscription",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.UpdateSubscriptionRequest"
-                        }
+                        "type": "integer",
+                        "description": "Размер батча (по умолчанию 1000, максимум 10000)",
+                        "name": "batch_size",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.RecomputeResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "400": {
-                        "description": "Неверный формат запроса",
+                    "401": {
+                        "description": "Неверный API-ключ",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
                         }
                     },
-                    "404": {
-                        "description": "Подписка не найдена",
+                    "429": {
+                        "description": "Превышен лимит запросов",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -300,93 +262,2311 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/api/v1/subscriptions": {
+            "get": {
+                "description": "Взаимоисключающие параметры (400 с названием конфликта): period и created_from, period и created_to, view=ids и group, view=ids и format=display, view=ids и facets. Фильтр по metadata - параметры meta.\u003cключ\u003e=\u003cзначение\u003e, например meta.plan_code=premium: подписки, у которых metadata содержит это строковое значение.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Удалить подписку",
+                "summary": "Список подписок с фильтрацией",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "UUID подписки",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
                     },
-                    "400": {
-                        "description": "Неверный формат ID",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Только подписки с указанными id; повтором параметра или через запятую",
+                        "name": "ids",
+                        "in": "query"
                     },
-                    "404": {
-                        "description": "Подписка не найдена",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
+                    {
+                        "type": "string",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
                     },
-                    "500": {
-                        "description": "Внутренняя ошибка сервера",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    }
-                }
-            }
-        }
-    },
-    "definitions": {
-        "model.AggregateResponse": {
-            "type": "object",
-            "properties": {
-                "total_price": {
-                    "type": "integer"
-                }
-            }
-        },
-        "model.CreateSubscriptionRequest": {
-            "type": "object",
-            "required": [
-                "price",
-                "service_name",
-                "start_date",
-                "user_id"
-            ],
-            "properties": {
-                "end_date": {
-                    "type": "string"
-                },
-                "price": {
-                    "type": "integer",
-                    "minimum": 0
-                },
-                "service_name": {
-                    "type": "string"
-                },
-                "start_date": {
-                    "type": "string"
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)",
+                        "name": "exact",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по дате начала (подписки, начавшиеся не раньше). При DATE_PRECISION=month сравнивается с точностью до месяца",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по дате начала (подписки, начавшиеся не позже). При DATE_PRECISION=month сравнивается с точностью до месяца",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по тегу",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Созданы не раньше (RFC3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Созданы раньше (RFC3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true - только бессрочные (без end_date), false - только с end_date",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подписки, активные на дату (YYYY-MM-DD)",
+                        "name": "active_on",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пресет по дате создания: today, week, month (в часовом поясе TIMEZONE)",
+                        "name": "period",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Лимит записей (по умолчанию 10, максимум 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение (по умолчанию 0)",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Сортировка: поле, с '-' для убывания (например -start_date); по умолчанию LIST_DEFAULT_SORT",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Изменены строго позже (RFC3339)",
+                        "name": "updated_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "full (по умолчанию) или ids - data содержит только [{id, updated_at}]",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "display - добавить price_display, отформатированную по валюте и Accept-Language (несовместимо с view=ids)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "service_name, status - вернуть facets {поле: {значение: количество}} по всему фильтру без пагинации; status - active, upcoming или expired на сегодня",
+                        "name": "facets",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Локаль для price_display (en, ru, de, fr)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "service_name - вернуть groups [{service_name, total, subscriptions}] вместо data; группируется текущая страница",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимум подписок в группе (по умолчанию 10, максимум 100)",
+                        "name": "group_limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "data, limit, offset, total (по всем страницам), total_pages; period, facets и warnings (некритичные проблемы запроса) - при наличии",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Создать новую подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Данные подписки",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CreateSubscriptionRequest"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Не создавать дубликат: вернуть существующую подписку с тем же user_id, service_name и start_date",
+                        "name": "if_not_exists",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Создать подписку несмотря на предупреждения (высокая цена, давняя start_date); они вернутся в поле warnings",
+                        "name": "allow_warnings",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Игнорировать лимит активных подписок пользователя (только с X-API-Key администратора)",
+                        "name": "override_limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin API key, нужен для override_limit",
+                        "name": "X-API-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "return=minimal - ответить без тела, только с заголовком Location (RFC 7240)",
+                        "name": "Prefer",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Подписка уже существует (if_not_exists=true)",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "override_limit без ключа администратора",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Достигнут лимит активных подписок пользователя, подписка с таким id уже есть или нарушена политика UNIQUE_POLICY",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true) или предупреждения без allow_warnings",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "БД временно только для чтения или конкурентные изменения не разрешились повторами; повторите через Retry-After секунд",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/aggregate": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Подсчет суммарной стоимости подписок за период",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)",
+                        "name": "exact",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало периода (YYYY-MM-DD)",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода (YYYY-MM-DD)",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Валюта результата (ISO 4217), суммы пересчитываются по курсам",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Разбивка результата: month или service_name,user_id (не больше AGGREGATE_MAX_GROUPS групп)",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "При group_by=month добавить общую сумму",
+                        "name": "include_total",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "month",
+                            "quarter",
+                            "year"
+                        ],
+                        "type": "string",
+                        "description": "Шаг временного ряда buckets: month (по умолчанию), quarter, year; подразумевает group_by=month",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "csv"
+                        ],
+                        "type": "string",
+                        "description": "json (по умолчанию) или csv - при group_by отдать разбивку CSV-файлом",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "CSV с колонками группировки, total_price и currency при format=csv",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/aggregate/batch": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Подсчет суммарной стоимости подписок за период для нескольких пользователей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Список пользователей и период",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.BatchAggregateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.BatchAggregateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/aggregate/compare": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Сравнение суммарной стоимости подписок за два периода",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)",
+                        "name": "exact",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало периода A (YYYY-MM-DD)",
+                        "name": "period_a_start",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода A (YYYY-MM-DD)",
+                        "name": "period_a_end",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало периода B (YYYY-MM-DD)",
+                        "name": "period_b_start",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода B (YYYY-MM-DD)",
+                        "name": "period_b_end",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Валюта результата (ISO 4217), суммы пересчитываются по курсам",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.CompareAggregateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/changes": {
+            "get": {
+                "description": "Подписки с updated_at после since по возрастанию (updated_at, id). Для следующей страницы передайте next_since и next_after_id из ответа; при пустой странице курсор возвращается без изменений. Пагинация курсорная, а не limit/offset, как у остальных списков: offset сбивается, пока клиент догоняет изменения. Удалённые подписки не возвращаются: удаление физическое, его видно только в событиях outbox.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Подписки, изменённые после момента времени (инкрементальная синхронизация)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Момент времени в RFC3339, например 2025-01-01T00:00:00Z",
+                        "name": "since",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_after_id из предыдущего ответа",
+                        "name": "after_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (по умолчанию 100, максимум 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ChangesPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/expiring": {
+            "get": {
+                "description": "Подписки по возрастанию end_date; from и to - границы окна (YYYY-MM-DD).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Подписки, заканчивающиеся в текущем месяце или в ближайшие N месяцев",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сколько следующих месяцев включить помимо текущего (по умолчанию 0)",
+                        "name": "months",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (по умолчанию 10, максимум 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "data, limit, offset, total (по всем страницам), total_pages, from, to; warnings - при наличии",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/export": {
+            "get": {
+                "description": "Отдаёт все подписки, подходящие под фильтры списка (без пагинации), по одному JSON-объекту на строку.\nВыгрузка ограничена EXPORT_MAX_ROWS строками; если подходящих строк больше, последней строкой идёт {\"warning\": \"...\"}.\nВзаимоисключающие параметры (400 с названием конфликта): period и created_from, period и created_to.\nСтроковые цены (Accept: application/json; prices=string или JSON_PRICES_AS_STRINGS) применяются и к строкам выгрузки.\nЕсли выгрузка оборвалась после первой строки, последней строкой идёт {\"error\": \"...\"}: статус 200 к этому моменту уже отправлен.",
+                "produces": [
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Выгрузка подписок в формате NDJSON",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Формат выгрузки (ndjson)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Только подписки с указанными id; повтором параметра или через запятую",
+                        "name": "ids",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)",
+                        "name": "exact",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по дате начала (подписки, начавшиеся не раньше)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по дате начала (подписки, начавшиеся не позже)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по тегу",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "true - только бессрочные (без end_date), false - только с end_date",
+                        "name": "open_ended",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подписки, активные на дату (YYYY-MM-DD)",
+                        "name": "active_on",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Созданы не раньше (RFC3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Созданы раньше (RFC3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пресет по дате создания: today, week, month (в часовом поясе TIMEZONE)",
+                        "name": "period",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/query": {
+            "post": {
+                "description": "Фильтры те же, что и у GET /api/v1/subscriptions, но передаются в теле. fields - JSON-имена полей подписки, включая вычисляемые (next_billing_date, duration_months, months_remaining, status); пустой fields - все поля.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Список подписок по структурированному запросу с выбором полей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "description": "filter, fields, sort, page",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SubscriptionQuery"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "data (только запрошенные поля), limit, offset, total (по всем страницам), total_pages; warnings - при наличии",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса, неизвестное поле или сортировка",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/schema": {
+            "get": {
+                "description": "Собирается из тех же списков, которыми сервис проверяет запросы (в том числе LIST_SORT_FIELDS), чтобы UI строил фильтры без расхождений с документацией. Ответ кэшируемый: Cache-Control и ETag, на совпавший If-None-Match - 304.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Фильтры, сортировки и перечислимые значения списка подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ETag из предыдущего ответа",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SubscriptionSchema"
+                        }
+                    },
+                    "304": {
+                        "description": "Схема не изменилась"
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/simulate-price-change": {
+            "post": {
+                "description": "Считает итог за период по текущим ценам и по новой цене (new_price) или изменённой на percent_change процентов. Данные не изменяются.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Оценить влияние изменения цены на выручку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Фильтр и гипотетическая цена",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SimulatePriceChangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SimulatePriceChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/timeline": {
+            "get": {
+                "description": "Временной ряд по всем месяцам периода без пагинации: период ограничен AGGREGATE_MAX_YEARS.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Количество активных подписок на конец каждого месяца",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)",
+                        "name": "exact",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало периода (YYYY-MM-DD), учитывается месяц",
+                        "name": "start",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец периода (YYYY-MM-DD), учитывается месяц",
+                        "name": "end",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.TimelinePoint"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/top": {
+            "get": {
+                "description": "Рейтинг, а не постраничный список: limit - число подписок в рейтинге, offset и total не поддерживаются.\nby=duration считает длительность от start_date до end_date, а для бессрочных и ещё идущих подписок - до сегодняшнего дня.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Самые дорогие или самые долгие подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "enum": [
+                            "price",
+                            "duration"
+                        ],
+                        "type": "string",
+                        "description": "Критерий: price (по умолчанию) или duration",
+                        "name": "by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество подписок (по умолчанию 5, максимум 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)",
+                        "name": "exact",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "data - подписки по убыванию критерия",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить подписку по ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Локаль для price_display (en, ru, de, fr)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "display - добавить price_display, отформатированную по валюте и Accept-Language",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат ID или параметра format",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "put": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Обновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag из GET; * - без проверки версии",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Данные для обновления",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UpdateSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "При SKIP_NOOP_UPDATES=true и отсутствии изменений - текущая подписка с заголовком X-Unchanged: true",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "При смене user_id у нового владельца достигнут лимит активных подписок",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась, ETag не совпадает",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан If-Match",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "БД временно только для чтения; повторите через Retry-After секунд",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Возвращает удалённую подписку целиком.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удалить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag из GET; * - без проверки версии",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат ID",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "412": {
+                        "description": "Подписка изменилась, ETag не совпадает",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "428": {
+                        "description": "Не передан If-Match",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "БД временно только для чтения; повторите через Retry-After секунд",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/shift": {
+            "post": {
+                "description": "Сдвигает start_date и end_date (если задана) на одно и то же число месяцев; отрицательное значение сдвигает назад.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Сдвинуть даты подписки на N месяцев",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Количество месяцев",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ShiftSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "БД временно только для чтения или конкурентные изменения не разрешились повторами; повторите через Retry-After секунд",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/transfer": {
+            "post": {
+                "description": "Меняет владельца в одной транзакции с записью в журнал передач (прежний и новый user_id) и, при включённом outbox, событием subscription.transferred. Лимит активных подписок проверяется у получателя.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Передать подписку другому пользователю",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новый владелец",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TransferSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса или подписка уже принадлежит этому пользователю",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Подписка не найдена",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "У получателя достигнут лимит активных подписок",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных (при VALIDATION_422=true)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "БД временно только для чтения или конкурентные изменения не разрешились повторами; повторите через Retry-After секунд",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{user_id}/deletion-preview": {
+            "get": {
+                "description": "Подписки пользователя, которые затронет удаление его данных, и выручка по ним от первой start_date до сегодня в базовой валюте. Только чтение, данные не меняются.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Предпросмотр удаления данных пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.UserDeletionPreview"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат user_id",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{user_id}/subscriptions": {
+            "get": {
+                "description": "Тот же список, что и /api/v1/subscriptions с user_id. При with_total=true добавляются total_monthly_cost и currency: сумма цен всех активных сегодня подписок пользователя (не только текущей страницы) в базовой валюте.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Подписки пользователя, опционально с общей месячной стоимостью",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "application/json; prices=string - отдать price и total_price строками",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Добавить total_monthly_cost",
+                        "name": "with_total",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)",
+                        "name": "exact",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Подписки, активные на дату (YYYY-MM-DD)",
+                        "name": "active_on",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Лимит записей (по умолчанию 10, максимум 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение (по умолчанию 0)",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Сортировка: поле, с '-' для убывания (например -start_date); по умолчанию LIST_DEFAULT_SORT",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "data, limit, offset, total (по всем страницам), total_pages; total_monthly_cost и currency - при with_total=true",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/health/ready": {
+            "get": {
+                "description": "Пингует БД и отдаёт задержку. Медленный ответ БД помечается degraded=true со статусом 200, недоступность БД - 503.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Готовность сервиса к приёму запросов",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.readinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "БД недоступна",
+                        "schema": {
+                            "$ref": "#/definitions/handler.readinessResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "config.Flags": {
+            "type": "object",
+            "properties": {
+                "aggregate_cache": {
+                    "type": "boolean"
+                },
+                "aggregate_summaries": {
+                    "type": "boolean"
+                },
+                "auto_renewal": {
+                    "type": "boolean"
+                },
+                "create_warnings": {
+                    "type": "boolean"
+                },
+                "expiring_report": {
+                    "type": "boolean"
+                },
+                "h2c": {
+                    "type": "boolean"
+                },
+                "keep_alives": {
+                    "type": "boolean"
+                },
+                "localized_errors": {
+                    "type": "boolean"
+                },
+                "outbox": {
+                    "type": "boolean"
+                },
+                "price_minor_units": {
+                    "type": "boolean"
+                },
+                "prune": {
+                    "type": "boolean"
+                },
+                "redis": {
+                    "type": "boolean"
+                },
+                "skip_noop_updates": {
+                    "type": "boolean"
+                },
+                "statement_timeout": {
+                    "type": "boolean"
+                },
+                "unique_policy": {
+                    "type": "string"
+                },
+                "user_limit": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handler.dbPoolStats": {
+            "type": "object",
+            "properties": {
+                "idle": {
+                    "type": "integer"
+                },
+                "in_use": {
+                    "type": "integer"
+                },
+                "max_open": {
+                    "type": "integer"
+                },
+                "open": {
+                    "type": "integer"
+                },
+                "wait_count": {
+                    "type": "integer"
+                },
+                "wait_duration_ms": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.dependencyStatus": {
+            "type": "object",
+            "properties": {
+                "latency_ms": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.diagnosticsResponse": {
+            "type": "object",
+            "properties": {
+                "db": {
+                    "$ref": "#/definitions/handler.dbPoolStats"
+                },
+                "runtime": {
+                    "$ref": "#/definitions/handler.runtimeStats"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "uptime_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.readinessResponse": {
+            "type": "object",
+            "properties": {
+                "db": {
+                    "$ref": "#/definitions/handler.dependencyStatus"
+                },
+                "degraded": {
+                    "type": "boolean"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.runtimeStats": {
+            "type": "object",
+            "properties": {
+                "go_version": {
+                    "type": "string"
+                },
+                "goroutines": {
+                    "type": "integer"
+                },
+                "heap_alloc_bytes": {
+                    "type": "integer"
+                },
+                "heap_inuse_bytes": {
+                    "type": "integer"
+                },
+                "last_gc_pause_ns": {
+                    "type": "integer"
+                },
+                "num_gc": {
+                    "type": "integer"
+                },
+                "sys_bytes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AggregateMonthlyResponse": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BucketTotal"
+                    }
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "granularity": {
+                    "type": "string"
+                },
+                "monthly": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.MonthlyTotal"
+                    }
+                },
+                "total_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AggregateResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "total_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AggregateServiceUserResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ServiceUserTotal"
+                    }
+                }
+            }
+        },
+        "model.BatchAggregateRequest": {
+            "type": "object",
+            "required": [
+                "end_date",
+                "start_date",
+                "user_ids"
+            ],
+            "properties": {
+                "end_date": {
+                    "type": "string"
+                },
+                "exact": {
+                    "type": "boolean"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "user_ids": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.BatchAggregateResponse": {
+            "type": "object",
+            "properties": {
+                "totals": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "model.BucketTotal": {
+            "type": "object",
+            "properties": {
+                "period": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "total_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ChangesPage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Subscription"
+                    }
+                },
+                "has_more": {
+                    "type": "boolean"
+                },
+                "next_after_id": {
+                    "type": "string"
+                },
+                "next_since": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.CompareAggregateResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "delta": {
+                    "type": "integer"
+                },
+                "delta_percent": {
+                    "type": "number"
+                },
+                "period_a": {
+                    "$ref": "#/definitions/model.PeriodTotal"
+                },
+                "period_b": {
+                    "$ref": "#/definitions/model.PeriodTotal"
+                }
+            }
+        },
+        "model.CreateSubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "service_name",
+                "start_date",
+                "tags",
+                "user_id"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Amount - цена в основных единицах валюты (\"9.99\") вместо price; только\nпри PRICE_MINOR_UNITS, знаков после точки не больше, чем у валюты.",
+                    "type": "string"
+                },
+                "auto_renew": {
+                    "type": "boolean"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 500
+                },
+                "end_date": {
+                    "type": "string",
+                    "example": "2025-12-31"
+                },
+                "id": {
+                    "description": "ID задаётся клиентом при переносе данных; если не задан, генерируется.",
+                    "type": "string"
+                },
+                "metadata": {
+                    "description": "Metadata - плоский JSON-объект со значениями-скалярами, не больше 2 КБ.",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "price": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string",
+                    "example": "2025-07-01"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.MaintenanceOperation": {
+            "type": "object",
+            "properties": {
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.MaintenanceResult": {
+            "type": "object",
+            "properties": {
+                "operations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.MaintenanceOperation"
+                    }
+                },
+                "total_duration_ms": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.MonthlyTotal": {
+            "type": "object",
+            "properties": {
+                "month": {
+                    "type": "string"
+                },
+                "total_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.PeriodTotal": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "total_price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.QueryPage": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "offset": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "model.RecomputeResult": {
+            "type": "object",
+            "properties": {
+                "batches": {
+                    "type": "integer"
+                },
+                "completed": {
+                    "type": "boolean"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "last_id": {
+                    "type": "string"
+                },
+                "rows_scanned": {
+                    "type": "integer"
+                },
+                "rows_updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.SchemaFilter": {
+            "type": "object",
+            "properties": {
+                "column": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "multiple": {
+                    "description": "Multiple - параметр можно повторить или передать значения через запятую.",
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "operators": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ServiceUserTotal": {
+            "type": "object",
+            "properties": {
+                "service_name": {
+                    "type": "string"
+                },
+                "total_price": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ShiftSubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "months"
+            ],
+            "properties": {
+                "months": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.SimulatePriceChangeRequest": {
+            "type": "object",
+            "required": [
+                "end_date",
+                "service_name",
+                "start_date"
+            ],
+            "properties": {
+                "end_date": {
+                    "type": "string"
+                },
+                "exact": {
+                    "type": "boolean"
+                },
+                "new_price": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "percent_change": {
+                    "type": "number"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
                 },
                 "user_id": {
                     "type": "string"
                 }
             }
         },
+        "model.SimulatePriceChangeResponse": {
+            "type": "object",
+            "properties": {
+                "current_total": {
+                    "type": "integer"
+                },
+                "delta": {
+                    "type": "integer"
+                },
+                "projected_total": {
+                    "type": "integer"
+                }
+            }
+        },
         "model.Subscription": {
             "type": "object",
             "required": [
@@ -396,39 +2576,260 @@ const docTemplate = `{
                 "user_id"
             ],
             "properties": {
+                "amount": {
+                    "description": "Amount - price в основных единицах валюты (\"9.99\"); заполняется, только\nкогда цены хранятся в минимальных единицах (PRICE_MINOR_UNITS).",
+                    "type": "string"
+                },
+                "auto_renew": {
+                    "description": "AutoRenew - продлевать подписку на месяц после end_date (фоновая задача renewal).",
+                    "type": "boolean"
+                },
                 "created_at": {
                     "type": "string"
                 },
+                "currency": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "duration_months": {
+                    "type": "integer"
+                },
                 "end_date": {
                     "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
+                "metadata": {
+                    "description": "Metadata - произвольные пары ключ-значение интеграций (плоский JSON-объект).",
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "months_remaining": {
+                    "type": "integer"
+                },
+                "next_billing_date": {
+                    "description": "Вычисляемые поля заполняются сервисом (см. SetComputedFields) и не хранятся в БД.",
+                    "type": "string"
+                },
                 "price": {
                     "type": "integer",
                     "minimum": 0
                 },
+                "price_display": {
+                    "description": "PriceDisplay - цена, отформатированная для показа (format=display), только в ответе.",
+                    "type": "string"
+                },
                 "service_name": {
                     "type": "string"
                 },
                 "start_date": {
                     "type": "string"
                 },
+                "status": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "updated_at": {
                     "type": "string"
                 },
                 "user_id": {
                     "type": "string"
+                },
+                "version": {
+                    "description": "Version растёт на 1 при каждом изменении; из него строится ETag.",
+                    "type": "integer"
+                },
+                "warnings": {
+                    "description": "Warnings - разрешённые предупреждения мягкой валидации, только в ответе на создание.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "model.UpdateSubscriptionRequest": {
+        "model.SubscriptionQuery": {
+            "type": "object",
+            "properties": {
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "filter": {
+                    "$ref": "#/definitions/model.SubscriptionQueryFilter"
+                },
+                "page": {
+                    "$ref": "#/definitions/model.QueryPage"
+                },
+                "sort": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SubscriptionQueryFilter": {
             "type": "object",
             "properties": {
+                "active_on": {
+                    "type": "string"
+                },
                 "end_date": {
                     "type": "string"
                 },
+                "exact": {
+                    "type": "boolean"
+                },
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "open_ended": {
+                    "type": "boolean"
+                },
+                "service_name": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "tag": {
+                    "type": "string"
+                },
+                "updated_after": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SubscriptionSchema": {
+            "type": "object",
+            "properties": {
+                "base_currency": {
+                    "type": "string"
+                },
+                "currency_standard": {
+                    "description": "Currency - коды валют проверяются по ISO 4217; BaseCurrency - валюта по умолчанию.",
+                    "type": "string"
+                },
+                "enums": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "facets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "fields": {
+                    "description": "Fields - поля для fields в POST /subscriptions/query.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "filters": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SchemaFilter"
+                    }
+                },
+                "sort_fields": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.SummaryRebuildResult": {
+            "type": "object",
+            "properties": {
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.TimelinePoint": {
+            "type": "object",
+            "properties": {
+                "active_count": {
+                    "type": "integer"
+                },
+                "month": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.TransferSubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "to_user_id"
+            ],
+            "properties": {
+                "to_user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UpdateSubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "tags"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "string"
+                },
+                "auto_renew": {
+                    "type": "boolean"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 500
+                },
+                "end_date": {
+                    "type": "string",
+                    "example": "2025-12-31"
+                },
+                "metadata": {
+                    "description": "Metadata заменяет metadata целиком; {} очищает.",
+                    "type": "object",
+                    "additionalProperties": true
+                },
                 "price": {
                     "type": "integer",
                     "minimum": 0
@@ -437,8 +2838,38 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "start_date": {
+                    "type": "string",
+                    "example": "2025-07-01"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.UserDeletionPreview": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "currency": {
                     "type": "string"
                 },
+                "subscriptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Subscription"
+                    }
+                },
+                "total_revenue": {
+                    "type": "integer"
+                },
                 "user_id": {
                     "type": "string"
                 }
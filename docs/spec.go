@@ -0,0 +1,14 @@
+package docs
+
+import _ "embed"
+
+// Сгенерированная спецификация в виде файлов для выгрузки (GET /openapi.json,
+// /openapi.yaml). Файл не перезаписывается swag init и подхватывает свежие
+// swagger.json/swagger.yaml при каждой сборке.
+var (
+	//go:embed swagger.json
+	SpecJSON []byte
+
+	//go:embed swagger.yaml
+	SpecYAML []byte
+)
@@ -1,17 +1,34 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"subscription_service/internal/logging"
+	"subscription_service/internal/model"
+
 	"github.com/joho/godotenv"
+	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
 )
 
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
 type Config struct {
+	AppEnv          string
 	ServerPort      string
+	DatabaseURL     string
 	PostgresHost    string
 	PostgresPort    int
 	PostgresUser    string
@@ -19,8 +36,150 @@ type Config struct {
 	PostgresDB      string
 	PostgresSSL     string
 	MigrationsPath  string
+	SkipMigrations  bool
 	LogLevel        string
 	ShutdownTimeout time.Duration
+	RequestTimeout  time.Duration
+	TLSCertFile     string
+	TLSKeyFile      string
+	BaseCurrency    string
+	RateCacheTTL    time.Duration
+	AdminAPIKey     string
+	AdminRateLimit  int
+	AdminRateWindow time.Duration
+	// TrustedProxies - IP или CIDR прокси, чьему X-Forwarded-For доверяется при
+	// определении IP клиента (лимит запросов, access log). Пусто - заголовок
+	// игнорируется, IP клиента - адрес соединения.
+	TrustedProxies []string
+	MaxBatchUsers  int
+	// MaxAggregateYears - максимальная длина периода агрегации в годах.
+	MaxAggregateYears int
+	// DatePrecision - "day" (по умолчанию) или "month": при "month" даты
+	// подписок хранятся и фильтруются с точностью до месяца.
+	DatePrecision    string
+	Timezone         string
+	ListDefaultSort  string
+	PruneEnabled     bool
+	PruneInterval    time.Duration
+	PruneAfterMonths int
+	PruneMode        string
+	PruneBatchSize   int
+
+	// ReportSchedule - cron-выражение (5 полей) отправки отчёта об
+	// заканчивающихся подписках в часовом поясе Timezone.
+	ReportEnabled        bool
+	ReportSchedule       string
+	ReportExpiringMonths int
+	ReportRecipients     []string
+	SMTPHost             string
+	SMTPPort             int
+	SMTPUsername         string
+	SMTPPassword         string
+	SMTPFrom             string
+
+	// UnprocessableValidation включает ответ 422 вместо 400 на ошибки валидации данных.
+	UnprocessableValidation bool
+	// PricesAsStrings сериализует суммы (price, total_price и другие) в JSON строками.
+	PricesAsStrings bool
+
+	// AccessLogSampleRate - доля успешных (2xx) запросов, попадающих в access-лог.
+	AccessLogSampleRate float64
+	// LogPIIMode - full (по умолчанию), hash или omit для user_id и других идентификаторов в логах.
+	LogPIIMode string
+
+	// StartMaxFutureMonths и StartMaxPastYears ограничивают start_date при
+	// создании и изменении подписки; 0 отключает проверку.
+	StartMaxFutureMonths int
+	StartMaxPastYears    int
+
+	// MaxActiveSubscriptionsPerUser - лимит незакончившихся подписок одного
+	// пользователя; 0 - без лимита.
+	MaxActiveSubscriptionsPerUser int
+
+	// ReadyPingTimeout ограничивает пинг БД в /health/ready; при задержке не
+	// меньше ReadyDegradedLatency ответ помечается degraded (0 - не проверять).
+	ReadyPingTimeout     time.Duration
+	ReadyDegradedLatency time.Duration
+
+	// Пороги мягкой валидации при создании (см. service.WarningRules); 0 отключает правило.
+	WarnPriceAbove           int
+	WarnStartOlderThanMonths int
+
+	// ExportMaxRows - максимум строк в одной выгрузке; 0 снимает ограничение.
+	ExportMaxRows int
+
+	// ListMaxIDs - максимум id в фильтре ids списка подписок.
+	ListMaxIDs int
+
+	// AggregateCacheTTL включает кэш агрегаций; 0 - кэш выключен.
+	AggregateCacheTTL time.Duration
+
+	// RedisURL - общее для реплик хранилище лимита запросов к admin API и кэша
+	// агрегаций (redis://[:password@]host:port/db). Пусто - состояние в памяти
+	// процесса; пока Redis недоступен, тоже используется память.
+	RedisURL string
+
+	// OutboxEnabled пишет события изменений подписок в таблицу outbox в той же
+	// транзакции; фоновый relay отправляет их каждые OutboxRelayInterval.
+	// Отправленные события старше OutboxRetention удаляются; 0 - хранить все.
+	OutboxEnabled       bool
+	OutboxRelayInterval time.Duration
+	OutboxBatchSize     int
+	OutboxRetention     time.Duration
+
+	// UniquePolicy - правило дедупликации при создании: none, user_service,
+	// user_service_period (см. model.UniquePolicy*).
+	UniquePolicy string
+
+	// DBStatementTimeout - statement_timeout для тяжёлых чтений (агрегации,
+	// выгрузка), выставляемый через SET LOCAL; 0 - не ограничивать.
+	DBStatementTimeout time.Duration
+
+	// EndDateRequiredServices - сервисы, подписки на которые нельзя создать без end_date.
+	EndDateRequiredServices []string
+
+	// SkipNoopUpdates - PUT, не меняющий значений, не пишется в БД и не сдвигает updated_at.
+	SkipNoopUpdates bool
+
+	// AggregateMaxGroups - максимум групп в агрегации с group_by=service_name,user_id.
+	AggregateMaxGroups int
+
+	// DBRetryAttempts - число повторов многошаговых операций при конфликте
+	// сериализации (40001) или взаимоблокировке (40P01); DBRetryBackoff -
+	// пауза перед первым повтором, далее удваивается.
+	DBRetryAttempts int
+	DBRetryBackoff  time.Duration
+
+	RenewalEnabled   bool
+	RenewalInterval  time.Duration
+	RenewalBatchSize int
+
+	// LocalizeErrors включает перевод сообщений об ошибках по Accept-Language.
+	LocalizeErrors bool
+
+	// PriceMinorUnits - price хранится и отдаётся в минимальных единицах валюты
+	// (копейки, центы; у JPY дробной части нет), а amount - в основных. Пороги
+	// вроде WarnPriceAbove тогда тоже задаются в минимальных единицах.
+	PriceMinorUnits bool
+
+	// AggregateSummaries считает итог агрегации по помесячной сводке
+	// subscription_summaries вместо подписок, когда это даёт тот же результат.
+	// Требует DATE_PRECISION=month.
+	AggregateSummaries bool
+
+	// Настройки HTTP-сервера. HTTP/2 по TLS включён всегда; HTTP2Cleartext
+	// разрешает h2c без TLS для работы за прокси. HTTPIdleTimeout - сколько
+	// держать простаивающее keep-alive соединение (0 - без ограничения), а
+	// HTTPKeepAlives=false закрывает соединение после каждого ответа.
+	HTTP2Cleartext            bool
+	HTTP2MaxConcurrentStreams int
+	HTTPIdleTimeout           time.Duration
+	HTTPKeepAlives            bool
+	HTTPMaxHeaderBytes        int
+
+	// ListSortFields - поля, по которым разрешено сортировать список (подмножество
+	// model.SortFields); пустой - все. Отдаётся в GET /subscriptions/schema.
+	ListSortFields []string
 }
 
 func Load() (*Config, error) {
@@ -28,27 +187,398 @@ func Load() (*Config, error) {
 		logrus.Warn("No .env file found, using environment variables")
 	}
 
+	env := &envParser{}
+
 	cfg := &Config{
-		ServerPort:      getEnv("SERVER_PORT", "8080"),
-		PostgresHost:    getEnv("POSTGRES_HOST", "localhost"),
-		PostgresPort:    getEnvAsInt("POSTGRES_PORT", 5432),
-		PostgresUser:    getEnv("POSTGRES_USER", "postgres"),
-		PostgresPass:    getEnv("POSTGRES_PASSWORD", "postgres"),
-		PostgresDB:      getEnv("POSTGRES_DB", "subscription_db"),
-		PostgresSSL:     getEnv("POSTGRES_SSL", "disable"),
-		MigrationsPath:  getEnv("MIGRATIONS_PATH", "file://migrations"),
-		LogLevel:        getEnv("LOG_LEVEL", "info"),
-		ShutdownTimeout: getEnvAsDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		AppEnv:            getEnv("APP_ENV", "development"),
+		ServerPort:        getEnv("SERVER_PORT", "8080"),
+		DatabaseURL:       getEnv("DATABASE_URL", ""),
+		PostgresHost:      getEnv("POSTGRES_HOST", "localhost"),
+		PostgresPort:      env.getEnvAsInt("POSTGRES_PORT", 5432),
+		PostgresUser:      getEnv("POSTGRES_USER", "postgres"),
+		PostgresPass:      getEnv("POSTGRES_PASSWORD", "postgres"),
+		PostgresDB:        getEnv("POSTGRES_DB", "subscription_db"),
+		PostgresSSL:       getEnv("POSTGRES_SSL", "disable"),
+		MigrationsPath:    getEnv("MIGRATIONS_PATH", "file://migrations"),
+		SkipMigrations:    env.getEnvAsBool("SKIP_MIGRATIONS", false),
+		LogLevel:          getEnv("LOG_LEVEL", "info"),
+		ShutdownTimeout:   env.getEnvAsDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		RequestTimeout:    env.getEnvAsDuration("REQUEST_TIMEOUT", 30*time.Second),
+		TLSCertFile:       getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:        getEnv("TLS_KEY_FILE", ""),
+		BaseCurrency:      getEnv("BASE_CURRENCY", "RUB"),
+		RateCacheTTL:      env.getEnvAsDuration("RATE_CACHE_TTL", time.Hour),
+		AdminAPIKey:       getEnv("ADMIN_API_KEY", ""),
+		AdminRateLimit:    env.getEnvAsInt("ADMIN_RATE_LIMIT", 5),
+		AdminRateWindow:   env.getEnvAsDuration("ADMIN_RATE_WINDOW", time.Minute),
+		TrustedProxies:    getEnvAsSlice("TRUSTED_PROXIES"),
+		MaxBatchUsers:     env.getEnvAsInt("MAX_BATCH_USERS", 100),
+		MaxAggregateYears: env.getEnvAsInt("AGGREGATE_MAX_YEARS", 10),
+		DatePrecision:     getEnv("DATE_PRECISION", "day"),
+		Timezone:          getEnv("TIMEZONE", "UTC"),
+		ListDefaultSort:   getEnv("LIST_DEFAULT_SORT", "-start_date"),
+
+		PruneEnabled:     env.getEnvAsBool("PRUNE_ENABLED", false),
+		PruneInterval:    env.getEnvAsDuration("PRUNE_INTERVAL", 24*time.Hour),
+		PruneAfterMonths: env.getEnvAsInt("PRUNE_AFTER_MONTHS", 12),
+		PruneMode:        getEnv("PRUNE_MODE", "archive"),
+		PruneBatchSize:   env.getEnvAsInt("PRUNE_BATCH_SIZE", 1000),
+
+		ReportEnabled:        env.getEnvAsBool("REPORT_ENABLED", false),
+		ReportSchedule:       getEnv("REPORT_SCHEDULE", "0 9 1 * *"),
+		ReportExpiringMonths: env.getEnvAsInt("REPORT_EXPIRING_MONTHS", 1),
+		ReportRecipients:     getEnvAsSlice("REPORT_RECIPIENTS"),
+		SMTPHost:             getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:             env.getEnvAsInt("SMTP_PORT", 25),
+		SMTPUsername:         getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:         getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:             getEnv("SMTP_FROM", "subscription-service@localhost"),
+
+		UnprocessableValidation: env.getEnvAsBool("VALIDATION_422", false),
+		PricesAsStrings:         env.getEnvAsBool("JSON_PRICES_AS_STRINGS", false),
+
+		AccessLogSampleRate: env.getEnvAsFloat("ACCESS_LOG_SAMPLE_RATE", 1),
+		LogPIIMode:          getEnv("LOG_PII_MODE", logging.PIIModeFull),
+
+		StartMaxFutureMonths: env.getEnvAsInt("START_DATE_MAX_FUTURE_MONTHS", 24),
+		StartMaxPastYears:    env.getEnvAsInt("START_DATE_MAX_PAST_YEARS", 0),
+
+		MaxActiveSubscriptionsPerUser: env.getEnvAsInt("MAX_ACTIVE_SUBSCRIPTIONS_PER_USER", 0),
+
+		ReadyPingTimeout:     env.getEnvAsDuration("READY_PING_TIMEOUT", 2*time.Second),
+		ReadyDegradedLatency: env.getEnvAsDuration("READY_DEGRADED_LATENCY", 200*time.Millisecond),
+
+		WarnPriceAbove:           env.getEnvAsInt("WARN_PRICE_ABOVE", 0),
+		WarnStartOlderThanMonths: env.getEnvAsInt("WARN_START_OLDER_THAN_MONTHS", 0),
+
+		ExportMaxRows: env.getEnvAsInt("EXPORT_MAX_ROWS", 1000000),
+
+		ListMaxIDs: env.getEnvAsInt("LIST_MAX_IDS", 100),
+
+		AggregateCacheTTL: env.getEnvAsDuration("AGGREGATE_CACHE_TTL", 0),
+
+		RedisURL: getEnv("REDIS_URL", ""),
+
+		OutboxEnabled:       env.getEnvAsBool("OUTBOX_ENABLED", false),
+		OutboxRelayInterval: env.getEnvAsDuration("OUTBOX_RELAY_INTERVAL", 5*time.Second),
+		OutboxBatchSize:     env.getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetention:     env.getEnvAsDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+
+		UniquePolicy: getEnv("UNIQUE_POLICY", model.UniquePolicyNone),
+
+		DBStatementTimeout: env.getEnvAsDuration("DB_STATEMENT_TIMEOUT", 0),
+
+		EndDateRequiredServices: getEnvAsSlice("END_DATE_REQUIRED_SERVICES"),
+
+		SkipNoopUpdates: env.getEnvAsBool("SKIP_NOOP_UPDATES", false),
+
+		AggregateMaxGroups: env.getEnvAsInt("AGGREGATE_MAX_GROUPS", 1000),
+
+		DBRetryAttempts: env.getEnvAsInt("DB_RETRY_ATTEMPTS", 3),
+		DBRetryBackoff:  env.getEnvAsDuration("DB_RETRY_BACKOFF", 50*time.Millisecond),
+
+		RenewalEnabled:   env.getEnvAsBool("RENEWAL_ENABLED", false),
+		RenewalInterval:  env.getEnvAsDuration("RENEWAL_INTERVAL", time.Hour),
+		RenewalBatchSize: env.getEnvAsInt("RENEWAL_BATCH_SIZE", 100),
+
+		LocalizeErrors: env.getEnvAsBool("LOCALIZE_ERRORS", false),
+
+		PriceMinorUnits: env.getEnvAsBool("PRICE_MINOR_UNITS", false),
+
+		AggregateSummaries: env.getEnvAsBool("AGGREGATE_SUMMARIES", false),
+
+		HTTP2Cleartext:            env.getEnvAsBool("HTTP2_CLEARTEXT", false),
+		HTTP2MaxConcurrentStreams: env.getEnvAsInt("HTTP2_MAX_CONCURRENT_STREAMS", 0),
+		HTTPIdleTimeout:           env.getEnvAsDuration("HTTP_IDLE_TIMEOUT", 0),
+		HTTPKeepAlives:            env.getEnvAsBool("HTTP_KEEP_ALIVES", true),
+		HTTPMaxHeaderBytes:        env.getEnvAsInt("HTTP_MAX_HEADER_BYTES", 1<<20),
+
+		ListSortFields: getEnvAsSlice("LIST_SORT_FIELDS"),
+	}
+
+	errs := env.errs
+
+	if cfg.DatabaseURL != "" {
+		if err := cfg.applyDatabaseURL(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, cfg.validate()...)
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
 	}
 
 	return cfg, nil
 }
 
+func (c *Config) IsProduction() bool {
+	return c.AppEnv == "production"
+}
+
+// TLSEnabled сообщает, что сервер должен отдавать HTTPS сам, без прокси.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// validate проверяет согласованность значений и возвращает все найденные ошибки.
+func (c *Config) validate() []error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.ServerPort); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("invalid SERVER_PORT %q: expected a number between 1 and 65535", c.ServerPort))
+	}
+
+	if !validSSLModes[c.PostgresSSL] {
+		errs = append(errs, fmt.Errorf("invalid POSTGRES_SSL %q: expected one of disable, require, verify-ca, verify-full", c.PostgresSSL))
+	}
+
+	if c.AccessLogSampleRate < 0 || c.AccessLogSampleRate > 1 {
+		errs = append(errs, fmt.Errorf("invalid ACCESS_LOG_SAMPLE_RATE %v: expected a value between 0 and 1", c.AccessLogSampleRate))
+	}
+
+	if c.MaxActiveSubscriptionsPerUser < 0 {
+		errs = append(errs, fmt.Errorf("invalid MAX_ACTIVE_SUBSCRIPTIONS_PER_USER %d: must not be negative", c.MaxActiveSubscriptionsPerUser))
+	}
+
+	if c.OutboxEnabled && (c.OutboxRelayInterval <= 0 || c.OutboxBatchSize <= 0) {
+		errs = append(errs, fmt.Errorf("OUTBOX_RELAY_INTERVAL and OUTBOX_BATCH_SIZE must be positive when OUTBOX_ENABLED=true"))
+	}
+
+	if c.AdminAPIKey != "" && (c.AdminRateLimit <= 0 || c.AdminRateWindow <= 0) {
+		errs = append(errs, fmt.Errorf("ADMIN_RATE_LIMIT and ADMIN_RATE_WINDOW must be positive when ADMIN_API_KEY is set"))
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("invalid TRUSTED_PROXIES entry %q: expected an IP address or CIDR", proxy))
+			}
+		}
+	}
+
+	if c.RedisURL != "" {
+		if u, err := url.Parse(c.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid REDIS_URL: expected redis://host:port or rediss://host:port"))
+		}
+	}
+
+	if c.OutboxRetention < 0 {
+		errs = append(errs, fmt.Errorf("invalid OUTBOX_RETENTION %s: must not be negative", c.OutboxRetention))
+	}
+
+	if c.PruneEnabled {
+		if c.PruneInterval <= 0 || c.PruneBatchSize <= 0 {
+			errs = append(errs, fmt.Errorf("PRUNE_INTERVAL and PRUNE_BATCH_SIZE must be positive when PRUNE_ENABLED=true"))
+		}
+		if c.PruneAfterMonths < 0 {
+			errs = append(errs, fmt.Errorf("invalid PRUNE_AFTER_MONTHS %d: must not be negative", c.PruneAfterMonths))
+		}
+		if c.PruneMode != "archive" && c.PruneMode != "delete" {
+			errs = append(errs, fmt.Errorf("invalid PRUNE_MODE %q: expected archive or delete", c.PruneMode))
+		}
+	}
+
+	if c.RenewalEnabled && (c.RenewalInterval <= 0 || c.RenewalBatchSize <= 0) {
+		errs = append(errs, fmt.Errorf("RENEWAL_INTERVAL and RENEWAL_BATCH_SIZE must be positive when RENEWAL_ENABLED=true"))
+	}
+
+	if c.ReportEnabled {
+		if _, err := cron.ParseStandard(c.ReportSchedule); err != nil {
+			errs = append(errs, fmt.Errorf("invalid REPORT_SCHEDULE %q: %w", c.ReportSchedule, err))
+		}
+		if len(c.ReportRecipients) == 0 {
+			errs = append(errs, fmt.Errorf("REPORT_RECIPIENTS must not be empty when REPORT_ENABLED=true"))
+		}
+	}
+
+	if c.DatePrecision != "day" && c.DatePrecision != "month" {
+		errs = append(errs, fmt.Errorf("invalid DATE_PRECISION %q: expected day or month", c.DatePrecision))
+	}
+
+	if c.AggregateSummaries && c.DatePrecision != "month" {
+		errs = append(errs, fmt.Errorf("AGGREGATE_SUMMARIES=true requires DATE_PRECISION=month"))
+	}
+
+	if c.HTTP2MaxConcurrentStreams < 0 || c.HTTPIdleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("HTTP2_MAX_CONCURRENT_STREAMS and HTTP_IDLE_TIMEOUT must not be negative"))
+	}
+
+	if c.HTTPMaxHeaderBytes <= 0 {
+		errs = append(errs, fmt.Errorf("invalid HTTP_MAX_HEADER_BYTES %d: must be positive", c.HTTPMaxHeaderBytes))
+	}
+
+	if c.DBStatementTimeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid DB_STATEMENT_TIMEOUT %s: must not be negative", c.DBStatementTimeout))
+	}
+
+	if c.DBRetryAttempts < 0 || c.DBRetryBackoff < 0 {
+		errs = append(errs, fmt.Errorf("DB_RETRY_ATTEMPTS and DB_RETRY_BACKOFF must not be negative"))
+	}
+
+	if c.ExportMaxRows < 0 {
+		errs = append(errs, fmt.Errorf("invalid EXPORT_MAX_ROWS %d: must not be negative", c.ExportMaxRows))
+	}
+
+	if c.ReadyPingTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("invalid READY_PING_TIMEOUT %s: must be positive", c.ReadyPingTimeout))
+	}
+
+	if !model.ValidUniquePolicy(c.UniquePolicy) {
+		errs = append(errs, fmt.Errorf("invalid UNIQUE_POLICY %q: expected one of none, user_service, user_service_period", c.UniquePolicy))
+	}
+
+	if !logging.ValidPIIMode(c.LogPIIMode) {
+		errs = append(errs, fmt.Errorf("invalid LOG_PII_MODE %q: expected one of full, hash, omit", c.LogPIIMode))
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+
+	if !c.SkipMigrations {
+		if err := validateMigrationsPath(c.MigrationsPath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	sortFields := model.SortFields
+	if len(c.ListSortFields) > 0 {
+		sortFields = c.ListSortFields
+		for _, field := range c.ListSortFields {
+			if _, _, ok := model.ParseSort(field); !ok || strings.HasPrefix(field, "-") {
+				errs = append(errs, fmt.Errorf("invalid LIST_SORT_FIELDS entry %q: expected one of %s",
+					field, strings.Join(model.SortFields, ", ")))
+			}
+		}
+	}
+
+	if _, _, ok := model.ParseSortIn(c.ListDefaultSort, sortFields); !ok {
+		errs = append(errs, fmt.Errorf("invalid LIST_DEFAULT_SORT %q: expected one of %s, optionally prefixed with '-'",
+			c.ListDefaultSort, strings.Join(sortFields, ", ")))
+	}
+
+	if c.IsProduction() {
+		if c.PostgresSSL == "disable" {
+			if c.DatabaseURL != "" {
+				errs = append(errs, fmt.Errorf("DATABASE_URL with sslmode=disable is not allowed when APP_ENV=production"))
+			} else {
+				errs = append(errs, fmt.Errorf("POSTGRES_SSL=disable is not allowed when APP_ENV=production"))
+			}
+		}
+
+		if c.DatabaseURL == "" {
+			for _, key := range []string{"POSTGRES_HOST", "POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DB"} {
+				if os.Getenv(key) == "" {
+					errs = append(errs, fmt.Errorf("%s is required when APP_ENV=production (or set DATABASE_URL)", key))
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateMigrationsPath проверяет, что для file:// источника каталог миграций существует.
+// Остальные источники (github://, s3:// и т.п.) проверяет сам golang-migrate.
+func validateMigrationsPath(path string) error {
+	dir, ok := strings.CutPrefix(path, "file://")
+	if !ok {
+		return nil
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("invalid MIGRATIONS_PATH %q: directory %q does not exist "+
+				"(point it at the migrations directory or set SKIP_MIGRATIONS=true if migrations are applied separately)", path, dir)
+		}
+		return fmt.Errorf("invalid MIGRATIONS_PATH %q: %w", path, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("invalid MIGRATIONS_PATH %q: %q is not a directory", path, dir)
+	}
+
+	return nil
+}
+
+// applyDatabaseURL разбирает DATABASE_URL и переопределяет им отдельные POSTGRES_* поля.
+func (c *Config) applyDatabaseURL() error {
+	u, err := url.Parse(c.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("invalid DATABASE_URL: unsupported scheme %q, expected postgres or postgresql", u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid DATABASE_URL: host is required")
+	}
+
+	dbName := strings.TrimPrefix(u.Path, "/")
+	if dbName == "" {
+		return fmt.Errorf("invalid DATABASE_URL: database name is required")
+	}
+
+	c.PostgresHost = u.Hostname()
+	c.PostgresDB = dbName
+
+	if port := u.Port(); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("invalid DATABASE_URL: invalid port %q", port)
+		}
+		c.PostgresPort = p
+	} else {
+		c.PostgresPort = 5432
+	}
+
+	if u.User != nil {
+		c.PostgresUser = u.User.Username()
+		if pass, ok := u.User.Password(); ok {
+			c.PostgresPass = pass
+		}
+	}
+
+	// Подключение идёт по самому URL, поэтому PostgresSSL должен отражать режим,
+	// с которым соединится lib/pq: без sslmode это require, а не POSTGRES_SSL.
+	c.PostgresSSL = u.Query().Get("sslmode")
+	if c.PostgresSSL == "" {
+		c.PostgresSSL = "require"
+	}
+
+	return nil
+}
+
 func (c *Config) GetPostgresDSN() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
+
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		c.PostgresHost, c.PostgresPort, c.PostgresUser, c.PostgresPass, c.PostgresDB, c.PostgresSSL)
 }
 
+// GetPostgresURL возвращает строку подключения в URL-форме, которую принимает golang-migrate.
+func (c *Config) GetPostgresURL() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.PostgresUser, c.PostgresPass),
+		Host:     net.JoinHostPort(c.PostgresHost, strconv.Itoa(c.PostgresPort)),
+		Path:     "/" + c.PostgresDB,
+		RawQuery: url.Values{"sslmode": []string{c.PostgresSSL}}.Encode(),
+	}
+	return u.String()
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -56,20 +586,75 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intVal, err := strconv.Atoi(value); err == nil {
-			return intVal
+// getEnvAsSlice разбирает список значений, разделённых запятыми.
+func getEnvAsSlice(key string) []string {
+	var values []string
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
 		}
 	}
-	return defaultValue
+	return values
 }
 
-func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
-		}
+// envParser разбирает типизированные переменные окружения и накапливает ошибки
+// для значений, которые заданы, но не разбираются.
+type envParser struct {
+	errs []error
+}
+
+func (p *envParser) getEnvAsInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
-	return defaultValue
+
+	intVal, err := strconv.Atoi(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid %s %q: expected an integer", key, value))
+		return defaultValue
+	}
+	return intVal
+}
+
+func (p *envParser) getEnvAsFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid %s %q: expected a number", key, value))
+		return defaultValue
+	}
+	return floatVal
+}
+
+func (p *envParser) getEnvAsBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	boolVal, err := strconv.ParseBool(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid %s %q: expected a boolean", key, value))
+		return defaultValue
+	}
+	return boolVal
+}
+
+func (p *envParser) getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("invalid %s %q: expected a duration like 30s or 5m", key, value))
+		return defaultValue
+	}
+	return duration
 }
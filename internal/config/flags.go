@@ -0,0 +1,45 @@
+package config
+
+// Flags - включённые опциональные возможности сервиса. Флаги выводятся из тех
+// же переменных окружения, что и настройки самих возможностей, поэтому флаг и
+// фактическое поведение не расходятся; переключение - сменой окружения и
+// перезапуском, без пересборки.
+type Flags struct {
+	Outbox           bool   `json:"outbox"`
+	AggregateCache   bool   `json:"aggregate_cache"`
+	SkipNoopUpdates  bool   `json:"skip_noop_updates"`
+	StatementTimeout bool   `json:"statement_timeout"`
+	Prune            bool   `json:"prune"`
+	ExpiringReport   bool   `json:"expiring_report"`
+	AutoRenewal      bool   `json:"auto_renewal"`
+	LocalizedErrors  bool   `json:"localized_errors"`
+	UserLimit        bool   `json:"user_limit"`
+	CreateWarnings   bool   `json:"create_warnings"`
+	UniquePolicy     string `json:"unique_policy"`
+	PriceMinorUnits  bool   `json:"price_minor_units"`
+	Summaries        bool   `json:"aggregate_summaries"`
+	H2C              bool   `json:"h2c"`
+	KeepAlives       bool   `json:"keep_alives"`
+	Redis            bool   `json:"redis"`
+}
+
+func (c *Config) Flags() Flags {
+	return Flags{
+		Outbox:           c.OutboxEnabled,
+		AggregateCache:   c.AggregateCacheTTL > 0,
+		SkipNoopUpdates:  c.SkipNoopUpdates,
+		StatementTimeout: c.DBStatementTimeout > 0,
+		Prune:            c.PruneEnabled,
+		ExpiringReport:   c.ReportEnabled,
+		AutoRenewal:      c.RenewalEnabled,
+		LocalizedErrors:  c.LocalizeErrors,
+		UserLimit:        c.MaxActiveSubscriptionsPerUser > 0,
+		CreateWarnings:   c.WarnPriceAbove > 0 || c.WarnStartOlderThanMonths > 0,
+		UniquePolicy:     c.UniquePolicy,
+		PriceMinorUnits:  c.PriceMinorUnits,
+		Summaries:        c.AggregateSummaries,
+		H2C:              c.HTTP2Cleartext,
+		KeepAlives:       c.HTTPKeepAlives,
+		Redis:            c.RedisURL != "",
+	}
+}
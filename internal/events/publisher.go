@@ -0,0 +1,27 @@
+// Package events доставляет события об изменениях подписок внешним потребителям.
+package events
+
+import (
+	"subscription_service/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Publisher отправляет событие потребителям. Ошибка означает, что событие не
+// доставлено и будет отправлено повторно.
+type Publisher interface {
+	Publish(event model.OutboxEvent) error
+}
+
+// LogPublisher пишет события в лог. Используется, пока не подключён внешний
+// брокер, чтобы outbox не копился.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(event model.OutboxEvent) error {
+	logrus.WithFields(logrus.Fields{
+		"event_id":     event.ID,
+		"event_type":   event.EventType,
+		"aggregate_id": event.AggregateID,
+	}).Info("Subscription event published")
+	return nil
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"subscription_service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRecomputeBatchSize = 1000
+	maxRecomputeBatchSize     = 10000
+)
+
+type AdminHandler struct {
+	maintenance service.MaintenanceService
+	recompute   service.RecomputeService
+	summaries   service.SummaryService
+	// pricesAsStrings - как Options.PricesAsStrings у SubscriptionHandler.
+	pricesAsStrings bool
+}
+
+func NewAdminHandler(maintenance service.MaintenanceService, recompute service.RecomputeService, summaries service.SummaryService, pricesAsStrings bool) *AdminHandler {
+	return &AdminHandler{maintenance: maintenance, recompute: recompute, summaries: summaries, pricesAsStrings: pricesAsStrings}
+}
+
+// respondJSON - SubscriptionHandler.respondJSON для административных ответов.
+func (h *AdminHandler) respondJSON(c *gin.Context, status int, obj interface{}) {
+	renderJSON(c, h.pricesAsStrings || acceptsStringPrices(c.GetHeader("Accept")), status, obj)
+}
+
+// RunMaintenance
+// @Summary Запустить обслуживание таблицы подписок (ANALYZE, опционально REINDEX)
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param reindex query bool false "Дополнительно выполнить REINDEX"
+// @Success 200 {object} model.MaintenanceResult
+// @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 401 {object} map[string]interface{} "Неверный API-ключ"
+// @Failure 429 {object} map[string]interface{} "Превышен лимит запросов"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/maintenance [post]
+func (h *AdminHandler) RunMaintenance(c *gin.Context) {
+	reindex := false
+	if r := c.Query("reindex"); r != "" {
+		parsed, err := strconv.ParseBool(r)
+		if err != nil {
+			logrus.WithField("reindex", r).Warn("Invalid reindex parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reindex parameter"})
+			return
+		}
+		reindex = parsed
+	}
+
+	result, err := h.maintenance.Run(reindex)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to run maintenance")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run maintenance"})
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, result)
+}
+
+// RecomputeDerived
+// @Summary Пересчитать производные колонки подписок батчами
+// @Description Приводит tags, currency и description сохранённых подписок к виду, который пишет API (строки, записанные в обход сервиса или до нормализации). Сводку subscription_summaries пересобирает POST /api/v1/admin/rebuild-summaries. Идемпотентно: повторный запуск не меняет актуальные строки. Если completed=false (например, истёк таймаут запроса), продолжите с after_id=last_id.
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param after_id query string false "Продолжить после этого id"
+// @Param batch_size query int false "Размер батча (по умолчанию 1000, максимум 10000)"
+// @Success 200 {object} model.RecomputeResult
+// @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 401 {object} map[string]interface{} "Неверный API-ключ"
+// @Failure 429 {object} map[string]interface{} "Превышен лимит запросов"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/recompute [post]
+func (h *AdminHandler) RecomputeDerived(c *gin.Context) {
+	var afterID *uuid.UUID
+	if a := c.Query("after_id"); a != "" {
+		parsed, err := uuid.Parse(a)
+		if err != nil {
+			logrus.WithField("after_id", a).Warn("Invalid after_id parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after_id parameter"})
+			return
+		}
+		afterID = &parsed
+	}
+
+	batchSize := defaultRecomputeBatchSize
+	if b := c.Query("batch_size"); b != "" {
+		parsed, err := strconv.Atoi(b)
+		if err != nil || parsed < 1 || parsed > maxRecomputeBatchSize {
+			logrus.WithField("batch_size", b).Warn("Invalid batch_size parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch_size parameter"})
+			return
+		}
+		batchSize = parsed
+	}
+
+	result, err := h.recompute.Run(c.Request.Context(), afterID, batchSize)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to recompute derived columns")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recompute derived columns"})
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, result)
+}
+
+// RebuildSummaries
+// @Summary Пересобрать помесячную сводку агрегаций
+// @Description Сводка subscription_summaries поддерживается триггером при каждой записи; пересборка нужна после изменений в обход триггера. На время пересборки запись в подписки блокируется.
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} model.SummaryRebuildResult
+// @Failure 401 {object} map[string]interface{} "Неверный API-ключ"
+// @Failure 429 {object} map[string]interface{} "Превышен лимит запросов"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/rebuild-summaries [post]
+func (h *AdminHandler) RebuildSummaries(c *gin.Context) {
+	result, err := h.summaries.Rebuild()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to rebuild subscription summaries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild subscription summaries"})
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, result)
+}
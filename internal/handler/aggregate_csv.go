@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+
+	"subscription_service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// utf8BOM в начале файла нужен Excel, чтобы открыть CSV как UTF-8.
+const utf8BOM = "\ufeff"
+
+// writeAggregateCSVHeaders начинает ответ-файл CSV. При false ответ оборван.
+func writeAggregateCSVHeaders(c *gin.Context) bool {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="aggregate.csv"`)
+	c.Status(http.StatusOK)
+
+	if _, err := c.Writer.WriteString(utf8BOM); err != nil {
+		logrus.WithError(err).Error("Failed to write aggregate CSV")
+		return false
+	}
+	return true
+}
+
+// writeAggregateCSV отдаёт временной ряд агрегации как CSV-файл: строка на
+// интервал с шагом granularity и, при include_total, итоговая строка "total".
+func writeAggregateCSV(c *gin.Context, result *model.AggregateMonthlyResponse) {
+	if !writeAggregateCSVHeaders(c) {
+		return
+	}
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"period", "total_price", "currency"})
+	for _, b := range result.Buckets {
+		_ = w.Write([]string{b.Period, strconv.Itoa(int(b.TotalPrice)), result.Currency})
+	}
+	if result.TotalPrice != nil {
+		_ = w.Write([]string{"total", strconv.Itoa(int(*result.TotalPrice)), result.Currency})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		logrus.WithError(err).Error("Failed to write aggregate CSV")
+	}
+}
+
+// writeAggregateMatrixCSV отдаёт разбивку по (service_name, user_id) как CSV-файл.
+func writeAggregateMatrixCSV(c *gin.Context, result *model.AggregateServiceUserResponse) {
+	if !writeAggregateCSVHeaders(c) {
+		return
+	}
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"service_name", "user_id", "total_price", "currency"})
+	for _, g := range result.Groups {
+		_ = w.Write([]string{g.ServiceName, g.UserID.String(), strconv.Itoa(int(g.TotalPrice)), result.Currency})
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		logrus.WithError(err).Error("Failed to write aggregate CSV")
+	}
+}
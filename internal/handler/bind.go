@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// errCodeEmptyBody - машинно-читаемый код ошибки для запроса без тела.
+const errCodeEmptyBody = "EMPTY_BODY"
+
+// bindJSON разбирает тело запроса в obj. Пустое тело отличается от
+// некорректного: вместо невнятного "EOF" клиент получает код EMPTY_BODY.
+// При false ответ с ошибкой уже записан.
+func (h *SubscriptionHandler) bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	if c.Request.Body == nil || c.Request.Body == http.NoBody || errors.Is(err, io.EOF) {
+		logrus.WithField("path", c.FullPath()).Warn("Empty request body")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body is required", "code": errCodeEmptyBody})
+		return false
+	}
+
+	logrus.WithError(err).Warn("Invalid request body")
+	c.JSON(h.bindingStatus(err), gin.H{"error": "Invalid request format: " + err.Error()})
+	return false
+}
+
+// bindQuery разбирает параметры запроса в obj. При false ответ с ошибкой уже записан.
+func (h *SubscriptionHandler) bindQuery(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindQuery(obj)
+	if err == nil {
+		return true
+	}
+
+	logrus.WithError(err).Warn("Invalid query parameters")
+	c.JSON(h.bindingStatus(err), gin.H{"error": "Invalid query parameters: " + err.Error()})
+	return false
+}
+
+// bindingStatus выбирает статус ошибки привязки. Нарушение тегов binding
+// (min, uuid, datetime...) - ошибка валидации данных, как и ValidationError
+// сервиса, поэтому при UnprocessableValidation это 422. Синтаксически
+// некорректный JSON и несовпадение типов остаются 400.
+func (h *SubscriptionHandler) bindingStatus(err error) int {
+	var validationErrs validator.ValidationErrors
+	if h.opts.UnprocessableValidation && errors.As(err, &validationErrs) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DBStatser - источник статистики пула соединений (например, *sql.DB).
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+type DiagnosticsHandler struct {
+	db        DBStatser
+	startedAt time.Time
+}
+
+func NewDiagnosticsHandler(db DBStatser, startedAt time.Time) *DiagnosticsHandler {
+	return &DiagnosticsHandler{db: db, startedAt: startedAt}
+}
+
+type dbPoolStats struct {
+	MaxOpen        int   `json:"max_open"`
+	Open           int   `json:"open"`
+	InUse          int   `json:"in_use"`
+	Idle           int   `json:"idle"`
+	WaitCount      int64 `json:"wait_count"`
+	WaitDurationMs int64 `json:"wait_duration_ms"`
+}
+
+type runtimeStats struct {
+	GoVersion      string `json:"go_version"`
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapInuseBytes uint64 `json:"heap_inuse_bytes"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+	LastGCPauseNs  uint64 `json:"last_gc_pause_ns"`
+}
+
+type diagnosticsResponse struct {
+	UptimeSeconds int64        `json:"uptime_seconds"`
+	StartedAt     time.Time    `json:"started_at"`
+	DB            dbPoolStats  `json:"db"`
+	Runtime       runtimeStats `json:"runtime"`
+}
+
+// Diagnostics
+// @Summary Диагностика процесса: пул соединений с БД, рантайм Go, аптайм
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} diagnosticsResponse
+// @Failure 401 {object} map[string]interface{} "Неверный API-ключ"
+// @Failure 429 {object} map[string]interface{} "Превышен лимит запросов"
+// @Router /api/v1/admin/diagnostics [get]
+func (h *DiagnosticsHandler) Diagnostics(c *gin.Context) {
+	stats := h.db.Stats()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	c.JSON(http.StatusOK, diagnosticsResponse{
+		UptimeSeconds: int64(time.Since(h.startedAt).Seconds()),
+		StartedAt:     h.startedAt,
+		DB: dbPoolStats{
+			MaxOpen:        stats.MaxOpenConnections,
+			Open:           stats.OpenConnections,
+			InUse:          stats.InUse,
+			Idle:           stats.Idle,
+			WaitCount:      stats.WaitCount,
+			WaitDurationMs: stats.WaitDuration.Milliseconds(),
+		},
+		Runtime: runtimeStats{
+			GoVersion:      runtime.Version(),
+			Goroutines:     runtime.NumGoroutine(),
+			HeapAllocBytes: mem.HeapAlloc,
+			HeapInuseBytes: mem.HeapInuse,
+			SysBytes:       mem.Sys,
+			NumGC:          mem.NumGC,
+			LastGCPauseNs:  mem.PauseNs[(mem.NumGC+255)%256],
+		},
+	})
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+
+	"subscription_service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const displayFormat = "display"
+
+// displayLocales - локали, под которые форматируется price_display. Первая -
+// значение по умолчанию, если Accept-Language не передан или не совпал ни с одной.
+var displayLocales = language.NewMatcher([]language.Tag{
+	language.English,
+	language.Make("ru"),
+	language.Make("de"),
+	language.Make("fr"),
+})
+
+// parseDisplayFormat проверяет параметр format у GET и списка. При ошибке ответ
+// 400 уже отправлен и возвращается ok=false.
+func parseDisplayFormat(c *gin.Context) (display bool, ok bool) {
+	format := c.Query("format")
+	switch format {
+	case "":
+		return false, true
+	case displayFormat:
+		return true, true
+	default:
+		logrus.WithField("format", format).Warn("Unsupported format parameter")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format parameter, supported: display"})
+		return false, false
+	}
+}
+
+// setPriceDisplay заполняет price_display по валюте подписки и локали из
+// Accept-Language. Подписки с неизвестным кодом валюты остаются без поля.
+func (h *SubscriptionHandler) setPriceDisplay(c *gin.Context, subs ...*model.Subscription) {
+	tags, _, _ := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+	tag, _, _ := displayLocales.Match(tags...)
+	printer := message.NewPrinter(tag)
+
+	for _, sub := range subs {
+		unit, err := currency.ParseISO(sub.Currency)
+		if err != nil {
+			continue
+		}
+		var amount interface{} = sub.Price
+		if h.opts.PriceMinorUnits {
+			amount = float64(sub.Price) / math.Pow10(model.CurrencyScale(sub.Currency))
+		}
+		sub.PriceDisplay = printer.Sprint(currency.Symbol(unit.Amount(amount)))
+	}
+}
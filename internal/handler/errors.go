@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"subscription_service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// statusClientClosedRequest - нестандартный статус nginx для запросов, клиент
+// которых отключился до ответа.
+const statusClientClosedRequest = 499
+
+// retryAfterSeconds - Retry-After для ответов 503: переключение primary и
+// конфликты транзакций обычно разрешаются за несколько секунд.
+const retryAfterSeconds = "5"
+
+// logRequestError логирует ошибку обработки запроса. Отмена запроса клиентом и
+// истёкший таймаут - не сбои сервиса, поэтому они пишутся на уровнях Debug и
+// Info, а не Error.
+func logRequestError(err error, fields logrus.Fields, msg string) {
+	entry := logrus.WithError(err).WithFields(fields)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		entry.Debug(msg + ": request canceled by client")
+	case errors.Is(err, context.DeadlineExceeded):
+		entry.Info(msg + ": request timed out")
+	default:
+		entry.Error(msg)
+	}
+}
+
+// errorStatus сопоставляет ошибку сервиса с HTTP-статусом и сообщением для клиента.
+// Для неизвестных ошибок возвращается 500 с сообщением fallback.
+func (h *SubscriptionHandler) errorStatus(err error, fallback string) (int, string) {
+	var validationErr *service.ValidationError
+	var notFoundErr *service.NotFoundError
+	var limitErr *service.LimitExceededError
+	var warningsErr *service.WarningsError
+	var duplicateErr *service.DuplicateSubscriptionError
+
+	switch {
+	case errors.As(err, &validationErr):
+		if h.opts.UnprocessableValidation {
+			return http.StatusUnprocessableEntity, validationErr.Error()
+		}
+		return http.StatusBadRequest, validationErr.Error()
+
+	case errors.As(err, &notFoundErr):
+		return http.StatusNotFound, notFoundErr.Error()
+
+	case errors.As(err, &limitErr):
+		return http.StatusConflict, limitErr.Error()
+
+	case errors.As(err, &duplicateErr):
+		return http.StatusConflict, duplicateErr.Error()
+
+	case errors.As(err, &warningsErr):
+		return http.StatusUnprocessableEntity, "Subscription has validation warnings, retry with allow_warnings=true to accept them"
+
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound, "Subscription not found"
+
+	case errors.Is(err, service.ErrNoUpdates):
+		return http.StatusBadRequest, "No fields to update"
+
+	case errors.Is(err, service.ErrDuplicateID):
+		return http.StatusConflict, "Subscription with this id already exists"
+
+	case errors.Is(err, service.ErrVersionConflict):
+		return http.StatusPreconditionFailed, "Subscription has been modified, ETag does not match"
+
+	case errors.Is(err, service.ErrRetriesExhausted):
+		return http.StatusServiceUnavailable, "Database is busy with concurrent changes, please retry"
+
+	case errors.Is(err, service.ErrReadOnly):
+		return http.StatusServiceUnavailable, "Database is temporarily read-only, please retry later"
+
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "Request timed out"
+
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest, "Request canceled"
+
+	default:
+		return http.StatusInternalServerError, fallback
+	}
+}
+
+func (h *SubscriptionHandler) respondError(c *gin.Context, err error, fallback string) {
+	status, message := h.errorStatus(err, fallback)
+	message = h.localizeError(c, err, message)
+
+	// 503 здесь - всегда временное состояние БД, клиенту стоит повторить запрос.
+	if status == http.StatusServiceUnavailable {
+		c.Header("Retry-After", retryAfterSeconds)
+	}
+
+	var warningsErr *service.WarningsError
+	if errors.As(err, &warningsErr) {
+		c.JSON(status, gin.H{"error": message, "warnings": warningsErr.Warnings})
+		return
+	}
+
+	c.JSON(status, gin.H{"error": message})
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"subscription_service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// formatETag строит сильный ETag подписки из её версии: "<version>".
+func formatETag(version int) string {
+	return fmt.Sprintf("%q", strconv.Itoa(version))
+}
+
+// parseETag извлекает версию из ETag, построенного formatETag.
+// Слабые ETag (W/"...") для If-Match не подходят и не распознаются.
+func parseETag(etag string) (int, bool) {
+	etag = strings.TrimSpace(etag)
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(etag[1 : len(etag)-1])
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+func setETag(c *gin.Context, sub *model.Subscription) {
+	c.Header("ETag", formatETag(sub.Version))
+}
+
+// requireIfMatch читает обязательный заголовок If-Match и возвращает ожидаемую
+// версию; "*" означает любую версию (nil). Без заголовка отвечает 428, на
+// нераспознанный ETag - 412, так как он не может совпасть с текущим. При
+// ok=false ответ уже записан.
+func requireIfMatch(c *gin.Context) (expected *int, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return nil, false
+	}
+
+	if header == "*" {
+		return nil, true
+	}
+
+	version, valid := parseETag(header)
+	if !valid {
+		logrus.WithField("if_match", header).Warn("Unrecognized If-Match header")
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the current ETag"})
+		return nil, false
+	}
+
+	return &version, true
+}
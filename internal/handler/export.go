@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// exportFlushEvery - через сколько записей сбрасывать буфер ответа клиенту.
+const exportFlushEvery = 100
+
+// ExportSubscriptions
+// @Summary Выгрузка подписок в формате NDJSON
+// @Description Отдаёт все подписки, подходящие под фильтры списка (без пагинации), по одному JSON-объекту на строку.
+// @Description Выгрузка ограничена EXPORT_MAX_ROWS строками; если подходящих строк больше, последней строкой идёт {"warning": "..."}.
+// @Description Взаимоисключающие параметры (400 с названием конфликта): period и created_from, period и created_to.
+// @Description Строковые цены (Accept: application/json; prices=string или JSON_PRICES_AS_STRINGS) применяются и к строкам выгрузки.
+// @Description Если выгрузка оборвалась после первой строки, последней строкой идёт {"error": "..."}: статус 200 к этому моменту уже отправлен.
+// @Tags subscriptions
+// @Produce application/x-ndjson
+// @Param format query string false "Формат выгрузки (ndjson)"
+// @Param ids query []string false "Только подписки с указанными id; повтором параметра или через запятую" collectionFormat(multi)
+// @Param user_id query string false "Фильтр по ID пользователя"
+// @Param service_name query []string false "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую" collectionFormat(multi)
+// @Param exact query bool false "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)"
+// @Param start_date query string false "Фильтр по дате начала (подписки, начавшиеся не раньше)"
+// @Param end_date query string false "Фильтр по дате начала (подписки, начавшиеся не позже)"
+// @Param tag query string false "Фильтр по тегу"
+// @Param open_ended query bool false "true - только бессрочные (без end_date), false - только с end_date"
+// @Param active_on query string false "Подписки, активные на дату (YYYY-MM-DD)"
+// @Param created_from query string false "Созданы не раньше (RFC3339)"
+// @Param created_to query string false "Созданы раньше (RFC3339)"
+// @Param period query string false "Пресет по дате создания: today, week, month (в часовом поясе TIMEZONE)"
+// @Success 200 {object} model.Subscription
+// @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/export [get]
+func (h *SubscriptionHandler) ExportSubscriptions(c *gin.Context) {
+	if conflict := checkExclusiveParams(c, listExclusiveParams); conflict != "" {
+		logrus.WithField("conflict", conflict).Warn("Conflicting query parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": conflict})
+		return
+	}
+
+	format := c.DefaultQuery("format", "ndjson")
+	if format != "ndjson" {
+		logrus.WithField("format", format).Warn("Unsupported export format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported export format, supported: ndjson"})
+		return
+	}
+
+	req := listRequestFromQuery(c)
+	encoder := json.NewEncoder(c.Writer)
+	stringPrices := h.stringPrices(c)
+	written := 0
+
+	err := h.service.Export(c.Request.Context(), req, func(sub *model.Subscription) error {
+		if written == 0 {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+
+		var row interface{} = sub
+		if stringPrices {
+			converted, err := pricesToStrings(sub)
+			if err != nil {
+				return err
+			}
+			row = converted
+		}
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	if errors.Is(err, service.ErrExportTruncated) {
+		logrus.WithField("written", written).Warn("Export truncated at row limit")
+		if err := encoder.Encode(gin.H{
+			"warning": fmt.Sprintf("export truncated after %d rows, narrow the filters to get the rest", written),
+		}); err != nil {
+			logrus.WithError(err).Error("Failed to write export truncation warning")
+		}
+		c.Writer.Flush()
+		return
+	}
+
+	if err != nil {
+		logRequestError(err, logrus.Fields{"written": written}, "Failed to export subscriptions")
+		if written == 0 {
+			h.respondError(c, err, "Failed to export subscriptions")
+			return
+		}
+
+		// Статус 200 уже отправлен: об обрыве выгрузки клиент узнаёт по
+		// последней строке, как и об усечении по лимиту.
+		_, message := h.errorStatus(err, "Failed to export subscriptions")
+		if err := encoder.Encode(gin.H{
+			"error": fmt.Sprintf("export failed after %d rows: %s", written, message),
+		}); err != nil {
+			logrus.WithError(err).Error("Failed to write export error line")
+		}
+		c.Writer.Flush()
+		return
+	}
+
+	if written == 0 {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+	}
+	c.Writer.Flush()
+
+	logrus.WithField("count", written).Info("Subscriptions exported")
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+
+	"subscription_service/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeFeatures
+// @Summary Состояние опциональных возможностей сервиса
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} config.Flags
+// @Failure 401 {object} map[string]interface{} "Неверный API-ключ"
+// @Failure 429 {object} map[string]interface{} "Превышен лимит запросов"
+// @Router /api/v1/admin/features [get]
+func ServeFeatures(flags config.Flags) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, flags)
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Pinger - зависимость, доступность которой проверяет readiness (например, *sql.DB).
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	db Pinger
+	// pingTimeout ограничивает ожидание ответа БД.
+	pingTimeout time.Duration
+	// degradedLatency - задержка ping, начиная с которой сервис считается degraded (0 - не проверять).
+	degradedLatency time.Duration
+}
+
+func NewHealthHandler(db Pinger, pingTimeout, degradedLatency time.Duration) *HealthHandler {
+	return &HealthHandler{db: db, pingTimeout: pingTimeout, degradedLatency: degradedLatency}
+}
+
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+type readinessResponse struct {
+	Status   string           `json:"status"`
+	Degraded bool             `json:"degraded"`
+	DB       dependencyStatus `json:"db"`
+}
+
+// Ready
+// @Summary Готовность сервиса к приёму запросов
+// @Description Пингует БД и отдаёт задержку. Медленный ответ БД помечается degraded=true со статусом 200, недоступность БД - 503.
+// @Tags health
+// @Produce json
+// @Success 200 {object} readinessResponse
+// @Failure 503 {object} readinessResponse "БД недоступна"
+// @Router /health/ready [get]
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.pingTimeout)
+	defer cancel()
+
+	started := time.Now()
+	err := h.db.PingContext(ctx)
+	latency := time.Since(started)
+
+	resp := readinessResponse{
+		Status: "ok",
+		DB:     dependencyStatus{Status: "ok", LatencyMs: latency.Milliseconds()},
+	}
+
+	if err != nil {
+		logrus.WithError(err).WithField("latency_ms", resp.DB.LatencyMs).Error("Readiness check: database ping failed")
+		resp.Status = "unavailable"
+		resp.DB.Status = "down"
+		c.JSON(http.StatusServiceUnavailable, resp)
+		return
+	}
+
+	if h.degradedLatency > 0 && latency >= h.degradedLatency {
+		logrus.WithField("latency_ms", resp.DB.LatencyMs).Warn("Readiness check: database ping is slow")
+		resp.Status = "degraded"
+		resp.Degraded = true
+		resp.DB.Status = "slow"
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
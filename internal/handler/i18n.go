@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"subscription_service/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// errorLanguages - языки сообщений об ошибках; первый (английский) - исходный
+// язык текстов сервиса и значение по умолчанию.
+var errorLanguages = []string{"en", "ru"}
+
+var errorLanguageMatcher = language.NewMatcher([]language.Tag{
+	language.English,
+	language.Make("ru"),
+})
+
+// errorCatalog - переводы сообщений об ошибках: язык -> ключ -> шаблон fmt.
+// Английского каталога нет: исходные тексты берутся из ошибок сервиса.
+var errorCatalog = map[string]map[string]string{
+	"ru": {
+		"validation":       "ошибка валидации: %s",
+		"validation_field": "ошибка валидации поля '%s': %s",
+
+		service.MsgInvalidUUID:         "неверный формат UUID",
+		service.MsgInvalidDate:         "неверный формат даты, ожидается YYYY-MM-DD",
+		service.MsgInvalidTimestamp:    "неверный формат времени, ожидается RFC3339",
+		service.MsgNegativePrice:       "цена не может быть отрицательной",
+		service.MsgEmptyTag:            "тег не может быть пустым",
+		service.MsgTagTooLong:          "тег %q длиннее %d символов",
+		service.MsgTooManyTags:         "слишком много тегов: %d, максимум %d",
+		service.MsgDescriptionTooLong:  "описание длиннее %d символов",
+		service.MsgStartAfterEnd:       "start_date должна быть не позже end_date",
+		service.MsgEmptyStartDate:      "start_date не может быть пустой",
+		service.MsgEndDateRequired:     "для сервиса %q end_date обязательна",
+		service.MsgStartTooFarInFuture: "start_date не может быть позже чем через %d мес.",
+		service.MsgStartTooFarInPast:   "start_date не может быть раньше чем %d лет назад",
+		service.MsgTooManyIDs:          "слишком много id: %d, максимум %d",
+		service.MsgMetadataNotFlat:     "значение metadata по ключу %q должно быть строкой, числом, логическим значением или null",
+		service.MsgMetadataTooLarge:    "metadata больше %d байт",
+		service.MsgEmptyMetadataKey:    "ключ metadata не может быть пустым",
+		service.MsgInvalidAmount:       "amount должен быть неотрицательным десятичным числом, не больше %d знаков после точки для %s",
+		service.MsgAmountUnsupported:   "amount поддерживается, только когда цены хранятся в минимальных единицах валюты",
+		service.MsgPriceAndAmount:      "нужно указать либо price, либо amount",
+		service.MsgSameOwner:           "подписка уже принадлежит этому пользователю",
+
+		"not_found":          "подписка с id '%s' не найдена",
+		"subscription_gone":  "Подписка не найдена",
+		"limit_exceeded":     "у пользователя '%s' уже максимум активных подписок: %d",
+		"duplicate":          "подписка нарушает политику уникальности '%s'",
+		"duplicate_existing": "подписка нарушает политику уникальности '%s': конфликт с подпиской '%s'",
+		"duplicate_id":       "Подписка с таким id уже существует",
+		"no_updates":         "Нет полей для обновления",
+		"version_conflict":   "Подписка была изменена, ETag не совпадает",
+		"retries_exhausted":  "БД занята конкурентными изменениями, повторите запрос",
+		"read_only":          "БД временно доступна только для чтения, повторите запрос позже",
+	},
+}
+
+// errorLanguage выбирает язык сообщений об ошибках по Accept-Language.
+func errorLanguage(c *gin.Context) string {
+	tags, _, _ := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+	_, index, _ := errorLanguageMatcher.Match(tags...)
+	return errorLanguages[index]
+}
+
+// localizeError переводит сообщение об ошибке на язык клиента. Если язык
+// английский или перевода нет, возвращается исходное сообщение message.
+func (h *SubscriptionHandler) localizeError(c *gin.Context, err error, message string) string {
+	if !h.opts.LocalizeErrors {
+		return message
+	}
+
+	catalog, ok := errorCatalog[errorLanguage(c)]
+	if !ok {
+		return message
+	}
+
+	var validationErr *service.ValidationError
+	var notFoundErr *service.NotFoundError
+	var limitErr *service.LimitExceededError
+	var duplicateErr *service.DuplicateSubscriptionError
+
+	switch {
+	case errors.As(err, &validationErr):
+		// Без ключа перевести можно только обёртку, детали остаются исходными.
+		detail := validationErr.Err.Error()
+		if tmpl, ok := catalog[validationErr.Key]; ok {
+			detail = fmt.Sprintf(tmpl, validationErr.Args...)
+		}
+		if validationErr.Field == "" {
+			return fmt.Sprintf(catalog["validation"], detail)
+		}
+		return fmt.Sprintf(catalog["validation_field"], validationErr.Field, detail)
+
+	case errors.As(err, &notFoundErr):
+		return fmt.Sprintf(catalog["not_found"], notFoundErr.ID)
+
+	case errors.As(err, &limitErr):
+		return fmt.Sprintf(catalog["limit_exceeded"], limitErr.UserID, limitErr.Limit)
+
+	case errors.As(err, &duplicateErr):
+		if duplicateErr.ExistingID == "" {
+			return fmt.Sprintf(catalog["duplicate"], duplicateErr.Policy)
+		}
+		return fmt.Sprintf(catalog["duplicate_existing"], duplicateErr.Policy, duplicateErr.ExistingID)
+
+	case errors.Is(err, sql.ErrNoRows):
+		return catalog["subscription_gone"]
+
+	case errors.Is(err, service.ErrNoUpdates):
+		return catalog["no_updates"]
+
+	case errors.Is(err, service.ErrDuplicateID):
+		return catalog["duplicate_id"]
+
+	case errors.Is(err, service.ErrVersionConflict):
+		return catalog["version_conflict"]
+
+	case errors.Is(err, service.ErrRetriesExhausted):
+		return catalog["retries_exhausted"]
+
+	case errors.Is(err, service.ErrReadOnly):
+		return catalog["read_only"]
+
+	default:
+		return message
+	}
+}
@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeSpec отдаёт сгенерированную спецификацию API файлом для скачивания,
+// независимо от Swagger UI - например, для генерации клиентов в CI.
+func ServeSpec(spec []byte, contentType, filename string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, contentType, spec)
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"subscription_service/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Размеры страниц для всех списочных эндпоинтов с offset-пагинацией: список
+// подписок (в том числе пользователя и POST /query) и /expiring. Не используют
+// parsePagination и envelope:
+//   - /changes - курсорная пагинация (since, after_id): offset сбивается, пока
+//     синхронизация догоняет изменения, а лимит до 1000 нужен для пропускной
+//     способности синхронизации;
+//   - /top - рейтинг: limit - это N, а не размер страницы, и total не имеет смысла;
+//   - /timeline - временной ряд, ограниченный периодом запроса (не больше
+//     AGGREGATE_MAX_YEARS лет помесячно); разбиение на страницы рвало бы график.
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// pagination - разобранные limit/offset/sort. Некорректные, но безопасные
+// значения (limit вне диапазона, неизвестная сортировка) заменяются значениями
+// по умолчанию с предупреждением в Warnings.
+type pagination struct {
+	Limit    int
+	Offset   int
+	Sort     *string
+	Warnings []string
+}
+
+// parsePagination разбирает limit, offset и sort из query; sort проверяется по
+// sortFields. Ошибка возвращается только для значений, которые нельзя разобрать
+// как число.
+func parsePagination(c *gin.Context, sortFields []string) (pagination, error) {
+	p := pagination{Limit: defaultPageSize}
+
+	if l := c.Query("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		switch {
+		case err != nil:
+			return p, errors.New("invalid limit parameter")
+		case parsed <= 0:
+			p.Warnings = append(p.Warnings, fmt.Sprintf("non-positive limit %d ignored, using default %d", parsed, defaultPageSize))
+		case parsed > maxPageSize:
+			p.Limit = maxPageSize
+			p.Warnings = append(p.Warnings, fmt.Sprintf("limit %d exceeds maximum, using %d", parsed, maxPageSize))
+		default:
+			p.Limit = parsed
+		}
+	}
+
+	if o := c.Query("offset"); o != "" {
+		parsed, err := strconv.Atoi(o)
+		switch {
+		case err != nil:
+			return p, errors.New("invalid offset parameter")
+		case parsed < 0:
+			p.Warnings = append(p.Warnings, fmt.Sprintf("negative offset %d ignored, using 0", parsed))
+		default:
+			p.Offset = parsed
+		}
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		if _, _, ok := model.ParseSortIn(sort, sortFields); ok {
+			p.Sort = &sort
+		} else {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("unknown sort %q ignored, using default", sort))
+		}
+	}
+
+	return p, nil
+}
+
+// envelope собирает ответ списочного эндпоинта: data, limit, offset, total,
+// total_pages и warnings (только если есть). extra добавляет специфичные для
+// эндпоинта поля.
+func (p pagination) envelope(data interface{}, total int, warnings []string, extra gin.H) gin.H {
+	totalPages := 0
+	if p.Limit > 0 {
+		totalPages = (total + p.Limit - 1) / p.Limit
+	}
+
+	resp := gin.H{
+		"data":        data,
+		"limit":       p.Limit,
+		"offset":      p.Offset,
+		"total":       total,
+		"total_pages": totalPages,
+	}
+	for k, v := range extra {
+		resp[k] = v
+	}
+	if all := append(append([]string{}, p.Warnings...), warnings...); len(all) > 0 {
+		resp["warnings"] = all
+	}
+	return resp
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parsePrefer разбирает заголовки Prefer (RFC 7240) в карту предпочтений:
+// "return=minimal, respond-async" -> {"return": "minimal", "respond-async": ""}.
+// Параметры после ";" игнорируются, имена приводятся к нижнему регистру; при
+// повторах действует первое значение.
+func parsePrefer(c *gin.Context) map[string]string {
+	prefs := make(map[string]string)
+	for _, header := range c.Request.Header.Values("Prefer") {
+		for _, item := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(item, ";")
+			name, value, _ := strings.Cut(token, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if _, seen := prefs[name]; !seen {
+				prefs[name] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return prefs
+}
+
+// applyPreference отмечает в Preference-Applied, что предпочтение учтено.
+func applyPreference(c *gin.Context, name, value string) {
+	applied := name
+	if value != "" {
+		applied += "=" + value
+	}
+	c.Writer.Header().Add("Preference-Applied", applied)
+}
+
+// wantsMinimal сообщает, что клиент просил Prefer: return=minimal, и отмечает
+// это в ответе. По умолчанию (return=representation) тело отдаётся полностью.
+func wantsMinimal(c *gin.Context) bool {
+	if parsePrefer(c)["return"] != "minimal" {
+		return false
+	}
+	applyPreference(c, "return", "minimal")
+	return true
+}
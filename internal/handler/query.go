@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"subscription_service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// QuerySubscriptions
+// @Summary Список подписок по структурированному запросу с выбором полей
+// @Description Фильтры те же, что и у GET /api/v1/subscriptions, но передаются в теле. fields - JSON-имена полей подписки, включая вычисляемые (next_billing_date, duration_months, months_remaining, status); пустой fields - все поля.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param query body model.SubscriptionQuery true "filter, fields, sort, page"
+// @Success 200 {object} map[string]interface{} "data (только запрошенные поля), limit, offset, total (по всем страницам), total_pages; warnings - при наличии"
+// @Failure 400 {object} map[string]interface{} "Неверный формат запроса, неизвестное поле или сортировка"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/query [post]
+func (h *SubscriptionHandler) QuerySubscriptions(c *gin.Context) {
+	var req model.SubscriptionQuery
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if req.Page.Limit == 0 {
+		req.Page.Limit = defaultPageSize
+	}
+
+	result, err := h.service.Query(c.Request.Context(), &req)
+	if err != nil {
+		logRequestError(err, nil, "Failed to query subscriptions")
+		h.respondError(c, err, "Failed to query subscriptions")
+		return
+	}
+
+	data, err := selectFields(result.Items, req.Fields)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to select subscription fields")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+
+	page := pagination{Limit: req.Page.Limit, Offset: req.Page.Offset}
+	h.respondJSON(c, http.StatusOK, page.envelope(data, result.Total, result.Warnings, nil))
+}
+
+// selectFields оставляет в JSON-представлении подписок только fields. Поля
+// берутся из сериализованной подписки, поэтому форматы (даты, null) совпадают
+// с остальными эндпоинтами.
+func selectFields(subs []*model.Subscription, fields []string) ([]map[string]interface{}, error) {
+	out := make([]map[string]interface{}, 0, len(subs))
+	for _, sub := range subs {
+		raw, err := json.Marshal(sub)
+		if err != nil {
+			return nil, err
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+
+		var full map[string]interface{}
+		if err := dec.Decode(&full); err != nil {
+			return nil, err
+		}
+		// Цена остаётся model.Money, чтобы respondJSON мог отдать её строкой.
+		full["price"] = sub.Price
+
+		if len(fields) == 0 {
+			out = append(out, full)
+			continue
+		}
+
+		selected := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			// end_date отсутствует у бессрочных подписок - отдаём null, а не пропуск.
+			selected[field] = full[field]
+		}
+		out = append(out, selected)
+	}
+	return out, nil
+}
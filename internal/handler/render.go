@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"subscription_service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// stringMoney - model.Money, которая сериализуется строкой.
+type stringMoney model.Money
+
+func (m stringMoney) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.Itoa(int(m)))), nil
+}
+
+// respondJSON отдаёт успешный ответ. Если клиент запросил строковые цены
+// (Accept: application/json; prices=string) или это включено в конфигурации,
+// суммы (model.Money) сериализуются строками, чтобы JS-клиенты не теряли
+// точность на значениях больше 2^53.
+func (h *SubscriptionHandler) respondJSON(c *gin.Context, status int, obj interface{}) {
+	renderJSON(c, h.stringPrices(c), status, obj)
+}
+
+// stringPrices сообщает, отдавать ли суммы в ответе на запрос строками.
+func (h *SubscriptionHandler) stringPrices(c *gin.Context) bool {
+	return h.opts.PricesAsStrings || acceptsStringPrices(c.GetHeader("Accept"))
+}
+
+// renderJSON - respondJSON для обработчиков без SubscriptionHandler.
+func renderJSON(c *gin.Context, stringPrices bool, status int, obj interface{}) {
+	if !stringPrices {
+		c.JSON(status, obj)
+		return
+	}
+
+	converted, err := pricesToStrings(obj)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encode prices as strings")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+
+	c.JSON(status, converted)
+}
+
+// acceptsStringPrices проверяет параметр prices=string в заголовке Accept.
+func acceptsStringPrices(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if (mediaType == "application/json" || mediaType == "*/*") && params["prices"] == "string" {
+			return true
+		}
+	}
+	return false
+}
+
+var moneyType = reflect.TypeOf(model.Money(0))
+
+// pricesToStrings возвращает значение с тем же JSON-представлением, что и obj,
+// но с суммами-строками. Суммы определяются по типу model.Money, а не по имени
+// поля, поэтому произвольные данные клиента (metadata) остаются как есть.
+func pricesToStrings(obj interface{}) (interface{}, error) {
+	return stringifyPrices(reflect.ValueOf(obj))
+}
+
+func stringifyPrices(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if !v.CanInterface() {
+		return nil, fmt.Errorf("cannot encode unexported field of type %s", v.Type())
+	}
+
+	t := v.Type()
+	if t == moneyType {
+		return stringMoney(v.Int()), nil
+	}
+	if !holdsMoney(t) {
+		return v.Interface(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return stringifyPrices(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			item, err := stringifyPrices(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := mapKey(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			item, err := stringifyPrices(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			out[key] = item
+		}
+		return out, nil
+	case reflect.Struct:
+		return stringifyStruct(v)
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// stringifyStruct сериализует структуру обычным образом (с её MarshalJSON, если
+// он есть) и заменяет поля, в которых могут быть суммы.
+func stringifyStruct(v reflect.Value) (interface{}, error) {
+	obj := v.Interface()
+	if v.CanAddr() {
+		// MarshalJSON с приёмником-указателем вызывается только для адресуемых значений.
+		obj = v.Addr().Interface()
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		// MarshalJSON отдал не объект - сумм в нём нет.
+		return json.RawMessage(raw), nil
+	}
+
+	out := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		out[name] = value
+	}
+	if err := overlayPrices(v, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// overlayPrices заменяет в out поля v, в которых могут быть суммы. Поля
+// встроенных структур без json-тега поднимаются на уровень v, как в encoding/json.
+func overlayPrices(v reflect.Value, out map[string]interface{}) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" && tag == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			if err := overlayPrices(v.Field(i), out); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" || !holdsMoney(field.Type) {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := out[name]; !ok {
+			continue
+		}
+
+		value, err := stringifyPrices(v.Field(i))
+		if err != nil {
+			return err
+		}
+		out[name] = value
+	}
+	return nil
+}
+
+// mapKey повторяет правила encoding/json для ключей объекта.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	return fmt.Sprint(k.Interface()), nil
+}
+
+// moneyTypes кэширует holdsMoney по типам.
+var moneyTypes sync.Map
+
+// holdsMoney сообщает, может ли значение типа t содержать model.Money. Для
+// интерфейсов ответ известен только по значению, поэтому они считаются
+// содержащими суммы.
+func holdsMoney(t reflect.Type) bool {
+	if cached, ok := moneyTypes.Load(t); ok {
+		return cached.(bool)
+	}
+	result := typeHoldsMoney(t, map[reflect.Type]bool{})
+	moneyTypes.Store(t, result)
+	return result
+}
+
+func typeHoldsMoney(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if t == moneyType {
+		return true
+	}
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return typeHoldsMoney(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if (field.PkgPath == "" || field.Anonymous) && typeHoldsMoney(field.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// schemaMaxAge - сколько клиенты и прокси могут кэшировать схему: она меняется
+// только с новой версией сервиса или конфигурации.
+const schemaMaxAge = "public, max-age=3600"
+
+// GetSubscriptionSchema
+// @Summary Фильтры, сортировки и перечислимые значения списка подписок
+// @Description Собирается из тех же списков, которыми сервис проверяет запросы (в том числе LIST_SORT_FIELDS), чтобы UI строил фильтры без расхождений с документацией. Ответ кэшируемый: Cache-Control и ETag, на совпавший If-None-Match - 304.
+// @Tags subscriptions
+// @Produce json
+// @Param If-None-Match header string false "ETag из предыдущего ответа"
+// @Success 200 {object} model.SubscriptionSchema
+// @Success 304 "Схема не изменилась"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/schema [get]
+func (h *SubscriptionHandler) GetSubscriptionSchema(c *gin.Context) {
+	body, err := json.Marshal(h.service.Schema())
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encode subscription schema")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode subscription schema"})
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	c.Header("Cache-Control", schemaMaxAge)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
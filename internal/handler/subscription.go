@@ -1,11 +1,13 @@
 package handler
 
 import (
-	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"subscription_service/internal/middleware"
 	"subscription_service/internal/model"
 	"subscription_service/internal/service"
 
@@ -13,12 +15,31 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// unchangedHeader отмечает ответ PUT, который ничего не изменил (SKIP_NOOP_UPDATES).
+const unchangedHeader = "X-Unchanged"
+
+type Options struct {
+	// UnprocessableValidation отдаёт 422 вместо 400 на семантические ошибки валидации.
+	UnprocessableValidation bool
+	// PricesAsStrings всегда отдаёт суммы строками (см. respondJSON).
+	PricesAsStrings bool
+	// AdminAPIKey разрешает override_limit при создании (пустой - override недоступен).
+	AdminAPIKey string
+	// LocalizeErrors переводит сообщения об ошибках на язык из Accept-Language
+	// (en по умолчанию, ru).
+	LocalizeErrors bool
+	// PriceMinorUnits - price хранится в минимальных единицах валюты; учитывается
+	// при форматировании price_display.
+	PriceMinorUnits bool
+}
+
 type SubscriptionHandler struct {
 	service service.SubscriptionService
+	opts    Options
 }
 
-func NewSubscriptionHandler(service service.SubscriptionService) *SubscriptionHandler {
-	return &SubscriptionHandler{service: service}
+func NewSubscriptionHandler(service service.SubscriptionService, opts Options) *SubscriptionHandler {
+	return &SubscriptionHandler{service: service, opts: opts}
 }
 
 // CreateSubscription
@@ -26,70 +47,126 @@ func NewSubscriptionHandler(service service.SubscriptionService) *SubscriptionHa
 // @Tags subscriptions
 // @Accept json
 // @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
 // @Param subscription body model.CreateSubscriptionRequest true "Данные подписки"
+// @Param if_not_exists query bool false "Не создавать дубликат: вернуть существующую подписку с тем же user_id, service_name и start_date"
+// @Param allow_warnings query bool false "Создать подписку несмотря на предупреждения (высокая цена, давняя start_date); они вернутся в поле warnings"
+// @Param override_limit query bool false "Игнорировать лимит активных подписок пользователя (только с X-API-Key администратора)"
+// @Param X-API-Key header string false "Admin API key, нужен для override_limit"
+// @Param Prefer header string false "return=minimal - ответить без тела, только с заголовком Location (RFC 7240)"
+// @Success 200 {object} model.Subscription "Подписка уже существует (if_not_exists=true)"
 // @Success 201 {object} model.Subscription
 // @Failure 400 {object} map[string]interface{} "Неверный формат запроса"
+// @Failure 403 {object} map[string]interface{} "override_limit без ключа администратора"
+// @Failure 409 {object} map[string]interface{} "Достигнут лимит активных подписок пользователя, подписка с таким id уже есть или нарушена политика UNIQUE_POLICY"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true) или предупреждения без allow_warnings"
 // @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Failure 503 {object} map[string]interface{} "БД временно только для чтения или конкурентные изменения не разрешились повторами; повторите через Retry-After секунд"
 // @Router /api/v1/subscriptions [post]
 func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
 	var req model.CreateSubscriptionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		logrus.WithError(err).Warn("Invalid request body")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+	if !h.bindJSON(c, &req) {
 		return
 	}
 
-	sub, err := h.service.Create(&req)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to create subscription")
+	ifNotExists := false
+	if v := c.Query("if_not_exists"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.WithField("if_not_exists", v).Warn("Invalid if_not_exists parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid if_not_exists parameter"})
+			return
+		}
+		ifNotExists = parsed
+	}
+
+	if v := c.Query("allow_warnings"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.WithField("allow_warnings", v).Warn("Invalid allow_warnings parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid allow_warnings parameter"})
+			return
+		}
+		req.AllowWarnings = parsed
+	}
 
-		var validationErr *service.ValidationError
-		if errors.As(err, &validationErr) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+	if v := c.Query("override_limit"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.WithField("override_limit", v).Warn("Invalid override_limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid override_limit parameter"})
 			return
 		}
+		if parsed && !middleware.HasAPIKey(c, h.opts.AdminAPIKey) {
+			logrus.WithField("client_ip", c.ClientIP()).Warn("override_limit without admin API key")
+			c.JSON(http.StatusForbidden, gin.H{"error": "override_limit requires admin API key"})
+			return
+		}
+		req.OverrideLimit = parsed
+	}
+
+	var sub *model.Subscription
+	var err error
+	created := true
+	if ifNotExists {
+		sub, created, err = h.service.CreateIfNotExists(c.Request.Context(), &req)
+	} else {
+		sub, err = h.service.Create(c.Request.Context(), &req)
+	}
+	if err != nil {
+		logRequestError(err, nil, "Failed to create subscription")
+		h.respondError(c, err, "Failed to create subscription")
+		return
+	}
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subscription"})
+	setETag(c, sub)
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+sub.ID.String())
+	if wantsMinimal(c) {
+		c.Status(status)
 		return
 	}
 
-	c.JSON(http.StatusCreated, sub)
+	h.respondJSON(c, status, sub)
 }
 
 // GetSubscription
 // @Summary Получить подписку по ID
 // @Tags subscriptions
 // @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param Accept-Language header string false "Локаль для price_display (en, ru, de, fr)"
 // @Param id path string true "UUID подписки"
+// @Param format query string false "display - добавить price_display, отформатированную по валюте и Accept-Language"
 // @Success 200 {object} model.Subscription
-// @Failure 400 {object} map[string]interface{} "Неверный формат ID"
+// @Failure 400 {object} map[string]interface{} "Неверный формат ID или параметра format"
 // @Failure 404 {object} map[string]interface{} "Подписка не найдена"
 // @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
 // @Router /api/v1/subscriptions/{id} [get]
 func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
 	id := c.Param("id")
 
-	sub, err := h.service.GetByID(id)
-	if err != nil {
-		logrus.WithError(err).WithField("id", id).Error("Failed to get subscription")
-
-		var validationErr *service.ValidationError
-		if errors.As(err, &validationErr) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
-			return
-		}
-
-		var notFoundErr *service.NotFoundError
-		if errors.As(err, &notFoundErr) {
-			c.JSON(http.StatusNotFound, gin.H{"error": notFoundErr.Error()})
-			return
-		}
+	display, ok := parseDisplayFormat(c)
+	if !ok {
+		return
+	}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription"})
+	sub, err := h.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		logRequestError(err, logrus.Fields{"id": id}, "Failed to get subscription")
+		h.respondError(c, err, "Failed to get subscription")
 		return
 	}
 
-	c.JSON(http.StatusOK, sub)
+	setETag(c, sub)
+	if display {
+		h.setPriceDisplay(c, sub)
+	}
+	h.respondJSON(c, http.StatusOK, sub)
 }
 
 // UpdateSubscription
@@ -97,214 +174,842 @@ func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
 // @Tags subscriptions
 // @Accept json
 // @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
 // @Param id path string true "UUID подписки"
+// @Param If-Match header string true "ETag из GET; * - без проверки версии"
 // @Param subscription body model.UpdateSubscriptionRequest true "Данные для обновления"
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} model.Subscription "При SKIP_NOOP_UPDATES=true и отсутствии изменений - текущая подписка с заголовком X-Unchanged: true"
 // @Failure 400 {object} map[string]interface{} "Неверный формат запроса"
 // @Failure 404 {object} map[string]interface{} "Подписка не найдена"
+// @Failure 409 {object} map[string]interface{} "При смене user_id у нового владельца достигнут лимит активных подписок"
+// @Failure 412 {object} map[string]interface{} "Подписка изменилась, ETag не совпадает"
+// @Failure 428 {object} map[string]interface{} "Не передан If-Match"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
 // @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Failure 503 {object} map[string]interface{} "БД временно только для чтения; повторите через Retry-After секунд"
 // @Router /api/v1/subscriptions/{id} [put]
 func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
 	id := c.Param("id")
 
 	var req model.UpdateSubscriptionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		logrus.WithError(err).Warn("Invalid request body")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	expectedVersion, ok := requireIfMatch(c)
+	if !ok {
 		return
 	}
 
-	err := h.service.Update(id, &req)
+	sub, err := h.service.Update(c.Request.Context(), id, &req, expectedVersion)
+	if errors.Is(err, service.ErrNotModified) {
+		c.Header(unchangedHeader, "true")
+		err = nil
+	}
 	if err != nil {
-		logrus.WithError(err).WithField("id", id).Error("Failed to update subscription")
+		logRequestError(err, logrus.Fields{"id": id}, "Failed to update subscription")
+		h.respondError(c, err, "Failed to update subscription")
+		return
+	}
 
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
-			return
+	setETag(c, sub)
+	h.respondJSON(c, http.StatusOK, sub)
+}
 
-		case errors.Is(err, service.ErrNoUpdates):
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
-			return
+// ShiftSubscription
+// @Summary Сдвинуть даты подписки на N месяцев
+// @Description Сдвигает start_date и end_date (если задана) на одно и то же число месяцев; отрицательное значение сдвигает назад.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param id path string true "UUID подписки"
+// @Param request body model.ShiftSubscriptionRequest true "Количество месяцев"
+// @Success 200 {object} model.Subscription
+// @Failure 400 {object} map[string]interface{} "Неверный формат запроса"
+// @Failure 404 {object} map[string]interface{} "Подписка не найдена"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Failure 503 {object} map[string]interface{} "БД временно только для чтения или конкурентные изменения не разрешились повторами; повторите через Retry-After секунд"
+// @Router /api/v1/subscriptions/{id}/shift [post]
+func (h *SubscriptionHandler) ShiftSubscription(c *gin.Context) {
+	id := c.Param("id")
 
-		default:
-			var validationErr *service.ValidationError
-			if errors.As(err, &validationErr) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
-				return
-			}
+	var req model.ShiftSubscriptionRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	sub, err := h.service.Shift(c.Request.Context(), id, *req.Months)
+	if err != nil {
+		logRequestError(err, logrus.Fields{"id": id}, "Failed to shift subscription")
+		h.respondError(c, err, "Failed to shift subscription")
+		return
+	}
 
-			var notFoundErr *service.NotFoundError
-			if errors.As(err, &notFoundErr) {
-				c.JSON(http.StatusNotFound, gin.H{"error": notFoundErr.Error()})
-				return
-			}
+	setETag(c, sub)
+	h.respondJSON(c, http.StatusOK, sub)
+}
 
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subscription"})
-			return
-		}
+// TransferSubscription
+// @Summary Передать подписку другому пользователю
+// @Description Меняет владельца в одной транзакции с записью в журнал передач (прежний и новый user_id) и, при включённом outbox, событием subscription.transferred. Лимит активных подписок проверяется у получателя.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param id path string true "UUID подписки"
+// @Param request body model.TransferSubscriptionRequest true "Новый владелец"
+// @Success 200 {object} model.Subscription
+// @Failure 400 {object} map[string]interface{} "Неверный формат запроса или подписка уже принадлежит этому пользователю"
+// @Failure 404 {object} map[string]interface{} "Подписка не найдена"
+// @Failure 409 {object} map[string]interface{} "У получателя достигнут лимит активных подписок"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Failure 503 {object} map[string]interface{} "БД временно только для чтения или конкурентные изменения не разрешились повторами; повторите через Retry-After секунд"
+// @Router /api/v1/subscriptions/{id}/transfer [post]
+func (h *SubscriptionHandler) TransferSubscription(c *gin.Context) {
+	id := c.Param("id")
+
+	var req model.TransferSubscriptionRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	sub, err := h.service.Transfer(c.Request.Context(), id, req.ToUserID)
+	if err != nil {
+		logRequestError(err, logrus.Fields{"id": id, "to_user_id": req.ToUserID}, "Failed to transfer subscription")
+		h.respondError(c, err, "Failed to transfer subscription")
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Subscription updated successfully",
-		"id":      id,
-	})
+	setETag(c, sub)
+	h.respondJSON(c, http.StatusOK, sub)
 }
 
 // DeleteSubscription
 // @Summary Удалить подписку
+// @Description Возвращает удалённую подписку целиком.
 // @Tags subscriptions
 // @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
 // @Param id path string true "UUID подписки"
-// @Success 200 {object} map[string]interface{}
+// @Param If-Match header string true "ETag из GET; * - без проверки версии"
+// @Success 200 {object} model.Subscription
 // @Failure 400 {object} map[string]interface{} "Неверный формат ID"
 // @Failure 404 {object} map[string]interface{} "Подписка не найдена"
+// @Failure 412 {object} map[string]interface{} "Подписка изменилась, ETag не совпадает"
+// @Failure 428 {object} map[string]interface{} "Не передан If-Match"
 // @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Failure 503 {object} map[string]interface{} "БД временно только для чтения; повторите через Retry-After секунд"
 // @Router /api/v1/subscriptions/{id} [delete]
 func (h *SubscriptionHandler) DeleteSubscription(c *gin.Context) {
 	id := c.Param("id")
 
-	err := h.service.Delete(id)
-	if err != nil {
-		logrus.WithError(err).WithField("id", id).Error("Failed to delete subscription")
-
-		var validationErr *service.ValidationError
-		if errors.As(err, &validationErr) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
-			return
-		}
-
-		var notFoundErr *service.NotFoundError
-		if errors.As(err, &notFoundErr) {
-			c.JSON(http.StatusNotFound, gin.H{"error": notFoundErr.Error()})
-			return
-		}
+	expectedVersion, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subscription"})
+	sub, err := h.service.Delete(c.Request.Context(), id, expectedVersion)
+	if err != nil {
+		logRequestError(err, logrus.Fields{"id": id}, "Failed to delete subscription")
+		h.respondError(c, err, "Failed to delete subscription")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Subscription deleted successfully",
-		"id":      id,
-	})
+	h.respondJSON(c, http.StatusOK, sub)
 }
 
 // ListSubscriptions
 // @Summary Список подписок с фильтрацией
+// @Description Взаимоисключающие параметры (400 с названием конфликта): period и created_from, period и created_to, view=ids и group, view=ids и format=display, view=ids и facets. Фильтр по metadata - параметры meta.<ключ>=<значение>, например meta.plan_code=premium: подписки, у которых metadata содержит это строковое значение.
 // @Tags subscriptions
 // @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param ids query []string false "Только подписки с указанными id; повтором параметра или через запятую" collectionFormat(multi)
 // @Param user_id query string false "Фильтр по ID пользователя"
-// @Param service_name query string false "Фильтр по названию сервиса"
-// @Param start_date query string false "Фильтр по дате начала (подписки, начавшиеся не раньше)"
-// @Param end_date query string false "Фильтр по дате начала (подписки, начавшиеся не позже)"
-// @Param limit query int false "Лимит записей (по умолчанию 10)"
+// @Param service_name query []string false "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую" collectionFormat(multi)
+// @Param exact query bool false "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)"
+// @Param start_date query string false "Фильтр по дате начала (подписки, начавшиеся не раньше). При DATE_PRECISION=month сравнивается с точностью до месяца"
+// @Param end_date query string false "Фильтр по дате начала (подписки, начавшиеся не позже). При DATE_PRECISION=month сравнивается с точностью до месяца"
+// @Param tag query string false "Фильтр по тегу"
+// @Param created_from query string false "Созданы не раньше (RFC3339)"
+// @Param created_to query string false "Созданы раньше (RFC3339)"
+// @Param open_ended query bool false "true - только бессрочные (без end_date), false - только с end_date"
+// @Param active_on query string false "Подписки, активные на дату (YYYY-MM-DD)"
+// @Param period query string false "Пресет по дате создания: today, week, month (в часовом поясе TIMEZONE)"
+// @Param limit query int false "Лимит записей (по умолчанию 10, максимум 100)"
 // @Param offset query int false "Смещение (по умолчанию 0)"
-// @Success 200 {object} map[string]interface{}
+// @Param sort query string false "Сортировка: поле, с '-' для убывания (например -start_date); по умолчанию LIST_DEFAULT_SORT"
+// @Param updated_after query string false "Изменены строго позже (RFC3339)"
+// @Param view query string false "full (по умолчанию) или ids - data содержит только [{id, updated_at}]"
+// @Param format query string false "display - добавить price_display, отформатированную по валюте и Accept-Language (несовместимо с view=ids)"
+// @Param facets query []string false "service_name, status - вернуть facets {поле: {значение: количество}} по всему фильтру без пагинации; status - active, upcoming или expired на сегодня" collectionFormat(multi)
+// @Param Accept-Language header string false "Локаль для price_display (en, ru, de, fr)"
+// @Param group query string false "service_name - вернуть groups [{service_name, total, subscriptions}] вместо data; группируется текущая страница"
+// @Param group_limit query int false "Максимум подписок в группе (по умолчанию 10, максимум 100)"
+// @Success 200 {object} map[string]interface{} "data, limit, offset, total (по всем страницам), total_pages; period, facets и warnings (некритичные проблемы запроса) - при наличии"
 // @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
 // @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
 // @Router /api/v1/subscriptions [get]
 func (h *SubscriptionHandler) ListSubscriptions(c *gin.Context) {
-	userID := c.Query("user_id")
-	serviceName := c.Query("service_name")
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
-
-	limit := 10
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
-			limit = parsed
-		} else if err != nil {
-			logrus.WithField("limit", l).Warn("Invalid limit parameter")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+	if conflict := checkExclusiveParams(c, listExclusiveParams); conflict != "" {
+		logrus.WithField("conflict", conflict).Warn("Conflicting query parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": conflict})
+		return
+	}
+
+	page, err := parsePagination(c, h.service.Schema().SortFields)
+	if err != nil {
+		logrus.WithError(err).Warn("Invalid pagination parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: " + err.Error()})
+		return
+	}
+
+	group := c.Query("group")
+	if group != "" && group != "service_name" {
+		logrus.WithField("group", group).Warn("Unsupported group parameter")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported group parameter, supported: service_name"})
+		return
+	}
+
+	view := c.DefaultQuery("view", "full")
+	if view != "full" && view != "ids" {
+		logrus.WithField("view", view).Warn("Unsupported view parameter")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported view parameter, supported: full, ids"})
+		return
+	}
+
+	display, ok := parseDisplayFormat(c)
+	if !ok {
+		return
+	}
+
+	groupLimit := defaultPageSize
+	if v := c.Query("group_limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > maxPageSize {
+			logrus.WithField("group_limit", v).Warn("Invalid group_limit parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid group_limit parameter, expected 1..%d", maxPageSize)})
+			return
+		}
+		groupLimit = parsed
+	}
+
+	req := listRequestFromQuery(c)
+	req.Limit = page.Limit
+	req.Offset = page.Offset
+	req.Sort = page.Sort
+
+	if view == "ids" {
+		h.listSubscriptionRefs(c, page, req)
+		return
+	}
+
+	result, err := h.service.List(c.Request.Context(), req)
+	if err != nil {
+		logRequestError(err, nil, "Failed to list subscriptions")
+		h.respondError(c, err, "Failed to list subscriptions")
+		return
+	}
+
+	subscriptions := result.Items
+	if subscriptions == nil {
+		subscriptions = []*model.Subscription{}
+	}
+	if display {
+		h.setPriceDisplay(c, subscriptions...)
+	}
+
+	extra := gin.H{}
+	if result.Period != nil {
+		extra["period"] = result.Period
+	}
+	if result.Facets != nil {
+		extra["facets"] = result.Facets
+	}
+
+	if group == "" {
+		h.respondJSON(c, http.StatusOK, page.envelope(subscriptions, result.Total, result.Warnings, extra))
+		return
+	}
+
+	resp := page.envelope(nil, result.Total, result.Warnings, extra)
+	delete(resp, "data")
+	resp["groups"] = groupByServiceName(subscriptions, groupLimit)
+	h.respondJSON(c, http.StatusOK, resp)
+}
+
+// listSubscriptionRefs отвечает на список в представлении view=ids.
+func (h *SubscriptionHandler) listSubscriptionRefs(c *gin.Context, page pagination, req *model.ListSubscriptionsRequest) {
+	result, err := h.service.ListRefs(c.Request.Context(), req)
+	if err != nil {
+		logRequestError(err, nil, "Failed to list subscription refs")
+		h.respondError(c, err, "Failed to list subscriptions")
+		return
+	}
+
+	extra := gin.H{}
+	if result.Period != nil {
+		extra["period"] = result.Period
+	}
+
+	h.respondJSON(c, http.StatusOK, page.envelope(result.Items, result.Total, nil, extra))
+}
+
+// groupByServiceName группирует страницу подписок по service_name в порядке
+// первого появления, сохраняя сортировку внутри групп. В каждой группе
+// остаётся не больше limit подписок.
+func groupByServiceName(subs []*model.Subscription, limit int) []model.SubscriptionGroup {
+	groups := []model.SubscriptionGroup{}
+	index := make(map[string]int)
+
+	for _, sub := range subs {
+		i, ok := index[sub.ServiceName]
+		if !ok {
+			i = len(groups)
+			index[sub.ServiceName] = i
+			groups = append(groups, model.SubscriptionGroup{
+				ServiceName:   sub.ServiceName,
+				Subscriptions: []*model.Subscription{},
+			})
+		}
+
+		groups[i].Total++
+		if len(groups[i].Subscriptions) < limit {
+			groups[i].Subscriptions = append(groups[i].Subscriptions, sub)
+		}
+	}
+
+	return groups
+}
+
+// ListUserSubscriptions
+// @Summary Подписки пользователя, опционально с общей месячной стоимостью
+// @Description Тот же список, что и /api/v1/subscriptions с user_id. При with_total=true добавляются total_monthly_cost и currency: сумма цен всех активных сегодня подписок пользователя (не только текущей страницы) в базовой валюте.
+// @Tags subscriptions
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param user_id path string true "UUID пользователя"
+// @Param with_total query bool false "Добавить total_monthly_cost"
+// @Param service_name query []string false "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую" collectionFormat(multi)
+// @Param exact query bool false "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)"
+// @Param active_on query string false "Подписки, активные на дату (YYYY-MM-DD)"
+// @Param limit query int false "Лимит записей (по умолчанию 10, максимум 100)"
+// @Param offset query int false "Смещение (по умолчанию 0)"
+// @Param sort query string false "Сортировка: поле, с '-' для убывания (например -start_date); по умолчанию LIST_DEFAULT_SORT"
+// @Success 200 {object} map[string]interface{} "data, limit, offset, total (по всем страницам), total_pages; total_monthly_cost и currency - при with_total=true"
+// @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{user_id}/subscriptions [get]
+func (h *SubscriptionHandler) ListUserSubscriptions(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	withTotal := false
+	if v := c.Query("with_total"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			logrus.WithField("with_total", v).Warn("Invalid with_total parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid with_total parameter"})
 			return
 		}
+		withTotal = parsed
+	}
+
+	page, err := parsePagination(c, h.service.Schema().SortFields)
+	if err != nil {
+		logrus.WithError(err).Warn("Invalid pagination parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: " + err.Error()})
+		return
+	}
+
+	req := listRequestFromQuery(c)
+	req.UserID = &userID
+	req.Limit = page.Limit
+	req.Offset = page.Offset
+	req.Sort = page.Sort
+
+	result, err := h.service.List(c.Request.Context(), req)
+	if err != nil {
+		logRequestError(err, logrus.Fields{"user_id": userID}, "Failed to list user subscriptions")
+		h.respondError(c, err, "Failed to list user subscriptions")
+		return
+	}
+
+	subscriptions := result.Items
+	if subscriptions == nil {
+		subscriptions = []*model.Subscription{}
 	}
 
-	offset := 0
-	if o := c.Query("offset"); o != "" {
-		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-			offset = parsed
-		} else if err != nil {
-			logrus.WithField("offset", o).Warn("Invalid offset parameter")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+	extra := gin.H{}
+	if withTotal {
+		cost, err := h.service.UserMonthlyCost(c.Request.Context(), userID)
+		if err != nil {
+			logRequestError(err, logrus.Fields{"user_id": userID}, "Failed to compute user monthly cost")
+			h.respondError(c, err, "Failed to compute user monthly cost")
 			return
 		}
+		extra["total_monthly_cost"] = cost.TotalPrice
+		extra["currency"] = cost.Currency
 	}
 
-	var userIDPtr, serviceNamePtr, startDatePtr, endDatePtr *string
-	if userID != "" {
-		userIDPtr = &userID
+	h.respondJSON(c, http.StatusOK, page.envelope(subscriptions, result.Total, result.Warnings, extra))
+}
+
+// queryParam - query-параметр в таблице конфликтов. Пустое Value означает
+// любое непустое значение.
+type queryParam struct {
+	Name  string
+	Value string
+}
+
+func (p queryParam) present(c *gin.Context) bool {
+	value := c.Query(p.Name)
+	if p.Value == "" {
+		return value != ""
+	}
+	return value == p.Value
+}
+
+func (p queryParam) String() string {
+	if p.Value == "" {
+		return p.Name
+	}
+	return p.Name + "=" + p.Value
+}
+
+// listExclusiveParams - пары query-параметров списка и выгрузки, которые нельзя
+// передавать вместе. Пары перечислены в @Description ListSubscriptions и
+// ExportSubscriptions.
+var listExclusiveParams = [][2]queryParam{
+	{{Name: "period"}, {Name: "created_from"}},
+	{{Name: "period"}, {Name: "created_to"}},
+	{{Name: "view", Value: "ids"}, {Name: "group"}},
+	{{Name: "view", Value: "ids"}, {Name: "format", Value: "display"}},
+	{{Name: "view", Value: "ids"}, {Name: "facets"}},
+}
+
+// checkExclusiveParams возвращает описание первого конфликта параметров или пустую строку.
+func checkExclusiveParams(c *gin.Context, pairs [][2]queryParam) string {
+	for _, pair := range pairs {
+		if pair[0].present(c) && pair[1].present(c) {
+			return fmt.Sprintf("Parameters '%s' and '%s' are mutually exclusive", pair[0], pair[1])
+		}
+	}
+	return ""
+}
+
+// listRequestFromQuery собирает фильтры списка подписок из query-параметров.
+func listRequestFromQuery(c *gin.Context) *model.ListSubscriptionsRequest {
+	return &model.ListSubscriptionsRequest{
+		IDs:          c.QueryArray("ids"),
+		UserID:       optionalQuery(c, "user_id"),
+		ServiceNames: c.QueryArray("service_name"),
+		Exact:        optionalQuery(c, "exact"),
+		StartDate:    optionalQuery(c, "start_date"),
+		EndDate:      optionalQuery(c, "end_date"),
+		Tag:          optionalQuery(c, "tag"),
+		CreatedFrom:  optionalQuery(c, "created_from"),
+		CreatedTo:    optionalQuery(c, "created_to"),
+		OpenEnded:    optionalQuery(c, "open_ended"),
+		ActiveOn:     optionalQuery(c, "active_on"),
+		UpdatedAfter: optionalQuery(c, "updated_after"),
+		Period:       optionalQuery(c, "period"),
+		Facets:       c.QueryArray("facets"),
+		Metadata:     metadataQuery(c),
+	}
+}
+
+// metadataQueryPrefix - префикс параметров фильтра по metadata: ?meta.plan_code=premium.
+const metadataQueryPrefix = "meta."
+
+// metadataQuery собирает фильтр по metadata из параметров meta.<ключ>=<значение>.
+func metadataQuery(c *gin.Context) map[string]string {
+	var metadata map[string]string
+	for key, values := range c.Request.URL.Query() {
+		if !strings.HasPrefix(key, metadataQueryPrefix) || len(values) == 0 {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[strings.TrimPrefix(key, metadataQueryPrefix)] = values[0]
+	}
+	return metadata
+}
+
+// PreviewUserDeletion
+// @Summary Предпросмотр удаления данных пользователя
+// @Description Подписки пользователя, которые затронет удаление его данных, и выручка по ним от первой start_date до сегодня в базовой валюте. Только чтение, данные не меняются.
+// @Tags subscriptions
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param user_id path string true "UUID пользователя"
+// @Success 200 {object} model.UserDeletionPreview
+// @Failure 400 {object} map[string]interface{} "Неверный формат user_id"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/users/{user_id}/deletion-preview [get]
+func (h *SubscriptionHandler) PreviewUserDeletion(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	preview, err := h.service.DeletionPreview(c.Request.Context(), userID)
+	if err != nil {
+		logRequestError(err, logrus.Fields{"user_id": userID}, "Failed to preview user deletion")
+		h.respondError(c, err, "Failed to preview user deletion")
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, preview)
+}
+
+// TopSubscriptions
+// @Summary Самые дорогие или самые долгие подписки
+// @Description Рейтинг, а не постраничный список: limit - число подписок в рейтинге, offset и total не поддерживаются.
+// @Description by=duration считает длительность от start_date до end_date, а для бессрочных и ещё идущих подписок - до сегодняшнего дня.
+// @Tags subscriptions
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param by query string false "Критерий: price (по умолчанию) или duration" Enums(price, duration)
+// @Param limit query int false "Количество подписок (по умолчанию 5, максимум 100)"
+// @Param user_id query string false "Фильтр по ID пользователя"
+// @Param service_name query []string false "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую" collectionFormat(multi)
+// @Param exact query bool false "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)"
+// @Success 200 {object} map[string]interface{} "data - подписки по убыванию критерия"
+// @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/top [get]
+func (h *SubscriptionHandler) TopSubscriptions(c *gin.Context) {
+	var req model.TopSubscriptionsRequest
+	if !h.bindQuery(c, &req) {
+		return
 	}
-	if serviceName != "" {
-		serviceNamePtr = &serviceName
+
+	subscriptions, err := h.service.Top(c.Request.Context(), &req)
+	if err != nil {
+		logRequestError(err, nil, "Failed to list top subscriptions")
+		h.respondError(c, err, "Failed to list top subscriptions")
+		return
 	}
-	if startDate != "" {
-		startDatePtr = &startDate
+
+	if subscriptions == nil {
+		subscriptions = []*model.Subscription{}
 	}
-	if endDate != "" {
-		endDatePtr = &endDate
+
+	h.respondJSON(c, http.StatusOK, gin.H{"data": subscriptions})
+}
+
+// ListSubscriptionChanges
+// @Summary Подписки, изменённые после момента времени (инкрементальная синхронизация)
+// @Description Подписки с updated_at после since по возрастанию (updated_at, id). Для следующей страницы передайте next_since и next_after_id из ответа; при пустой странице курсор возвращается без изменений. Пагинация курсорная, а не limit/offset, как у остальных списков: offset сбивается, пока клиент догоняет изменения. Удалённые подписки не возвращаются: удаление физическое, его видно только в событиях outbox.
+// @Tags subscriptions
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param since query string true "Момент времени в RFC3339, например 2025-01-01T00:00:00Z"
+// @Param after_id query string false "next_after_id из предыдущего ответа"
+// @Param limit query int false "Размер страницы (по умолчанию 100, максимум 1000)"
+// @Success 200 {object} model.ChangesPage
+// @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/changes [get]
+func (h *SubscriptionHandler) ListSubscriptionChanges(c *gin.Context) {
+	var req model.ChangesRequest
+	if !h.bindQuery(c, &req) {
+		return
 	}
 
-	subscriptions, err := h.service.List(userIDPtr, serviceNamePtr, startDatePtr, endDatePtr, limit, offset)
+	page, err := h.service.Changes(c.Request.Context(), &req)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to list subscriptions")
+		logRequestError(err, logrus.Fields{"since": req.Since}, "Failed to list subscription changes")
+		h.respondError(c, err, "Failed to list subscription changes")
+		return
+	}
+
+	if page.Data == nil {
+		page.Data = []*model.Subscription{}
+	}
+
+	h.respondJSON(c, http.StatusOK, page)
+}
 
-		var validationErr *service.ValidationError
-		if errors.As(err, &validationErr) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+// ListExpiringSubscriptions
+// @Summary Подписки, заканчивающиеся в текущем месяце или в ближайшие N месяцев
+// @Description Подписки по возрастанию end_date; from и to - границы окна (YYYY-MM-DD).
+// @Tags subscriptions
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param months query int false "Сколько следующих месяцев включить помимо текущего (по умолчанию 0)"
+// @Param limit query int false "Размер страницы (по умолчанию 10, максимум 100)"
+// @Param offset query int false "Смещение"
+// @Success 200 {object} map[string]interface{} "data, limit, offset, total (по всем страницам), total_pages, from, to; warnings - при наличии"
+// @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/expiring [get]
+func (h *SubscriptionHandler) ListExpiringSubscriptions(c *gin.Context) {
+	months := 0
+	if m := c.Query("months"); m != "" {
+		parsed, err := strconv.Atoi(m)
+		if err != nil {
+			logrus.WithField("months", m).Warn("Invalid months parameter")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid months parameter"})
 			return
 		}
+		months = parsed
+	}
+
+	// Порядок фиксирован (end_date, id), поэтому sort не поддерживается.
+	page, err := parsePagination(c, nil)
+	if err != nil {
+		logrus.WithError(err).Warn("Invalid pagination parameters")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: " + err.Error()})
+		return
+	}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list subscriptions"})
+	result, err := h.service.ListExpiring(c.Request.Context(), months, page.Limit, page.Offset)
+	if err != nil {
+		logRequestError(err, nil, "Failed to list expiring subscriptions")
+		h.respondError(c, err, "Failed to list expiring subscriptions")
 		return
 	}
 
+	subscriptions := result.Items
 	if subscriptions == nil {
 		subscriptions = []*model.Subscription{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":   subscriptions,
-		"limit":  limit,
-		"offset": offset,
-		"total":  len(subscriptions),
-	})
+	h.respondJSON(c, http.StatusOK, page.envelope(subscriptions, result.Total, nil, gin.H{
+		"from": result.From.Format(model.DateFormat),
+		"to":   result.To.Format(model.DateFormat),
+	}))
+}
+
+// optionalQuery возвращает значение query-параметра или nil, если он не задан.
+func optionalQuery(c *gin.Context, key string) *string {
+	if value := c.Query(key); value != "" {
+		return &value
+	}
+	return nil
 }
 
 // AggregateSubscriptions
 // @Summary Подсчет суммарной стоимости подписок за период
 // @Tags subscriptions
 // @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
 // @Param user_id query string false "Фильтр по ID пользователя"
-// @Param service_name query string false "Фильтр по названию сервиса"
+// @Param service_name query []string false "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую" collectionFormat(multi)
+// @Param exact query bool false "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)"
 // @Param start_date query string true "Начало периода (YYYY-MM-DD)"
 // @Param end_date query string true "Конец периода (YYYY-MM-DD)"
+// @Param currency query string false "Валюта результата (ISO 4217), суммы пересчитываются по курсам"
+// @Param group_by query string false "Разбивка результата: month или service_name,user_id (не больше AGGREGATE_MAX_GROUPS групп)"
+// @Param include_total query bool false "При group_by=month добавить общую сумму"
+// @Param granularity query string false "Шаг временного ряда buckets: month (по умолчанию), quarter, year; подразумевает group_by=month" Enums(month, quarter, year)
+// @Param format query string false "json (по умолчанию) или csv - при group_by отдать разбивку CSV-файлом" Enums(json, csv)
 // @Success 200 {object} model.AggregateResponse
+// @Success 200 {object} model.AggregateMonthlyResponse "При group_by=month или granularity"
+// @Success 200 {object} model.AggregateServiceUserResponse "При group_by=service_name,user_id"
+// @Success 200 {string} string "CSV с колонками группировки, total_price и currency при format=csv"
 // @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
 // @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
 // @Router /api/v1/subscriptions/aggregate [get]
 func (h *SubscriptionHandler) AggregateSubscriptions(c *gin.Context) {
 	var req model.AggregateRequest
-	if err := c.ShouldBindQuery(&req); err != nil {
-		logrus.WithError(err).Warn("Invalid query parameters")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: " + err.Error()})
+	if !h.bindQuery(c, &req) {
 		return
 	}
 
-	result, err := h.service.Aggregate(&req)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to aggregate subscriptions")
+	if req.GroupBy != nil && *req.GroupBy != model.GroupByMonth && *req.GroupBy != model.GroupByServiceUser {
+		logrus.WithField("group_by", *req.GroupBy).Warn("Unsupported group_by parameter")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported group_by parameter, supported: month, service_name,user_id"})
+		return
+	}
+
+	if req.Granularity != nil {
+		if req.GroupBy != nil && *req.GroupBy != model.GroupByMonth {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "granularity is only supported with group_by=month"})
+			return
+		}
+		groupBy := model.GroupByMonth
+		req.GroupBy = &groupBy
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		logrus.WithField("format", format).Warn("Unsupported aggregate format")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format, supported: json, csv"})
+		return
+	}
+	if format == "csv" && req.GroupBy == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format=csv requires group_by"})
+		return
+	}
+
+	if req.GroupBy != nil && *req.GroupBy == model.GroupByServiceUser {
+		result, err := h.service.AggregateMatrix(c.Request.Context(), &req)
+		if err != nil {
+			logRequestError(err, nil, "Failed to aggregate subscriptions by service and user")
+			h.respondError(c, err, "Failed to aggregate subscriptions")
+			return
+		}
+
+		if format == "csv" {
+			writeAggregateMatrixCSV(c, result)
+			return
+		}
+
+		h.respondJSON(c, http.StatusOK, result)
+		return
+	}
+
+	if req.GroupBy != nil {
+		result, err := h.service.AggregateMonthly(c.Request.Context(), &req)
+		if err != nil {
+			logRequestError(err, nil, "Failed to aggregate subscriptions by month")
+			h.respondError(c, err, "Failed to aggregate subscriptions")
+			return
+		}
 
-		var validationErr *service.ValidationError
-		if errors.As(err, &validationErr) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		if format == "csv" {
+			writeAggregateCSV(c, result)
 			return
 		}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate subscriptions"})
+		h.respondJSON(c, http.StatusOK, result)
+		return
+	}
+
+	result, err := h.service.Aggregate(c.Request.Context(), &req)
+	if err != nil {
+		logRequestError(err, nil, "Failed to aggregate subscriptions")
+		h.respondError(c, err, "Failed to aggregate subscriptions")
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, result)
+}
+
+// SimulatePriceChange
+// @Summary Оценить влияние изменения цены на выручку
+// @Description Считает итог за период по текущим ценам и по новой цене (new_price) или изменённой на percent_change процентов. Данные не изменяются.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param request body model.SimulatePriceChangeRequest true "Фильтр и гипотетическая цена"
+// @Success 200 {object} model.SimulatePriceChangeResponse
+// @Failure 400 {object} map[string]interface{} "Неверный формат запроса"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/simulate-price-change [post]
+func (h *SubscriptionHandler) SimulatePriceChange(c *gin.Context) {
+	var req model.SimulatePriceChangeRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.service.SimulatePriceChange(c.Request.Context(), &req)
+	if err != nil {
+		logRequestError(err, nil, "Failed to simulate price change")
+		h.respondError(c, err, "Failed to simulate price change")
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, result)
+}
+
+// CompareAggregateSubscriptions
+// @Summary Сравнение суммарной стоимости подписок за два периода
+// @Tags subscriptions
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param user_id query string false "Фильтр по ID пользователя"
+// @Param service_name query []string false "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую" collectionFormat(multi)
+// @Param exact query bool false "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)"
+// @Param period_a_start query string true "Начало периода A (YYYY-MM-DD)"
+// @Param period_a_end query string true "Конец периода A (YYYY-MM-DD)"
+// @Param period_b_start query string true "Начало периода B (YYYY-MM-DD)"
+// @Param period_b_end query string true "Конец периода B (YYYY-MM-DD)"
+// @Param currency query string false "Валюта результата (ISO 4217), суммы пересчитываются по курсам"
+// @Success 200 {object} model.CompareAggregateResponse
+// @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/aggregate/compare [get]
+func (h *SubscriptionHandler) CompareAggregateSubscriptions(c *gin.Context) {
+	var req model.CompareAggregateRequest
+	if !h.bindQuery(c, &req) {
+		return
+	}
+
+	result, err := h.service.CompareAggregate(c.Request.Context(), &req)
+	if err != nil {
+		logRequestError(err, nil, "Failed to compare aggregates")
+		h.respondError(c, err, "Failed to compare aggregates")
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, result)
+}
+
+// SubscriptionsTimeline
+// @Summary Количество активных подписок на конец каждого месяца
+// @Description Временной ряд по всем месяцам периода без пагинации: период ограничен AGGREGATE_MAX_YEARS.
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "Фильтр по ID пользователя"
+// @Param service_name query []string false "Фильтр по названию сервиса; несколько значений - повтором параметра или через запятую" collectionFormat(multi)
+// @Param exact query bool false "Точное совпадение service_name без учёта регистра (по умолчанию - поиск по подстроке)"
+// @Param start query string true "Начало периода (YYYY-MM-DD), учитывается месяц"
+// @Param end query string true "Конец периода (YYYY-MM-DD), учитывается месяц"
+// @Success 200 {array} model.TimelinePoint
+// @Failure 400 {object} map[string]interface{} "Неверные параметры запроса"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/timeline [get]
+func (h *SubscriptionHandler) SubscriptionsTimeline(c *gin.Context) {
+	var req model.TimelineRequest
+	if !h.bindQuery(c, &req) {
+		return
+	}
+
+	points, err := h.service.Timeline(c.Request.Context(), &req)
+	if err != nil {
+		logRequestError(err, nil, "Failed to build timeline")
+		h.respondError(c, err, "Failed to build timeline")
+		return
+	}
+
+	h.respondJSON(c, http.StatusOK, points)
+}
+
+// AggregateSubscriptionsBatch
+// @Summary Подсчет суммарной стоимости подписок за период для нескольких пользователей
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param Accept header string false "application/json; prices=string - отдать price и total_price строками"
+// @Param request body model.BatchAggregateRequest true "Список пользователей и период"
+// @Success 200 {object} model.BatchAggregateResponse
+// @Failure 400 {object} map[string]interface{} "Неверный формат запроса"
+// @Failure 422 {object} map[string]interface{} "Ошибка валидации данных (при VALIDATION_422=true)"
+// @Failure 500 {object} map[string]interface{} "Внутренняя ошибка сервера"
+// @Router /api/v1/subscriptions/aggregate/batch [post]
+func (h *SubscriptionHandler) AggregateSubscriptionsBatch(c *gin.Context) {
+	var req model.BatchAggregateRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.service.AggregateBatch(c.Request.Context(), &req)
+	if err != nil {
+		logRequestError(err, nil, "Failed to aggregate subscriptions batch")
+		h.respondError(c, err, "Failed to aggregate subscriptions")
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	h.respondJSON(c, http.StatusOK, result)
 }
@@ -0,0 +1,411 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription_service/internal/handler"
+	"subscription_service/internal/middleware"
+	"subscription_service/internal/mocks"
+	"subscription_service/internal/model"
+	"subscription_service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve выполняет запрос к единственному маршруту route с обработчиком,
+// построенным из svc и opts.
+func serve(svc service.SubscriptionService, opts handler.Options, method, route string, handle func(*handler.SubscriptionHandler) gin.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, route, handle(handler.NewSubscriptionHandler(svc, opts)))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetSubscription(t *testing.T) {
+	sub := mocks.NewSubscription(func(s *model.Subscription) { s.Version = 3 })
+
+	tests := []struct {
+		name       string
+		result     *model.Subscription
+		err        error
+		wantStatus int
+	}{
+		{"found", sub, nil, http.StatusOK},
+		{"not found", nil, &service.NotFoundError{ID: sub.ID.String()}, http.StatusNotFound},
+		{"invalid id", nil, &service.ValidationError{Field: "id", Err: errors.New("invalid UUID format")}, http.StatusBadRequest},
+		{"unexpected error", nil, errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestedID string
+			svc := &mocks.SubscriptionService{
+				GetByIDFn: func(_ context.Context, id string) (*model.Subscription, error) {
+					requestedID = id
+					return tt.result, tt.err
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions/"+sub.ID.String(), nil)
+			w := serve(svc, handler.Options{}, http.MethodGet, "/subscriptions/:id",
+				func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.GetSubscription }, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if requestedID != sub.ID.String() {
+				t.Errorf("service called with id %q, want %q", requestedID, sub.ID)
+			}
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON body %q: %v", w.Body, err)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				if _, ok := body["error"]; !ok {
+					t.Errorf("error response %v has no error field", body)
+				}
+				return
+			}
+
+			if body["id"] != sub.ID.String() || body["service_name"] != sub.ServiceName {
+				t.Errorf("body = %v, want subscription %s", body, sub.ID)
+			}
+			if w.Header().Get("ETag") == "" {
+				t.Error("ETag header is not set")
+			}
+		})
+	}
+}
+
+func TestSlowRequestTimesOut(t *testing.T) {
+	svc := &mocks.SubscriptionService{
+		// Как и запрос к БД, ждёт либо результата, либо отмены контекста запроса.
+		ListFn: func(ctx context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionPage, error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(5 * time.Second):
+				return &model.SubscriptionPage{}, nil
+			}
+		},
+	}
+
+	router := gin.New()
+	router.Use(middleware.Timeout(20 * time.Millisecond))
+	router.GET("/subscriptions", handler.NewSubscriptionHandler(svc, handler.Options{}).ListSubscriptions)
+
+	started := time.Now()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions", nil))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusGatewayTimeout, w.Body)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("request took %v, want it cut off by the timeout", elapsed)
+	}
+}
+
+func TestListSubscriptionRefsReportsTotal(t *testing.T) {
+	ref := model.SubscriptionRef{ID: mocks.NewSubscription().ID}
+	svc := &mocks.SubscriptionService{
+		ListRefsFn: func(_ context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionRefPage, error) {
+			return &model.SubscriptionRefPage{Items: []model.SubscriptionRef{ref, ref}, Total: 25}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions?view=ids&limit=2&offset=4", nil)
+	w := serve(svc, handler.Options{}, http.MethodGet, "/subscriptions",
+		func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ListSubscriptions }, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var body struct {
+		Data       []model.SubscriptionRef `json:"data"`
+		Total      int                     `json:"total"`
+		TotalPages int                     `json:"total_pages"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", w.Body, err)
+	}
+	if len(body.Data) != 2 || body.Total != 25 || body.TotalPages != 13 {
+		t.Errorf("got %d refs, total %d, total_pages %d; want 2, 25, 13", len(body.Data), body.Total, body.TotalPages)
+	}
+}
+
+func TestSimulatePriceChangeStringPrices(t *testing.T) {
+	svc := &mocks.SubscriptionService{
+		SimulatePriceChangeFn: func(_ context.Context, req *model.SimulatePriceChangeRequest) (*model.SimulatePriceChangeResponse, error) {
+			return &model.SimulatePriceChangeResponse{CurrentTotal: 9007199254740993, ProjectedTotal: 9007199254740995, Delta: 2}, nil
+		},
+	}
+
+	body := `{"service_name":"Yandex Plus","start_date":"2024-01-01","end_date":"2024-12-31","new_price":500}`
+	req := httptest.NewRequest(http.MethodPost, "/aggregate/simulate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json; prices=string")
+	w := serve(svc, handler.Options{}, http.MethodPost, "/aggregate/simulate",
+		func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.SimulatePriceChange }, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", w.Body, err)
+	}
+	want := map[string]string{
+		"current_total":   "9007199254740993",
+		"projected_total": "9007199254740995",
+		"delta":           "2",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %#v, want string %q", key, got[key], value)
+		}
+	}
+}
+
+func TestStringPricesKeepMetadata(t *testing.T) {
+	sub := mocks.NewSubscription(func(s *model.Subscription) {
+		s.Metadata = map[string]interface{}{"price": 5, "delta": 1}
+	})
+	svc := &mocks.SubscriptionService{
+		GetByIDFn: func(_ context.Context, id string) (*model.Subscription, error) { return sub, nil },
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/"+sub.ID.String(), nil)
+	w := serve(svc, handler.Options{PricesAsStrings: true}, http.MethodGet, "/subscriptions/:id",
+		func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.GetSubscription }, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var body struct {
+		Price     interface{}            `json:"price"`
+		StartDate string                 `json:"start_date"`
+		Metadata  map[string]interface{} `json:"metadata"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", w.Body, err)
+	}
+	if body.Price != "400" {
+		t.Errorf("price = %#v, want string \"400\"", body.Price)
+	}
+	if body.StartDate != sub.StartDate.Format(model.DateFormat) {
+		t.Errorf("start_date = %q, want the subscription date format", body.StartDate)
+	}
+	if body.Metadata["price"] != float64(5) || body.Metadata["delta"] != float64(1) {
+		t.Errorf("metadata = %v, want numbers left as they are", body.Metadata)
+	}
+}
+
+func TestBindingErrorStatus(t *testing.T) {
+	valid := `{"service_name":"Yandex Plus","price":400,"user_id":"60601fee-2bf1-4721-ae6f-7636e79a0cba","start_date":"2025-07-01"}`
+
+	tests := []struct {
+		name          string
+		body          string
+		unprocessable bool
+		wantStatus    int
+	}{
+		{"binding tag, 400 by default", strings.Replace(valid, `"price":400`, `"price":-1`, 1), false, http.StatusBadRequest},
+		{"binding tag, 422 when enabled", strings.Replace(valid, `"price":400`, `"price":-1`, 1), true, http.StatusUnprocessableEntity},
+		{"missing required field", strings.Replace(valid, `"service_name":"Yandex Plus",`, "", 1), true, http.StatusUnprocessableEntity},
+		{"malformed JSON stays 400", `{"service_name":`, true, http.StatusBadRequest},
+		{"wrong type stays 400", strings.Replace(valid, `"price":400`, `"price":"400"`, 1), true, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &mocks.SubscriptionService{}
+
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := serve(svc, handler.Options{UnprocessableValidation: tt.unprocessable}, http.MethodPost, "/subscriptions",
+				func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.CreateSubscription }, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestQueryBindingErrorStatus(t *testing.T) {
+	for _, tt := range []struct {
+		unprocessable bool
+		wantStatus    int
+	}{
+		{false, http.StatusBadRequest},
+		{true, http.StatusUnprocessableEntity},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions/changes", nil)
+		w := serve(&mocks.SubscriptionService{}, handler.Options{UnprocessableValidation: tt.unprocessable}, http.MethodGet, "/subscriptions/changes",
+			func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ListSubscriptionChanges }, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("VALIDATION_422=%t: status = %d, want %d: %s", tt.unprocessable, w.Code, tt.wantStatus, w.Body)
+		}
+	}
+}
+
+func TestExportSubscriptions(t *testing.T) {
+	sub := mocks.NewSubscription()
+
+	t.Run("period is applied like in list", func(t *testing.T) {
+		var got *model.ListSubscriptionsRequest
+		svc := &mocks.SubscriptionService{
+			ExportFn: func(_ context.Context, req *model.ListSubscriptionsRequest, fn func(*model.Subscription) error) error {
+				got = req
+				return nil
+			},
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions/export?period=month", nil)
+		w := serve(svc, handler.Options{}, http.MethodGet, "/subscriptions/export",
+			func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ExportSubscriptions }, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		if got == nil || got.Period == nil || *got.Period != "month" {
+			t.Errorf("export request period = %v, want month", got)
+		}
+	})
+
+	t.Run("string prices apply to rows", func(t *testing.T) {
+		svc := &mocks.SubscriptionService{
+			ExportFn: func(_ context.Context, req *model.ListSubscriptionsRequest, fn func(*model.Subscription) error) error {
+				return fn(sub)
+			},
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions/export", nil)
+		req.Header.Set("Accept", "application/json; prices=string")
+		w := serve(svc, handler.Options{}, http.MethodGet, "/subscriptions/export",
+			func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ExportSubscriptions }, req)
+
+		var row map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(w.Body.String())), &row); err != nil {
+			t.Fatalf("invalid row %q: %v", w.Body, err)
+		}
+		if row["price"] != "400" {
+			t.Errorf("price = %#v, want string \"400\"", row["price"])
+		}
+	})
+
+	t.Run("failure after the first row ends with an error line", func(t *testing.T) {
+		svc := &mocks.SubscriptionService{
+			ExportFn: func(_ context.Context, req *model.ListSubscriptionsRequest, fn func(*model.Subscription) error) error {
+				if err := fn(sub); err != nil {
+					return err
+				}
+				return errors.New("connection reset by peer")
+			},
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions/export", nil)
+		w := serve(svc, handler.Options{}, http.MethodGet, "/subscriptions/export",
+			func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ExportSubscriptions }, req)
+
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("got %d lines, want a row and an error line: %s", len(lines), w.Body)
+		}
+
+		var last map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[1]), &last); err != nil {
+			t.Fatalf("invalid last line %q: %v", lines[1], err)
+		}
+		message, _ := last["error"].(string)
+		if !strings.Contains(message, "after 1 rows") || strings.Contains(message, "connection reset") {
+			t.Errorf("error line = %q, want a generic message with the row count", message)
+		}
+	})
+}
+
+func TestExclusiveQueryParams(t *testing.T) {
+	tests := []struct {
+		route  string
+		query  string
+		handle func(*handler.SubscriptionHandler) gin.HandlerFunc
+		want   string
+	}{
+		{"/subscriptions", "period=week&created_from=2024-01-01T00:00:00Z", func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ListSubscriptions }, "'period' and 'created_from'"},
+		{"/subscriptions", "view=ids&group=service_name", func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ListSubscriptions }, "'view=ids' and 'group'"},
+		{"/subscriptions", "view=ids&format=display", func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ListSubscriptions }, "'view=ids' and 'format=display'"},
+		{"/subscriptions", "view=ids&facets=status", func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ListSubscriptions }, "'view=ids' and 'facets'"},
+		{"/subscriptions/export", "period=today&created_to=2024-01-01T00:00:00Z", func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ExportSubscriptions }, "'period' and 'created_to'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.route+"?"+tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.route+"?"+tt.query, nil)
+			w := serve(&mocks.SubscriptionService{}, handler.Options{}, http.MethodGet, tt.route, tt.handle, req)
+
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("got %d %s, want 400 naming %s", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestListExpiringSubscriptionsEnvelope(t *testing.T) {
+	var gotLimit, gotOffset int
+	svc := &mocks.SubscriptionService{
+		ListExpiringFn: func(_ context.Context, months, limit, offset int) (*model.ExpiringSubscriptions, error) {
+			gotLimit, gotOffset = limit, offset
+			return &model.ExpiringSubscriptions{
+				From:  time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+				To:    time.Date(2024, time.July, 31, 0, 0, 0, 0, time.UTC),
+				Items: []*model.Subscription{mocks.NewSubscription()},
+				Total: 7,
+			}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/expiring?months=1&limit=500&offset=6", nil)
+	w := serve(svc, handler.Options{}, http.MethodGet, "/subscriptions/expiring",
+		func(h *handler.SubscriptionHandler) gin.HandlerFunc { return h.ListExpiringSubscriptions }, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if gotLimit != 100 || gotOffset != 6 {
+		t.Errorf("page = %d/%d, want the clamped limit 100 and offset 6", gotLimit, gotOffset)
+	}
+
+	var body struct {
+		Data     []json.RawMessage `json:"data"`
+		Total    int               `json:"total"`
+		From     string            `json:"from"`
+		To       string            `json:"to"`
+		Warnings []string          `json:"warnings"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", w.Body, err)
+	}
+	if len(body.Data) != 1 || body.Total != 7 || body.From != "2024-06-01" || body.To != "2024-07-31" || len(body.Warnings) != 1 {
+		t.Errorf("unexpected envelope %s", w.Body)
+	}
+}
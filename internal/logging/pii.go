@@ -0,0 +1,72 @@
+// Package logging применяет политику логирования персональных данных ко всем
+// записям logrus через хук, чтобы не повторять её в каждом месте логирования.
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	PIIModeFull = "full"
+	PIIModeHash = "hash"
+	PIIModeOmit = "omit"
+)
+
+// PIIFields - поля логов, содержащие идентификаторы пользователей.
+var PIIFields = []string{"user_id", "user_ids", "from_user_id", "to_user_id", "client_ip", "recipients"}
+
+// ValidPIIMode сообщает, поддерживается ли режим.
+func ValidPIIMode(mode string) bool {
+	return mode == PIIModeFull || mode == PIIModeHash || mode == PIIModeOmit
+}
+
+// InstallPIIHook регистрирует хук, который хэширует или удаляет PIIFields
+// в каждой записи. В режиме full хук не ставится.
+func InstallPIIHook(mode string) {
+	if mode == PIIModeFull {
+		return
+	}
+
+	fields := make(map[string]bool, len(PIIFields))
+	for _, f := range PIIFields {
+		fields[f] = true
+	}
+
+	logrus.AddHook(&piiHook{mode: mode, fields: fields})
+}
+
+type piiHook struct {
+	mode   string
+	fields map[string]bool
+}
+
+func (h *piiHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *piiHook) Fire(entry *logrus.Entry) error {
+	for key, value := range entry.Data {
+		if !h.fields[key] {
+			continue
+		}
+
+		if h.mode == PIIModeOmit {
+			delete(entry.Data, key)
+			continue
+		}
+		entry.Data[key] = Hash(value)
+	}
+
+	return nil
+}
+
+// Hash возвращает стабильный короткий хэш значения, по которому записи
+// одного пользователя можно сопоставить, не раскрывая сам идентификатор.
+func Hash(value interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprint(value)))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
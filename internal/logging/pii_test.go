@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestPIIHook(t *testing.T) {
+	data := func() logrus.Fields {
+		return logrus.Fields{
+			"id":           "a6b1c0de-0000-4000-8000-000000000001",
+			"user_id":      "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+			"from_user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+			"to_user_id":   "7d6e0b1a-9b8f-4b3c-9a6e-2f1d3c4b5a69",
+			"client_ip":    "203.0.113.7",
+		}
+	}
+	pii := []string{"user_id", "from_user_id", "to_user_id", "client_ip"}
+
+	tests := []struct {
+		mode  string
+		check func(t *testing.T, key string, original, got interface{}, ok bool)
+	}{
+		{PIIModeHash, func(t *testing.T, key string, original, got interface{}, ok bool) {
+			if !ok || got != Hash(original) {
+				t.Errorf("%s = %v, want %s", key, got, Hash(original))
+			}
+		}},
+		{PIIModeOmit, func(t *testing.T, key string, _, got interface{}, ok bool) {
+			if ok {
+				t.Errorf("%s = %v, want omitted", key, got)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			fields := make(map[string]bool, len(PIIFields))
+			for _, f := range PIIFields {
+				fields[f] = true
+			}
+			hook := &piiHook{mode: tt.mode, fields: fields}
+
+			original := data()
+			entry := &logrus.Entry{Data: data()}
+			if err := hook.Fire(entry); err != nil {
+				t.Fatalf("Fire: %v", err)
+			}
+
+			for _, key := range pii {
+				got, ok := entry.Data[key]
+				tt.check(t, key, original[key], got, ok)
+			}
+			if entry.Data["id"] != original["id"] {
+				t.Errorf("id = %v, want it unchanged", entry.Data["id"])
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLog пишет по одной структурированной записи logrus на запрос.
+// Успешные (2xx) запросы логируются с вероятностью successSampleRate (0..1),
+// остальные - всегда. path - шаблон маршрута (/api/v1/users/:user_id/...),
+// а не фактический путь: в пути бывают идентификаторы пользователей, которые
+// PII-хук не видит. Для запросов без маршрута path пустой.
+func AccessLog(successSampleRate float64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		started := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= 200 && status < 300 && successSampleRate < 1 && rand.Float64() >= successSampleRate {
+			return
+		}
+
+		entry := logrus.WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.FullPath(),
+			"status":     status,
+			"latency_ms": time.Since(started).Milliseconds(),
+			"bytes":      c.Writer.Size(),
+			"client_ip":  c.ClientIP(),
+			"request_id": c.GetString(RequestIDKey),
+			"user_agent": c.Request.UserAgent(),
+		})
+
+		switch {
+		case status >= 500:
+			entry.Error("HTTP request")
+		case status >= 400:
+			entry.Warn("HTTP request")
+		default:
+			entry.Info("HTTP request")
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestAccessLogRecordsRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hook := test.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+
+	router := gin.New()
+	router.Use(AccessLog(1))
+	router.GET("/api/v1/users/:user_id/subscriptions", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	userID := "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/users/"+userID+"/subscriptions", nil))
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("no access log entry")
+	}
+	if got := entry.Data["path"]; got != "/api/v1/users/:user_id/subscriptions" {
+		t.Errorf("path = %v, want the route template", got)
+	}
+	for key, value := range entry.Data {
+		if s, ok := value.(string); ok && s != "" && strings.Contains(s, userID) {
+			t.Errorf("%s = %q leaks the user id", key, s)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// HasAPIKey сообщает, передан ли в запросе заголовок X-API-Key, совпадающий с apiKey.
+// Пустой apiKey не совпадает ни с чем.
+func HasAPIKey(c *gin.Context, apiKey string) bool {
+	provided := c.GetHeader(APIKeyHeader)
+	return apiKey != "" && provided != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) == 1
+}
+
+// APIKeyAuth пропускает только запросы с заголовком X-API-Key, совпадающим с apiKey.
+func APIKeyAuth(apiKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasAPIKey(c, apiKey) {
+			logrus.WithFields(logrus.Fields{
+				"path":      c.Request.URL.Path,
+				"client_ip": c.ClientIP(),
+			}).Warn("Unauthorized admin request")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing API key"})
+			return
+		}
+
+		c.Next()
+	}
+}
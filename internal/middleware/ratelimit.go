@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"subscription_service/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	clients map[string]*rateWindow
+	// nextSweep - когда в следующий раз удалять окна неактивных клиентов.
+	nextSweep time.Time
+}
+
+// allow учитывает запрос клиента и возвращает время до сброса окна,
+// если лимит уже исчерпан.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.After(l.nextSweep) {
+		l.sweep(now)
+	}
+
+	w, ok := l.clients[client]
+	if !ok || now.Sub(w.start) >= l.window {
+		l.clients[client] = &rateWindow{start: now, count: 1}
+		return true, 0
+	}
+
+	if w.count >= l.limit {
+		return false, l.window - now.Sub(w.start)
+	}
+
+	w.count++
+	return true, 0
+}
+
+// sweep удаляет окна, истёкшие больше window назад: такой клиент всё равно
+// начнёт новое окно, а без очистки карта растёт с каждым новым IP.
+// Вызывается под l.mu не чаще раза в window.
+func (l *rateLimiter) sweep(now time.Time) {
+	for client, w := range l.clients {
+		if now.Sub(w.start) >= l.window {
+			delete(l.clients, client)
+		}
+	}
+	l.nextSweep = now.Add(l.window)
+}
+
+// sharedAllow учитывает запрос в общем для реплик окне: счётчик в shared
+// живёт window с первого запроса клиента. ok = false, если shared не задан или
+// недоступен и решение должен принять локальный лимитер.
+func sharedAllow(ctx context.Context, shared store.Store, key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, ok bool) {
+	if shared == nil {
+		return false, 0, false
+	}
+
+	count, ttl, err := shared.Incr(ctx, key, window)
+	if err != nil {
+		return false, 0, false
+	}
+	if count > int64(limit) {
+		return false, ttl, true
+	}
+	return true, 0, true
+}
+
+// RateLimit ограничивает число запросов с одного IP до limit за окно window.
+// С shared окна общие для всех реплик и различаются по scope; если shared нет
+// или он недоступен, запросы считаются в памяти процесса.
+func RateLimit(scope string, limit int, window time.Duration, shared store.Store) gin.HandlerFunc {
+	limiter := &rateLimiter{
+		limit:   limit,
+		window:  window,
+		clients: make(map[string]*rateWindow),
+	}
+
+	return func(c *gin.Context) {
+		allowed, retryAfter, ok := sharedAllow(c.Request.Context(), shared, "ratelimit:"+scope+":"+c.ClientIP(), limit, window)
+		if !ok {
+			allowed, retryAfter = limiter.allow(c.ClientIP(), time.Now())
+		}
+
+		if !allowed {
+			logrus.WithFields(logrus.Fields{
+				"path":      c.Request.URL.Path,
+				"client_ip": c.ClientIP(),
+			}).Warn("Rate limit exceeded")
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"subscription_service/internal/store"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	l := &rateLimiter{limit: 2, window: time.Minute, clients: make(map[string]*rateWindow)}
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("203.0.113.7", now); !ok {
+			t.Fatalf("request %d rejected within limit", i+1)
+		}
+	}
+
+	ok, retryAfter := l.allow("203.0.113.7", now.Add(20*time.Second))
+	if ok || retryAfter != 40*time.Second {
+		t.Errorf("allow over limit = %t, %v; want false, 40s", ok, retryAfter)
+	}
+
+	if ok, _ := l.allow("203.0.113.7", now.Add(time.Minute)); !ok {
+		t.Error("request rejected after the window reset")
+	}
+}
+
+func TestRateLimiterEvictsIdleClients(t *testing.T) {
+	l := &rateLimiter{limit: 10, window: time.Minute, clients: make(map[string]*rateWindow)}
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 100; i++ {
+		l.allow("198.51.100."+strconv.Itoa(i), now)
+	}
+	l.allow("203.0.113.7", now.Add(30*time.Second))
+
+	if len(l.clients) != 101 {
+		t.Fatalf("tracking %d clients, want 101", len(l.clients))
+	}
+
+	l.allow("203.0.113.8", now.Add(80*time.Second))
+
+	if len(l.clients) != 2 {
+		t.Errorf("tracking %d clients after idle sweep, want 2 (active and new)", len(l.clients))
+	}
+	if _, ok := l.clients["203.0.113.7"]; !ok {
+		t.Error("client with an active window was evicted")
+	}
+}
+
+// rateLimitedRouter - реплика сервиса с лимитом 2 запроса в минуту.
+func rateLimitedRouter(shared store.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RateLimit("admin", 2, time.Minute, shared))
+	router.GET("/admin", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func serve(router *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	return w
+}
+
+func TestRateLimitSharesWindowAcrossReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	shared, err := store.NewRedis("redis://" + server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+	defer shared.Close()
+
+	first, second := rateLimitedRouter(shared), rateLimitedRouter(shared)
+
+	if w := serve(first); w.Code != http.StatusOK {
+		t.Fatalf("first replica: status %d, want 200", w.Code)
+	}
+	if w := serve(second); w.Code != http.StatusOK {
+		t.Fatalf("second replica: status %d, want 200", w.Code)
+	}
+
+	w := serve(first)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status %d, want 429 across replicas", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+}
+
+func TestRateLimitFallsBackToLocalWindowWhenRedisIsDown(t *testing.T) {
+	server := miniredis.RunT(t)
+	shared, err := store.NewRedis("redis://" + server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+	defer shared.Close()
+	server.Close()
+
+	router := rateLimitedRouter(shared)
+	for i := 0; i < 2; i++ {
+		if w := serve(router); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200 while Redis is down", i+1, w.Code)
+		}
+	}
+	if w := serve(router); w.Code != http.StatusTooManyRequests {
+		t.Errorf("third request: status %d, want 429 from the local window", w.Code)
+	}
+}
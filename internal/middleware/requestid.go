@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey - ключ, под которым id запроса лежит в gin.Context.
+	RequestIDKey = "request_id"
+)
+
+// RequestID берёт id запроса из заголовка X-Request-ID или генерирует новый
+// и возвращает его клиенту в том же заголовке.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = uuid.NewString()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Timeout ограничивает время обработки запроса: контекст запроса отменяется
+// по истечении timeout. Запросы к БД выполняются с этим контекстом и
+// прерываются, а обработчик отвечает 504 по ошибке context.DeadlineExceeded;
+// если он так ничего и не ответил, 504 отдаёт сам middleware. Маршруты из skip (по c.FullPath(), например потоковый экспорт)
+// выполняются без ограничения. timeout <= 0 отключает middleware.
+func Timeout(timeout time.Duration, skip ...string) gin.HandlerFunc {
+	skipped := make(map[string]bool, len(skip))
+	for _, path := range skip {
+		skipped[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if timeout <= 0 || skipped[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			logrus.WithFields(logrus.Fields{
+				"path":    c.FullPath(),
+				"timeout": timeout.String(),
+			}).Warn("Request timed out")
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+		}
+	}
+}
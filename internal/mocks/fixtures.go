@@ -0,0 +1,33 @@
+package mocks
+
+import (
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// NewSubscription возвращает заполненную подписку с фиксированными датами;
+// нужные поля переопределяются через opts.
+func NewSubscription(opts ...func(*model.Subscription)) *model.Subscription {
+	created := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	sub := &model.Subscription{
+		ID:          uuid.New(),
+		ServiceName: "Yandex Plus",
+		Price:       400,
+		Currency:    "RUB",
+		Tags:        []string{},
+		UserID:      uuid.New(),
+		StartDate:   time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt:   created,
+		UpdatedAt:   created,
+	}
+
+	for _, opt := range opts {
+		opt(sub)
+	}
+
+	return sub
+}
@@ -0,0 +1,15 @@
+// Package mocks содержит ручные моки репозитория и сервиса подписок для
+// тестов без базы данных. Каждый метод делегирует соответствующему полю *Fn;
+// если поле не задано, метод возвращает ErrNotConfigured.
+package mocks
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNotConfigured = errors.New("mock method is not configured")
+
+func notConfigured(method string) error {
+	return fmt.Errorf("%s: %w", method, ErrNotConfigured)
+}
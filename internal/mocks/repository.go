@@ -0,0 +1,192 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var _ repository.SubscriptionRepository = (*SubscriptionRepository)(nil)
+
+type SubscriptionRepository struct {
+	CreateFn              func(ctx context.Context, sub *model.Subscription, limit repository.UserLimit) error
+	GetByIDFn             func(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	CreateIfNotExistsFn   func(ctx context.Context, sub *model.Subscription, limit repository.UserLimit, check func() error) (*model.Subscription, bool, error)
+	FindDuplicateFn       func(ctx context.Context, sub *model.Subscription, policy string) (*model.Subscription, error)
+	MonthlyCostByUserFn   func(ctx context.Context, userID uuid.UUID, on time.Time) (map[string]int, error)
+	UpdateFn              func(ctx context.Context, id uuid.UUID, updates map[string]interface{}, expectedVersion *int, skipUnchanged bool, limit repository.UserLimit) (*model.Subscription, error)
+	DeleteFn              func(ctx context.Context, id uuid.UUID, expectedVersion *int) (*model.Subscription, error)
+	TransferFn            func(ctx context.Context, id, toUserID uuid.UUID, limit repository.UserLimit, check func(current *model.Subscription) error) (*model.Subscription, error)
+	ListFn                func(ctx context.Context, filter model.SubscriptionFilter) ([]*model.Subscription, int, int, error)
+	ListRefsFn            func(ctx context.Context, filter model.SubscriptionFilter) ([]model.SubscriptionRef, int, error)
+	FacetFn               func(ctx context.Context, filter model.SubscriptionFilter, field string, today time.Time) (map[string]int, error)
+	TopFn                 func(ctx context.Context, by string, limit int, today time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]*model.Subscription, error)
+	ChangesFn             func(ctx context.Context, since time.Time, afterID *uuid.UUID, limit int) ([]*model.Subscription, error)
+	ListExpiringFn        func(ctx context.Context, from, to time.Time, limit, offset int) ([]*model.Subscription, int, error)
+	ExportFn              func(ctx context.Context, filter model.SubscriptionFilter, fn func(*model.Subscription) error) error
+	AggregateFn           func(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (int, error)
+	AggregateByCurrencyFn func(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (map[string]int, error)
+	AggregateByUsersFn    func(ctx context.Context, startDate, endDate time.Time, userIDs []uuid.UUID, serviceName *model.ServiceNameFilter) (map[uuid.UUID]int, error)
+	AggregateByMonthFn    func(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]repository.MonthlyCurrencyTotal, error)
+	AggregateMatrixFn     func(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter, limit int) ([]repository.ServiceUserCurrencyTotal, error)
+	SimulatePriceChangeFn func(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter, newPrice *int, percent *float64) (int, int, error)
+	TimelineFn            func(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]repository.MonthlyCount, error)
+}
+
+func (m *SubscriptionRepository) Create(ctx context.Context, sub *model.Subscription, limit repository.UserLimit) error {
+	if m.CreateFn == nil {
+		return notConfigured("Create")
+	}
+	return m.CreateFn(ctx, sub, limit)
+}
+
+func (m *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
+	if m.GetByIDFn == nil {
+		return nil, notConfigured("GetByID")
+	}
+	return m.GetByIDFn(ctx, id)
+}
+
+func (m *SubscriptionRepository) CreateIfNotExists(ctx context.Context, sub *model.Subscription, limit repository.UserLimit, check func() error) (*model.Subscription, bool, error) {
+	if m.CreateIfNotExistsFn == nil {
+		return nil, false, notConfigured("CreateIfNotExists")
+	}
+	return m.CreateIfNotExistsFn(ctx, sub, limit, check)
+}
+
+func (m *SubscriptionRepository) FindDuplicate(ctx context.Context, sub *model.Subscription, policy string) (*model.Subscription, error) {
+	if m.FindDuplicateFn == nil {
+		return nil, notConfigured("FindDuplicate")
+	}
+	return m.FindDuplicateFn(ctx, sub, policy)
+}
+
+func (m *SubscriptionRepository) MonthlyCostByUser(ctx context.Context, userID uuid.UUID, on time.Time) (map[string]int, error) {
+	if m.MonthlyCostByUserFn == nil {
+		return nil, notConfigured("MonthlyCostByUser")
+	}
+	return m.MonthlyCostByUserFn(ctx, userID, on)
+}
+
+func (m *SubscriptionRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}, expectedVersion *int, skipUnchanged bool, limit repository.UserLimit) (*model.Subscription, error) {
+	if m.UpdateFn == nil {
+		return nil, notConfigured("Update")
+	}
+	return m.UpdateFn(ctx, id, updates, expectedVersion, skipUnchanged, limit)
+}
+
+func (m *SubscriptionRepository) Delete(ctx context.Context, id uuid.UUID, expectedVersion *int) (*model.Subscription, error) {
+	if m.DeleteFn == nil {
+		return nil, notConfigured("Delete")
+	}
+	return m.DeleteFn(ctx, id, expectedVersion)
+}
+
+func (m *SubscriptionRepository) Transfer(ctx context.Context, id, toUserID uuid.UUID, limit repository.UserLimit, check func(current *model.Subscription) error) (*model.Subscription, error) {
+	if m.TransferFn == nil {
+		return nil, notConfigured("Transfer")
+	}
+	return m.TransferFn(ctx, id, toUserID, limit, check)
+}
+
+func (m *SubscriptionRepository) List(ctx context.Context, filter model.SubscriptionFilter) ([]*model.Subscription, int, int, error) {
+	if m.ListFn == nil {
+		return nil, 0, 0, notConfigured("List")
+	}
+	return m.ListFn(ctx, filter)
+}
+
+func (m *SubscriptionRepository) ListRefs(ctx context.Context, filter model.SubscriptionFilter) ([]model.SubscriptionRef, int, error) {
+	if m.ListRefsFn == nil {
+		return nil, 0, notConfigured("ListRefs")
+	}
+	return m.ListRefsFn(ctx, filter)
+}
+
+func (m *SubscriptionRepository) Facet(ctx context.Context, filter model.SubscriptionFilter, field string, today time.Time) (map[string]int, error) {
+	if m.FacetFn == nil {
+		return nil, notConfigured("Facet")
+	}
+	return m.FacetFn(ctx, filter, field, today)
+}
+
+func (m *SubscriptionRepository) Top(ctx context.Context, by string, limit int, today time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]*model.Subscription, error) {
+	if m.TopFn == nil {
+		return nil, notConfigured("Top")
+	}
+	return m.TopFn(ctx, by, limit, today, userID, serviceName)
+}
+
+func (m *SubscriptionRepository) Changes(ctx context.Context, since time.Time, afterID *uuid.UUID, limit int) ([]*model.Subscription, error) {
+	if m.ChangesFn == nil {
+		return nil, notConfigured("Changes")
+	}
+	return m.ChangesFn(ctx, since, afterID, limit)
+}
+
+func (m *SubscriptionRepository) ListExpiring(ctx context.Context, from, to time.Time, limit, offset int) ([]*model.Subscription, int, error) {
+	if m.ListExpiringFn == nil {
+		return nil, 0, notConfigured("ListExpiring")
+	}
+	return m.ListExpiringFn(ctx, from, to, limit, offset)
+}
+
+func (m *SubscriptionRepository) Export(ctx context.Context, filter model.SubscriptionFilter, fn func(*model.Subscription) error) error {
+	if m.ExportFn == nil {
+		return notConfigured("Export")
+	}
+	return m.ExportFn(ctx, filter, fn)
+}
+
+func (m *SubscriptionRepository) Aggregate(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (int, error) {
+	if m.AggregateFn == nil {
+		return 0, notConfigured("Aggregate")
+	}
+	return m.AggregateFn(ctx, startDate, endDate, userID, serviceName)
+}
+
+func (m *SubscriptionRepository) AggregateByCurrency(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (map[string]int, error) {
+	if m.AggregateByCurrencyFn == nil {
+		return nil, notConfigured("AggregateByCurrency")
+	}
+	return m.AggregateByCurrencyFn(ctx, startDate, endDate, userID, serviceName)
+}
+
+func (m *SubscriptionRepository) AggregateByUsers(ctx context.Context, startDate, endDate time.Time, userIDs []uuid.UUID, serviceName *model.ServiceNameFilter) (map[uuid.UUID]int, error) {
+	if m.AggregateByUsersFn == nil {
+		return nil, notConfigured("AggregateByUsers")
+	}
+	return m.AggregateByUsersFn(ctx, startDate, endDate, userIDs, serviceName)
+}
+
+func (m *SubscriptionRepository) AggregateByMonth(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]repository.MonthlyCurrencyTotal, error) {
+	if m.AggregateByMonthFn == nil {
+		return nil, notConfigured("AggregateByMonth")
+	}
+	return m.AggregateByMonthFn(ctx, startDate, endDate, userID, serviceName)
+}
+
+func (m *SubscriptionRepository) AggregateMatrix(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter, limit int) ([]repository.ServiceUserCurrencyTotal, error) {
+	if m.AggregateMatrixFn == nil {
+		return nil, notConfigured("AggregateMatrix")
+	}
+	return m.AggregateMatrixFn(ctx, startDate, endDate, userID, serviceName, limit)
+}
+
+func (m *SubscriptionRepository) SimulatePriceChange(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter, newPrice *int, percent *float64) (int, int, error) {
+	if m.SimulatePriceChangeFn == nil {
+		return 0, 0, notConfigured("SimulatePriceChange")
+	}
+	return m.SimulatePriceChangeFn(ctx, startDate, endDate, userID, serviceName, newPrice, percent)
+}
+
+func (m *SubscriptionRepository) Timeline(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]repository.MonthlyCount, error) {
+	if m.TimelineFn == nil {
+		return nil, notConfigured("Timeline")
+	}
+	return m.TimelineFn(ctx, startDate, endDate, userID, serviceName)
+}
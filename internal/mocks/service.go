@@ -0,0 +1,206 @@
+package mocks
+
+import (
+	"context"
+	"subscription_service/internal/model"
+	"subscription_service/internal/service"
+)
+
+var _ service.SubscriptionService = (*SubscriptionService)(nil)
+
+type SubscriptionService struct {
+	CreateFn              func(ctx context.Context, req *model.CreateSubscriptionRequest) (*model.Subscription, error)
+	CreateIfNotExistsFn   func(ctx context.Context, req *model.CreateSubscriptionRequest) (*model.Subscription, bool, error)
+	GetByIDFn             func(ctx context.Context, id string) (*model.Subscription, error)
+	UpdateFn              func(ctx context.Context, id string, req *model.UpdateSubscriptionRequest, expectedVersion *int) (*model.Subscription, error)
+	ShiftFn               func(ctx context.Context, id string, months int) (*model.Subscription, error)
+	TransferFn            func(ctx context.Context, id, toUserID string) (*model.Subscription, error)
+	SchemaFn              func() *model.SubscriptionSchema
+	DeleteFn              func(ctx context.Context, id string, expectedVersion *int) (*model.Subscription, error)
+	ListFn                func(ctx context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionPage, error)
+	ListRefsFn            func(ctx context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionRefPage, error)
+	QueryFn               func(ctx context.Context, req *model.SubscriptionQuery) (*model.SubscriptionPage, error)
+	ExportFn              func(ctx context.Context, req *model.ListSubscriptionsRequest, fn func(*model.Subscription) error) error
+	TopFn                 func(ctx context.Context, req *model.TopSubscriptionsRequest) ([]*model.Subscription, error)
+	ChangesFn             func(ctx context.Context, req *model.ChangesRequest) (*model.ChangesPage, error)
+	ListExpiringFn        func(ctx context.Context, months, limit, offset int) (*model.ExpiringSubscriptions, error)
+	AggregateFn           func(ctx context.Context, req *model.AggregateRequest) (*model.AggregateResponse, error)
+	AggregateMonthlyFn    func(ctx context.Context, req *model.AggregateRequest) (*model.AggregateMonthlyResponse, error)
+	AggregateMatrixFn     func(ctx context.Context, req *model.AggregateRequest) (*model.AggregateServiceUserResponse, error)
+	CompareAggregateFn    func(ctx context.Context, req *model.CompareAggregateRequest) (*model.CompareAggregateResponse, error)
+	AggregateBatchFn      func(ctx context.Context, req *model.BatchAggregateRequest) (*model.BatchAggregateResponse, error)
+	TimelineFn            func(ctx context.Context, req *model.TimelineRequest) ([]model.TimelinePoint, error)
+	SimulatePriceChangeFn func(ctx context.Context, req *model.SimulatePriceChangeRequest) (*model.SimulatePriceChangeResponse, error)
+	UserMonthlyCostFn     func(ctx context.Context, userID string) (*model.AggregateResponse, error)
+	DeletionPreviewFn     func(ctx context.Context, userID string) (*model.UserDeletionPreview, error)
+}
+
+func (m *SubscriptionService) Create(ctx context.Context, req *model.CreateSubscriptionRequest) (*model.Subscription, error) {
+	if m.CreateFn == nil {
+		return nil, notConfigured("Create")
+	}
+	return m.CreateFn(ctx, req)
+}
+
+func (m *SubscriptionService) CreateIfNotExists(ctx context.Context, req *model.CreateSubscriptionRequest) (*model.Subscription, bool, error) {
+	if m.CreateIfNotExistsFn == nil {
+		return nil, false, notConfigured("CreateIfNotExists")
+	}
+	return m.CreateIfNotExistsFn(ctx, req)
+}
+
+func (m *SubscriptionService) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
+	if m.GetByIDFn == nil {
+		return nil, notConfigured("GetByID")
+	}
+	return m.GetByIDFn(ctx, id)
+}
+
+func (m *SubscriptionService) Update(ctx context.Context, id string, req *model.UpdateSubscriptionRequest, expectedVersion *int) (*model.Subscription, error) {
+	if m.UpdateFn == nil {
+		return nil, notConfigured("Update")
+	}
+	return m.UpdateFn(ctx, id, req, expectedVersion)
+}
+
+func (m *SubscriptionService) Shift(ctx context.Context, id string, months int) (*model.Subscription, error) {
+	if m.ShiftFn == nil {
+		return nil, notConfigured("Shift")
+	}
+	return m.ShiftFn(ctx, id, months)
+}
+
+// Schema без SchemaFn возвращает схему со всеми полями сортировки: ошибки в
+// сигнатуре нет, а хендлеры списка читают из неё разрешённые сортировки.
+func (m *SubscriptionService) Schema() *model.SubscriptionSchema {
+	if m.SchemaFn == nil {
+		return &model.SubscriptionSchema{SortFields: model.SortFields}
+	}
+	return m.SchemaFn()
+}
+
+func (m *SubscriptionService) Transfer(ctx context.Context, id, toUserID string) (*model.Subscription, error) {
+	if m.TransferFn == nil {
+		return nil, notConfigured("Transfer")
+	}
+	return m.TransferFn(ctx, id, toUserID)
+}
+
+func (m *SubscriptionService) Delete(ctx context.Context, id string, expectedVersion *int) (*model.Subscription, error) {
+	if m.DeleteFn == nil {
+		return nil, notConfigured("Delete")
+	}
+	return m.DeleteFn(ctx, id, expectedVersion)
+}
+
+func (m *SubscriptionService) List(ctx context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionPage, error) {
+	if m.ListFn == nil {
+		return nil, notConfigured("List")
+	}
+	return m.ListFn(ctx, req)
+}
+
+func (m *SubscriptionService) Export(ctx context.Context, req *model.ListSubscriptionsRequest, fn func(*model.Subscription) error) error {
+	if m.ExportFn == nil {
+		return notConfigured("Export")
+	}
+	return m.ExportFn(ctx, req, fn)
+}
+
+func (m *SubscriptionService) ListRefs(ctx context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionRefPage, error) {
+	if m.ListRefsFn == nil {
+		return nil, notConfigured("ListRefs")
+	}
+	return m.ListRefsFn(ctx, req)
+}
+
+func (m *SubscriptionService) Query(ctx context.Context, req *model.SubscriptionQuery) (*model.SubscriptionPage, error) {
+	if m.QueryFn == nil {
+		return nil, notConfigured("Query")
+	}
+	return m.QueryFn(ctx, req)
+}
+
+func (m *SubscriptionService) Top(ctx context.Context, req *model.TopSubscriptionsRequest) ([]*model.Subscription, error) {
+	if m.TopFn == nil {
+		return nil, notConfigured("Top")
+	}
+	return m.TopFn(ctx, req)
+}
+
+func (m *SubscriptionService) Changes(ctx context.Context, req *model.ChangesRequest) (*model.ChangesPage, error) {
+	if m.ChangesFn == nil {
+		return nil, notConfigured("Changes")
+	}
+	return m.ChangesFn(ctx, req)
+}
+
+func (m *SubscriptionService) ListExpiring(ctx context.Context, months, limit, offset int) (*model.ExpiringSubscriptions, error) {
+	if m.ListExpiringFn == nil {
+		return nil, notConfigured("ListExpiring")
+	}
+	return m.ListExpiringFn(ctx, months, limit, offset)
+}
+
+func (m *SubscriptionService) Aggregate(ctx context.Context, req *model.AggregateRequest) (*model.AggregateResponse, error) {
+	if m.AggregateFn == nil {
+		return nil, notConfigured("Aggregate")
+	}
+	return m.AggregateFn(ctx, req)
+}
+
+func (m *SubscriptionService) AggregateMonthly(ctx context.Context, req *model.AggregateRequest) (*model.AggregateMonthlyResponse, error) {
+	if m.AggregateMonthlyFn == nil {
+		return nil, notConfigured("AggregateMonthly")
+	}
+	return m.AggregateMonthlyFn(ctx, req)
+}
+
+func (m *SubscriptionService) AggregateMatrix(ctx context.Context, req *model.AggregateRequest) (*model.AggregateServiceUserResponse, error) {
+	if m.AggregateMatrixFn == nil {
+		return nil, notConfigured("AggregateMatrix")
+	}
+	return m.AggregateMatrixFn(ctx, req)
+}
+
+func (m *SubscriptionService) CompareAggregate(ctx context.Context, req *model.CompareAggregateRequest) (*model.CompareAggregateResponse, error) {
+	if m.CompareAggregateFn == nil {
+		return nil, notConfigured("CompareAggregate")
+	}
+	return m.CompareAggregateFn(ctx, req)
+}
+
+func (m *SubscriptionService) AggregateBatch(ctx context.Context, req *model.BatchAggregateRequest) (*model.BatchAggregateResponse, error) {
+	if m.AggregateBatchFn == nil {
+		return nil, notConfigured("AggregateBatch")
+	}
+	return m.AggregateBatchFn(ctx, req)
+}
+
+func (m *SubscriptionService) Timeline(ctx context.Context, req *model.TimelineRequest) ([]model.TimelinePoint, error) {
+	if m.TimelineFn == nil {
+		return nil, notConfigured("Timeline")
+	}
+	return m.TimelineFn(ctx, req)
+}
+
+func (m *SubscriptionService) SimulatePriceChange(ctx context.Context, req *model.SimulatePriceChangeRequest) (*model.SimulatePriceChangeResponse, error) {
+	if m.SimulatePriceChangeFn == nil {
+		return nil, notConfigured("SimulatePriceChange")
+	}
+	return m.SimulatePriceChangeFn(ctx, req)
+}
+
+func (m *SubscriptionService) UserMonthlyCost(ctx context.Context, userID string) (*model.AggregateResponse, error) {
+	if m.UserMonthlyCostFn == nil {
+		return nil, notConfigured("UserMonthlyCost")
+	}
+	return m.UserMonthlyCostFn(ctx, userID)
+}
+
+func (m *SubscriptionService) DeletionPreview(ctx context.Context, userID string) (*model.UserDeletionPreview, error) {
+	if m.DeletionPreviewFn == nil {
+		return nil, notConfigured("DeletionPreview")
+	}
+	return m.DeletionPreviewFn(ctx, userID)
+}
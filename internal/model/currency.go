@@ -0,0 +1,72 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/currency"
+)
+
+// Money - денежная сумма в ответе API: цена подписки или итог агрегации в тех же
+// единицах, что и price. Сериализуется числом; строкой её отдаёт handler, если
+// клиент запросил строковые цены. Тип, а не имя поля, отличает суммы от
+// произвольных чисел (например, в metadata).
+type Money int
+
+// defaultCurrencyScale - число знаков после запятой для кодов, которых нет в ISO 4217.
+const defaultCurrencyScale = 2
+
+// CurrencyScale возвращает число знаков дробной части валюты по ISO 4217:
+// 0 для JPY, 2 для RUB и USD, 3 для KWD.
+func CurrencyScale(code string) int {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return defaultCurrencyScale
+	}
+	scale, _ := currency.Standard.Rounding(unit)
+	return scale
+}
+
+// ParseMinorUnits переводит десятичную сумму в основных единицах ("9.99") в
+// целое число минимальных единиц валюты со scale знаками. Больше знаков после
+// точки, чем scale, - ошибка: сумма не представима в этой валюте.
+func ParseMinorUnits(amount string, scale int) (int, error) {
+	whole, fraction, hasPoint := strings.Cut(amount, ".")
+	if whole == "" || (hasPoint && fraction == "") || !isDigits(whole) || !isDigits(fraction) {
+		return 0, fmt.Errorf("invalid amount %q, expected a non-negative decimal like 9.99", amount)
+	}
+	if len(fraction) > scale {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", amount, scale)
+	}
+
+	minor, err := strconv.Atoi(whole + fraction + strings.Repeat("0", scale-len(fraction)))
+	if err != nil {
+		return 0, errors.New("amount is too large")
+	}
+	return minor, nil
+}
+
+// FormatMinorUnits - обратное к ParseMinorUnits: 999 со scale 2 даёт "9.99".
+func FormatMinorUnits(minor, scale int) string {
+	if scale <= 0 {
+		return strconv.Itoa(minor)
+	}
+
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	digits := fmt.Sprintf("%0*d", scale+1, minor)
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
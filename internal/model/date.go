@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Date - дата без времени в формате YYYY-MM-DD в теле запроса. Разбор
+// происходит при декодировании JSON, поэтому неверная дата отклоняется сразу
+// с указанием значения, а сервис получает готовый time.Time.
+type Date struct {
+	time.Time
+}
+
+// UnmarshalJSON принимает строку YYYY-MM-DD. Пустая строка даёт нулевую Date
+// (в UpdateSubscriptionRequest так сбрасывается end_date).
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid date %s, expected a string in YYYY-MM-DD format", data)
+	}
+
+	if s == "" {
+		d.Time = time.Time{}
+		return nil
+	}
+
+	t, err := time.Parse(DateFormat, s)
+	if err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+	}
+	d.Time = t
+	return nil
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return json.Marshal("")
+	}
+	return json.Marshal(d.Format(DateFormat))
+}
@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+type ExchangeRate struct {
+	Currency   string    `json:"currency" db:"currency"`
+	Date       time.Time `json:"date" db:"date"`
+	RateToBase float64   `json:"rate_to_base" db:"rate_to_base"`
+}
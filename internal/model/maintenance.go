@@ -0,0 +1,30 @@
+package model
+
+import "github.com/google/uuid"
+
+type MaintenanceOperation struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type MaintenanceResult struct {
+	Operations      []MaintenanceOperation `json:"operations"`
+	TotalDurationMs int64                  `json:"total_duration_ms"`
+}
+
+// RecomputeResult - итог пересчёта производных колонок. Если Completed=false,
+// запуск можно продолжить, передав LastID как after_id.
+type RecomputeResult struct {
+	Batches     int        `json:"batches"`
+	RowsScanned int        `json:"rows_scanned"`
+	RowsUpdated int        `json:"rows_updated"`
+	LastID      *uuid.UUID `json:"last_id,omitempty"`
+	Completed   bool       `json:"completed"`
+	DurationMs  int64      `json:"duration_ms"`
+}
+
+// SummaryRebuildResult - итог пересборки помесячной сводки агрегаций.
+type SummaryRebuildResult struct {
+	Rows       int   `json:"rows"`
+	DurationMs int64 `json:"duration_ms"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Типы событий, которые репозиторий пишет в outbox.
+const (
+	EventSubscriptionCreated = "subscription.created"
+	EventSubscriptionUpdated = "subscription.updated"
+	EventSubscriptionDeleted = "subscription.deleted"
+	// EventSubscriptionTransferred - смена владельца; в payload дополнительно
+	// previous_user_id.
+	EventSubscriptionTransferred = "subscription.transferred"
+	// EventSubscriptionRenewed - автопродление (auto_renew); в payload
+	// дополнительно previous_end_date.
+	EventSubscriptionRenewed = "subscription.renewed"
+)
+
+// OutboxEvent - событие об изменении подписки, записанное в той же транзакции,
+// что и само изменение. Payload - строка subscriptions после изменения
+// (для удаления - до него).
+type OutboxEvent struct {
+	ID          int64           `json:"id"`
+	AggregateID uuid.UUID       `json:"aggregate_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
@@ -0,0 +1,81 @@
+package model
+
+import "strconv"
+
+// QueryFields - поля подписки, которые можно запросить в fields запроса
+// /subscriptions/query: JSON-имена хранимых и вычисляемых полей.
+var QueryFields = []string{
+	"id", "service_name", "price", "currency", "tags", "description", "user_id",
+	"start_date", "end_date", "auto_renew", "metadata", "created_at", "updated_at", "version",
+	"next_billing_date", "duration_months", "months_remaining", "status", "amount",
+}
+
+// ValidQueryField проверяет, что field есть среди QueryFields.
+func ValidQueryField(field string) bool {
+	for _, f := range QueryFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionQuery - структурированный запрос списка (POST /subscriptions/query):
+// те же фильтры, что и у GET /subscriptions, плюс выбор полей ответа.
+// Пустой Fields - все поля.
+type SubscriptionQuery struct {
+	Filter SubscriptionQueryFilter `json:"filter"`
+	Fields []string                `json:"fields,omitempty"`
+	Sort   string                  `json:"sort,omitempty"`
+	Page   QueryPage               `json:"page"`
+}
+
+type SubscriptionQueryFilter struct {
+	IDs          []string          `json:"ids,omitempty"`
+	UserID       *string           `json:"user_id,omitempty"`
+	ServiceNames []string          `json:"service_name,omitempty"`
+	Exact        *bool             `json:"exact,omitempty"`
+	StartDate    *string           `json:"start_date,omitempty"`
+	EndDate      *string           `json:"end_date,omitempty"`
+	Tag          *string           `json:"tag,omitempty"`
+	ActiveOn     *string           `json:"active_on,omitempty"`
+	OpenEnded    *bool             `json:"open_ended,omitempty"`
+	UpdatedAfter *string           `json:"updated_after,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+type QueryPage struct {
+	Limit  int `json:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int `json:"offset" binding:"min=0"`
+}
+
+// ListRequest переводит запрос в параметры обычного списка.
+func (q *SubscriptionQuery) ListRequest() *ListSubscriptionsRequest {
+	f := q.Filter
+	req := &ListSubscriptionsRequest{
+		IDs:          f.IDs,
+		UserID:       f.UserID,
+		ServiceNames: f.ServiceNames,
+		StartDate:    f.StartDate,
+		EndDate:      f.EndDate,
+		Tag:          f.Tag,
+		ActiveOn:     f.ActiveOn,
+		UpdatedAfter: f.UpdatedAfter,
+		Metadata:     f.Metadata,
+		Limit:        q.Page.Limit,
+		Offset:       q.Page.Offset,
+	}
+	if f.Exact != nil {
+		exact := strconv.FormatBool(*f.Exact)
+		req.Exact = &exact
+	}
+	if f.OpenEnded != nil {
+		openEnded := strconv.FormatBool(*f.OpenEnded)
+		req.OpenEnded = &openEnded
+	}
+	if q.Sort != "" {
+		sort := q.Sort
+		req.Sort = &sort
+	}
+	return req
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Renewal - продление подписки с auto_renew на один месяц после её окончания.
+type Renewal struct {
+	SubscriptionID  uuid.UUID
+	UserID          uuid.UUID
+	PreviousEndDate time.Time
+	NewEndDate      time.Time
+}
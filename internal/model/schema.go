@@ -0,0 +1,66 @@
+package model
+
+// Операторы фильтров в описании схемы списка.
+const (
+	OpEq       = "eq"
+	OpIn       = "in"
+	OpContains = "contains"
+	OpGte      = "gte"
+	OpLte      = "lte"
+	OpGt       = "gt"
+	OpLt       = "lt"
+)
+
+// SchemaFilter - фильтр списка подписок: параметр запроса, тип значения и
+// операторы. Column - поле подписки, с которым сравнивается значение, если оно
+// отличается от Name (end_date ограничивает start_date сверху).
+type SchemaFilter struct {
+	Name      string   `json:"name"`
+	Column    string   `json:"column,omitempty"`
+	Type      string   `json:"type"`
+	Operators []string `json:"operators"`
+	// Multiple - параметр можно повторить или передать значения через запятую.
+	Multiple    bool   `json:"multiple,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ListFilters - фильтры GET /subscriptions и filter в POST /subscriptions/query.
+// Новый фильтр в ListSubscriptionsRequest добавляется и сюда.
+var ListFilters = []SchemaFilter{
+	{Name: "ids", Column: "id", Type: "uuid", Operators: []string{OpIn}, Multiple: true},
+	{Name: "user_id", Type: "uuid", Operators: []string{OpEq}},
+	{Name: "service_name", Type: "string", Operators: []string{OpContains, OpEq}, Multiple: true,
+		Description: "без учёта регистра; eq при exact=true"},
+	{Name: "exact", Type: "bool", Operators: []string{OpEq}, Description: "точное совпадение service_name"},
+	{Name: "start_date", Type: "date", Operators: []string{OpGte}},
+	{Name: "end_date", Column: "start_date", Type: "date", Operators: []string{OpLte}},
+	{Name: "tag", Column: "tags", Type: "string", Operators: []string{OpContains}},
+	{Name: "created_from", Column: "created_at", Type: "timestamp", Operators: []string{OpGte}},
+	{Name: "created_to", Column: "created_at", Type: "timestamp", Operators: []string{OpLt}},
+	{Name: "period", Column: "created_at", Type: "enum", Operators: []string{OpEq}, Description: "см. enums.period"},
+	{Name: "open_ended", Column: "end_date", Type: "bool", Operators: []string{OpEq}, Description: "true - без end_date"},
+	{Name: "active_on", Type: "date", Operators: []string{OpEq}, Description: "подписка активна на дату"},
+	{Name: "updated_after", Column: "updated_at", Type: "timestamp", Operators: []string{OpGt}},
+	{Name: "meta.<key>", Column: "metadata", Type: "string", Operators: []string{OpEq}},
+}
+
+// Периоды фильтра period.
+var ListPeriods = []string{"today", "week", "month"}
+
+// BillingCycles - периоды списания. Все подписки оплачиваются помесячно
+// (next_billing_date, агрегации).
+var BillingCycles = []string{"monthly"}
+
+// SubscriptionSchema - описание того, по чему можно фильтровать и сортировать
+// список подписок, для построения фильтров в UI.
+type SubscriptionSchema struct {
+	Filters    []SchemaFilter `json:"filters"`
+	SortFields []string       `json:"sort_fields"`
+	// Fields - поля для fields в POST /subscriptions/query.
+	Fields []string            `json:"fields"`
+	Facets []string            `json:"facets"`
+	Enums  map[string][]string `json:"enums"`
+	// Currency - коды валют проверяются по ISO 4217; BaseCurrency - валюта по умолчанию.
+	Currency     string `json:"currency_standard"`
+	BaseCurrency string `json:"base_currency"`
+}
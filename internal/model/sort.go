@@ -0,0 +1,26 @@
+package model
+
+import "strings"
+
+// SortFields - поля, по которым разрешена сортировка списка подписок.
+var SortFields = []string{"start_date", "end_date", "created_at", "updated_at", "price", "service_name"}
+
+// ParseSort разбирает значение сортировки вида "field" или "-field" (по убыванию).
+func ParseSort(sort string) (field string, desc bool, ok bool) {
+	return ParseSortIn(sort, SortFields)
+}
+
+// ParseSortIn - ParseSort с полями из allowed (подмножество SortFields,
+// включённое в конфигурации).
+func ParseSortIn(sort string, allowed []string) (field string, desc bool, ok bool) {
+	field = strings.TrimPrefix(sort, "-")
+	desc = strings.HasPrefix(sort, "-")
+
+	for _, f := range allowed {
+		if f == field {
+			return field, desc, true
+		}
+	}
+
+	return "", false, false
+}
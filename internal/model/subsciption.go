@@ -1,6 +1,8 @@
 package model
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,76 +11,577 @@ import (
 type Subscription struct {
 	ID          uuid.UUID  `json:"id" db:"id"`
 	ServiceName string     `json:"service_name" db:"service_name" binding:"required"`
-	Price       int        `json:"price" db:"price" binding:"required,min=0"`
+	Price       Money      `json:"price" db:"price" binding:"required,min=0"`
+	Currency    string     `json:"currency" db:"currency"`
+	Tags        []string   `json:"tags" db:"tags"`
+	Description string     `json:"description" db:"description"`
 	UserID      uuid.UUID  `json:"user_id" db:"user_id" binding:"required"`
 	StartDate   time.Time  `json:"start_date" db:"start_date" binding:"required"`
 	EndDate     *time.Time `json:"end_date,omitempty" db:"end_date"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	// AutoRenew - продлевать подписку на месяц после end_date (фоновая задача renewal).
+	AutoRenew bool `json:"auto_renew" db:"auto_renew"`
+	// Metadata - произвольные пары ключ-значение интеграций (плоский JSON-объект).
+	Metadata map[string]interface{} `json:"metadata" db:"metadata"`
+	// Вычисляемые поля заполняются сервисом (см. SetComputedFields) и не хранятся в БД.
+	NextBillingDate *time.Time `json:"next_billing_date" db:"-"`
+	DurationMonths  int        `json:"duration_months" db:"-"`
+	MonthsRemaining *int       `json:"months_remaining" db:"-"`
+	Status          string     `json:"status" db:"-"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	// Version растёт на 1 при каждом изменении; из него строится ETag.
+	Version int `json:"version" db:"version"`
+	// Warnings - разрешённые предупреждения мягкой валидации, только в ответе на создание.
+	Warnings []string `json:"warnings,omitempty" db:"-"`
+	// PriceDisplay - цена, отформатированная для показа (format=display), только в ответе.
+	PriceDisplay string `json:"price_display,omitempty" db:"-"`
+	// Amount - price в основных единицах валюты ("9.99"); заполняется, только
+	// когда цены хранятся в минимальных единицах (PRICE_MINOR_UNITS).
+	Amount string `json:"amount,omitempty" db:"-"`
+}
+
+const DateFormat = "2006-01-02"
+
+// MarshalJSON отдаёт start_date/end_date как даты YYYY-MM-DD,
+// а created_at/updated_at - в RFC3339 в UTC.
+func (s Subscription) MarshalJSON() ([]byte, error) {
+	type alias Subscription
+
+	out := struct {
+		alias
+		StartDate string  `json:"start_date"`
+		EndDate   *string `json:"end_date,omitempty"`
+		// NextBillingDate отдаётся как null, если списаний больше не будет.
+		NextBillingDate *string   `json:"next_billing_date"`
+		CreatedAt       time.Time `json:"created_at"`
+		UpdatedAt       time.Time `json:"updated_at"`
+	}{
+		alias:     alias(s),
+		StartDate: s.StartDate.Format(DateFormat),
+		CreatedAt: s.CreatedAt.UTC().Truncate(time.Second),
+		UpdatedAt: s.UpdatedAt.UTC().Truncate(time.Second),
+	}
+
+	if s.EndDate != nil {
+		endDate := s.EndDate.Format(DateFormat)
+		out.EndDate = &endDate
+	}
+
+	if s.NextBillingDate != nil {
+		next := s.NextBillingDate.Format(DateFormat)
+		out.NextBillingDate = &next
+	}
+
+	return json.Marshal(out)
 }
 
 type CreateSubscriptionRequest struct {
-	ServiceName string `json:"service_name" binding:"required"`
-	Price       int    `json:"price" binding:"required,min=0"`
-	UserID      string `json:"user_id" binding:"required,uuid"`
-	StartDate   string `json:"start_date" binding:"required,datetime=2006-01-02"`
-	EndDate     string `json:"end_date,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	// ID задаётся клиентом при переносе данных; если не задан, генерируется.
+	ID          string   `json:"id,omitempty" binding:"omitempty,uuid"`
+	ServiceName string   `json:"service_name" binding:"required"`
+	Price       int      `json:"price" binding:"required_without=Amount,min=0"`
+	Currency    string   `json:"currency,omitempty" binding:"omitempty,iso4217"`
+	Tags        []string `json:"tags,omitempty" binding:"omitempty,dive,required,max=50"`
+	Description string   `json:"description,omitempty" binding:"max=500"`
+	UserID      string   `json:"user_id" binding:"required,uuid"`
+	StartDate   *Date    `json:"start_date" binding:"required" swaggertype:"string" example:"2025-07-01"`
+	EndDate     *Date    `json:"end_date,omitempty" swaggertype:"string" example:"2025-12-31"`
+	AutoRenew   bool     `json:"auto_renew,omitempty"`
+	// Metadata - плоский JSON-объект со значениями-скалярами, не больше 2 КБ.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// Amount - цена в основных единицах валюты ("9.99") вместо price; только
+	// при PRICE_MINOR_UNITS, знаков после точки не больше, чем у валюты.
+	Amount string `json:"amount,omitempty"`
+
+	// OverrideLimit снимает лимит активных подписок; выставляется хендлером
+	// только для запросов с admin API key, из тела не читается.
+	OverrideLimit bool `json:"-"`
+	// AllowWarnings создаёт подписку несмотря на предупреждения (?allow_warnings=true).
+	AllowWarnings bool `json:"-"`
 }
 
+// UpdateSubscriptionRequest - частичное обновление; end_date "" сбрасывает дату
+// окончания (подписка становится бессрочной).
 type UpdateSubscriptionRequest struct {
-	ServiceName *string `json:"service_name,omitempty"`
-	Price       *int    `json:"price,omitempty" binding:"omitempty,min=0"`
-	UserID      *string `json:"user_id,omitempty" binding:"omitempty,uuid"`
-	StartDate   *string `json:"start_date,omitempty" binding:"omitempty,datetime=2006-01-02"`
-	EndDate     *string `json:"end_date,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	ServiceName *string   `json:"service_name,omitempty"`
+	Price       *int      `json:"price,omitempty" binding:"omitempty,min=0"`
+	Amount      *string   `json:"amount,omitempty"`
+	Currency    *string   `json:"currency,omitempty" binding:"omitempty,iso4217"`
+	Tags        *[]string `json:"tags,omitempty" binding:"omitempty,dive,required,max=50"`
+	Description *string   `json:"description,omitempty" binding:"omitempty,max=500"`
+	UserID      *string   `json:"user_id,omitempty" binding:"omitempty,uuid"`
+	StartDate   *Date     `json:"start_date,omitempty" swaggertype:"string" example:"2025-07-01"`
+	EndDate     *Date     `json:"end_date,omitempty" swaggertype:"string" example:"2025-12-31"`
+	AutoRenew   *bool     `json:"auto_renew,omitempty"`
+	// Metadata заменяет metadata целиком; {} очищает.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ShiftSubscriptionRequest - сдвиг дат подписки на Months месяцев (может быть отрицательным).
+type ShiftSubscriptionRequest struct {
+	Months *int `json:"months" binding:"required"`
+}
+
+// TransferSubscriptionRequest - передача подписки другому пользователю.
+type TransferSubscriptionRequest struct {
+	ToUserID string `json:"to_user_id" binding:"required,uuid"`
+}
+
+type ListSubscriptionsRequest struct {
+	IDs          []string
+	UserID       *string
+	ServiceNames []string
+	StartDate    *string
+	EndDate      *string
+	Tag          *string
+	CreatedFrom  *string
+	CreatedTo    *string
+	Exact        *string
+	OpenEnded    *string
+	ActiveOn     *string
+	UpdatedAfter *string
+	Facets       []string
+	Metadata     map[string]string
+	Period       *string
+	Sort         *string
+	Limit        int
+	Offset       int
+}
+
+// ResolvedPeriod - диапазон created_at [From, To), вычисленный из пресета period.
+type ResolvedPeriod struct {
+	Name string    `json:"name"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type SubscriptionPage struct {
+	Items []*Subscription
+	// Total - число подписок по фильтру на всех страницах.
+	Total  int
+	Period *ResolvedPeriod
+	// Warnings - некритичные проблемы, не помешавшие вернуть результат.
+	Warnings []string
+	// Facets - счётчики по запрошенным фасетам: поле -> значение -> количество.
+	Facets map[string]map[string]int
+}
+
+// Фасеты списка (facets=...): количество подписок по значениям поля в рамках
+// фильтра, без учёта пагинации.
+const (
+	FacetServiceName = "service_name"
+	FacetStatus      = "status"
+)
+
+// Статусы подписки на сегодняшний день для фасета status.
+const (
+	StatusActive   = "active"
+	StatusUpcoming = "upcoming"
+	StatusExpired  = "expired"
+)
+
+// SubscriptionRef - облегчённое представление подписки для опроса изменений (view=ids).
+type SubscriptionRef struct {
+	ID        uuid.UUID `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SubscriptionRefPage - результат списка в представлении view=ids.
+// Total - общее количество совпадений без учёта пагинации.
+type SubscriptionRefPage struct {
+	Items  []SubscriptionRef
+	Total  int
+	Period *ResolvedPeriod
+}
+
+// SubscriptionGroup - подписки одного сервиса в ответе списка с group=service_name.
+// Total - сколько подписок группы было на странице до обрезки по group_limit.
+type SubscriptionGroup struct {
+	ServiceName   string          `json:"service_name"`
+	Total         int             `json:"total"`
+	Subscriptions []*Subscription `json:"subscriptions"`
+}
+
+// ExpiringSubscriptions - страница подписок, заканчивающихся в окне [From, To]
+// (включительно). Total - количество таких подписок без учёта пагинации.
+type ExpiringSubscriptions struct {
+	From  time.Time
+	To    time.Time
+	Items []*Subscription
+	Total int
+}
+
+// ServiceNameFilter - фильтр по названию сервиса без учёта регистра: по подстроке
+// или, при Exact, по точному совпадению. Несколько значений объединяются через OR.
+type ServiceNameFilter struct {
+	Values []string
+	Exact  bool
 }
 
 type SubscriptionFilter struct {
+	IDs         []uuid.UUID
 	UserID      *uuid.UUID
-	ServiceName *string
+	ServiceName *ServiceNameFilter
+	Tag         *string
 	StartDate   *time.Time
 	EndDate     *time.Time
-	Limit       int
-	Offset      int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	OpenEnded   *bool
+	ActiveOn    *time.Time
+	// UpdatedAfter - только подписки, изменённые строго позже этого момента.
+	UpdatedAfter *time.Time
+	// Metadata - подписки, metadata которых содержит все эти пары (строковые значения).
+	Metadata map[string]string
+	Sort     string
+	Limit    int
+	Offset   int
 }
 
 type AggregateRequest struct {
-	UserID      *string `form:"user_id" binding:"omitempty,uuid"`
-	ServiceName *string `form:"service_name"`
-	StartDate   string  `form:"start_date" binding:"required,datetime=2006-01-02"`
-	EndDate     string  `form:"end_date" binding:"required,datetime=2006-01-02"`
+	UserID       *string  `form:"user_id" binding:"omitempty,uuid"`
+	ServiceNames []string `form:"service_name"`
+	Exact        *bool    `form:"exact"`
+	StartDate    string   `form:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate      string   `form:"end_date" binding:"required,datetime=2006-01-02"`
+	Currency     *string  `form:"currency" binding:"omitempty,iso4217"`
+	// GroupBy - разбивка результата: GroupByMonth или GroupByServiceUser.
+	// Значение проверяется хендлером: oneof не умеет значения с запятой.
+	GroupBy      *string `form:"group_by"`
+	IncludeTotal bool    `form:"include_total"`
+	// Granularity - шаг временного ряда для group_by=month; по умолчанию month.
+	Granularity *string `form:"granularity" binding:"omitempty,oneof=month quarter year"`
+}
+
+// CompareAggregateRequest - два периода для сравнения; фильтры применяются к обоим.
+type CompareAggregateRequest struct {
+	UserID       *string  `form:"user_id" binding:"omitempty,uuid"`
+	ServiceNames []string `form:"service_name"`
+	Exact        *bool    `form:"exact"`
+	PeriodAStart string   `form:"period_a_start" binding:"required,datetime=2006-01-02"`
+	PeriodAEnd   string   `form:"period_a_end" binding:"required,datetime=2006-01-02"`
+	PeriodBStart string   `form:"period_b_start" binding:"required,datetime=2006-01-02"`
+	PeriodBEnd   string   `form:"period_b_end" binding:"required,datetime=2006-01-02"`
+	Currency     *string  `form:"currency" binding:"omitempty,iso4217"`
+}
+
+type TimelineRequest struct {
+	UserID       *string  `form:"user_id" binding:"omitempty,uuid"`
+	ServiceNames []string `form:"service_name"`
+	Exact        *bool    `form:"exact"`
+	Start        string   `form:"start" binding:"required,datetime=2006-01-02"`
+	End          string   `form:"end" binding:"required,datetime=2006-01-02"`
+}
+
+// TimelinePoint - количество подписок, активных на последний день месяца.
+type TimelinePoint struct {
+	Month       string `json:"month"`
+	ActiveCount int    `json:"active_count"`
+}
+
+type BatchAggregateRequest struct {
+	UserIDs     []string `json:"user_ids" binding:"required,min=1,dive,uuid"`
+	ServiceName *string  `json:"service_name,omitempty"`
+	Exact       *bool    `json:"exact,omitempty"`
+	StartDate   string   `json:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate     string   `json:"end_date" binding:"required,datetime=2006-01-02"`
+}
+
+type BatchAggregateResponse struct {
+	Totals map[string]Money `json:"totals"`
+}
+
+// Варианты разбивки агрегации (AggregateRequest.GroupBy).
+const (
+	GroupByMonth       = "month"
+	GroupByServiceUser = "service_name,user_id"
+)
+
+// ServiceUserTotal - сумма подписок пользователя на сервис за период.
+type ServiceUserTotal struct {
+	ServiceName string    `json:"service_name"`
+	UserID      uuid.UUID `json:"user_id"`
+	TotalPrice  Money     `json:"total_price"`
+}
+
+// AggregateServiceUserResponse - разбивка агрегации по парам (service_name, user_id).
+type AggregateServiceUserResponse struct {
+	Currency string             `json:"currency,omitempty"`
+	Groups   []ServiceUserTotal `json:"groups"`
 }
 
 type AggregateResponse struct {
-	TotalPrice int `json:"total_price"`
+	TotalPrice Money  `json:"total_price"`
+	Currency   string `json:"currency,omitempty"`
+}
+
+type PeriodTotal struct {
+	StartDate  string `json:"start_date"`
+	EndDate    string `json:"end_date"`
+	TotalPrice Money  `json:"total_price"`
+}
+
+// CompareAggregateResponse - итоги двух периодов и разница B - A.
+// DeltaPercent не заполняется, если итог периода A равен нулю.
+type CompareAggregateResponse struct {
+	PeriodA      PeriodTotal `json:"period_a"`
+	PeriodB      PeriodTotal `json:"period_b"`
+	Delta        Money       `json:"delta"`
+	DeltaPercent *float64    `json:"delta_percent"`
+	Currency     string      `json:"currency,omitempty"`
+}
+
+// Критерии выборки самых дорогих/долгих подписок (TopSubscriptionsRequest.By).
+const (
+	TopByPrice    = "price"
+	TopByDuration = "duration"
+)
+
+// TopSubscriptionsRequest - N подписок с наибольшей ценой или длительностью.
+type TopSubscriptionsRequest struct {
+	UserID       *string  `form:"user_id" binding:"omitempty,uuid"`
+	ServiceNames []string `form:"service_name"`
+	Exact        *bool    `form:"exact"`
+	By           string   `form:"by" binding:"omitempty,oneof=price duration"`
+	Limit        int      `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// ChangesRequest - подписки, изменённые после since, для инкрементальной
+// синхронизации. Курсор - пара (since, after_id) из next_since и next_after_id
+// предыдущего ответа: after_id различает подписки с одинаковым updated_at на
+// границе страниц.
+type ChangesRequest struct {
+	Since   string  `form:"since" binding:"required"`
+	AfterID *string `form:"after_id" binding:"omitempty,uuid"`
+	Limit   int     `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// ChangesPage - страница изменений по возрастанию (updated_at, id). Если
+// изменений нет, курсор возвращается без изменений.
+type ChangesPage struct {
+	Data        []*Subscription `json:"data"`
+	NextSince   time.Time       `json:"next_since"`
+	NextAfterID *uuid.UUID      `json:"next_after_id,omitempty"`
+	HasMore     bool            `json:"has_more"`
+}
+
+// UserDeletionPreview - что затронет удаление данных пользователя: все его
+// подписки и выручка по ним от первой start_date до сегодня в базовой валюте.
+type UserDeletionPreview struct {
+	UserID        uuid.UUID       `json:"user_id"`
+	Count         int             `json:"count"`
+	Subscriptions []*Subscription `json:"subscriptions"`
+	TotalRevenue  Money           `json:"total_revenue"`
+	Currency      string          `json:"currency"`
+}
+
+// SimulatePriceChangeRequest - гипотетическое изменение цены подписок на сервис:
+// новая цена (NewPrice) или изменение в процентах (PercentChange), ровно одно из двух.
+type SimulatePriceChangeRequest struct {
+	ServiceName   string   `json:"service_name" binding:"required"`
+	Exact         *bool    `json:"exact,omitempty"`
+	UserID        *string  `json:"user_id,omitempty" binding:"omitempty,uuid"`
+	StartDate     string   `json:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate       string   `json:"end_date" binding:"required,datetime=2006-01-02"`
+	NewPrice      *int     `json:"new_price,omitempty" binding:"omitempty,min=0"`
+	PercentChange *float64 `json:"percent_change,omitempty"`
+}
+
+// SimulatePriceChangeResponse - итог за период по текущим и по гипотетическим ценам.
+type SimulatePriceChangeResponse struct {
+	CurrentTotal   Money `json:"current_total"`
+	ProjectedTotal Money `json:"projected_total"`
+	Delta          Money `json:"delta"`
+}
+
+// MonthlyTotal - сумма подписок за один календарный месяц (YYYY-MM).
+type MonthlyTotal struct {
+	Month      string `json:"month"`
+	TotalPrice Money  `json:"total_price"`
+}
+
+// Шаг временного ряда агрегации (AggregateRequest.Granularity).
+const (
+	GranularityMonth   = "month"
+	GranularityQuarter = "quarter"
+	GranularityYear    = "year"
+)
+
+// BucketTotal - сумма подписок за интервал временного ряда. Period - YYYY-MM,
+// YYYY-Qn или YYYY в зависимости от шага, StartDate - первый день интервала.
+type BucketTotal struct {
+	Period     string `json:"period"`
+	StartDate  string `json:"start_date"`
+	TotalPrice Money  `json:"total_price"`
+}
+
+// AggregateMonthlyResponse - помесячная разбивка; TotalPrice заполняется при
+// include_total=true и всегда равен сумме Monthly. Buckets - тот же итог,
+// разложенный с шагом Granularity на весь запрошенный период, включая
+// интервалы без подписок.
+type AggregateMonthlyResponse struct {
+	TotalPrice  *Money         `json:"total_price,omitempty"`
+	Currency    string         `json:"currency,omitempty"`
+	Monthly     []MonthlyTotal `json:"monthly"`
+	Granularity string         `json:"granularity"`
+	Buckets     []BucketTotal  `json:"buckets"`
 }
 
 func (r *CreateSubscriptionRequest) ToSubscription() (*Subscription, error) {
+	id := uuid.New()
+	if r.ID != "" {
+		parsed, err := uuid.Parse(r.ID)
+		if err != nil {
+			return nil, err
+		}
+		id = parsed
+	}
+
 	userID, err := uuid.Parse(r.UserID)
 	if err != nil {
 		return nil, err
 	}
 
-	startDate, err := time.Parse("2006-01-02", r.StartDate)
-	if err != nil {
-		return nil, err
+	if r.StartDate == nil || r.StartDate.IsZero() {
+		return nil, errors.New("start_date is required")
 	}
 
 	sub := &Subscription{
-		ID:          uuid.New(),
+		ID:          id,
 		ServiceName: r.ServiceName,
-		Price:       r.Price,
+		Price:       Money(r.Price),
+		Currency:    r.Currency,
+		Tags:        r.Tags,
+		Description: r.Description,
 		UserID:      userID,
-		StartDate:   startDate,
+		StartDate:   r.StartDate.Time,
+		AutoRenew:   r.AutoRenew,
+		Metadata:    r.Metadata,
 	}
 
-	if r.EndDate != "" {
-		endDate, err := time.Parse("2006-01-02", r.EndDate)
-		if err != nil {
-			return nil, err
-		}
+	if sub.Tags == nil {
+		sub.Tags = []string{}
+	}
+
+	if sub.Metadata == nil {
+		sub.Metadata = map[string]interface{}{}
+	}
+
+	if r.EndDate != nil && !r.EndDate.IsZero() {
+		endDate := r.EndDate.Time
 		sub.EndDate = &endDate
 	}
 
 	return sub, nil
 }
+
+// SetComputedFields заполняет вычисляемые поля относительно даты today.
+func (s *Subscription) SetComputedFields(today time.Time) {
+	s.NextBillingDate = s.ComputeNextBillingDate(today)
+	s.DurationMonths, s.MonthsRemaining = s.ComputeDurations(today)
+	s.Status = s.StatusOn(today)
+}
+
+// StatusOn возвращает статус подписки на дату today: upcoming до start_date,
+// expired после end_date, иначе active. Совпадает с фасетом status списка.
+func (s *Subscription) StatusOn(today time.Time) string {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	switch {
+	case s.StartDate.After(today):
+		return StatusUpcoming
+	case s.EndDate != nil && s.EndDate.Before(today):
+		return StatusExpired
+	default:
+		return StatusActive
+	}
+}
+
+// ComputeDurations возвращает число полных месяцев, которые подписка уже
+// активна (от start_date до end_date или today), и число полных месяцев до
+// end_date (nil для бессрочной). Для ещё не начавшейся подписки длительность
+// равна 0, а остаток считается от start_date; для закончившейся остаток равен 0.
+func (s *Subscription) ComputeDurations(today time.Time) (int, *int) {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	start := time.Date(s.StartDate.Year(), s.StartDate.Month(), s.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+
+	activeUntil := today
+	if s.EndDate != nil && s.EndDate.Before(today) {
+		activeUntil = *s.EndDate
+	}
+	duration := fullMonthsBetween(start, activeUntil)
+
+	if s.EndDate == nil {
+		return duration, nil
+	}
+
+	from := today
+	if start.After(today) {
+		from = start
+	}
+	remaining := fullMonthsBetween(from, *s.EndDate)
+
+	return duration, &remaining
+}
+
+// fullMonthsBetween возвращает число полных месяцев от from до to (0, если to раньше from).
+func fullMonthsBetween(from, to time.Time) int {
+	if !to.After(from) {
+		return 0
+	}
+
+	months := (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	if AddMonths(from, months).After(to) {
+		months--
+	}
+	return months
+}
+
+// ComputeNextBillingDate возвращает ближайшую дату списания не раньше today при
+// ежемесячном цикле, или nil, если подписка к этой дате уже закончилась.
+// Даты списаний отсчитываются от start_date, а не от предыдущего списания:
+// для старта 31 января это 29 (28) февраля, затем снова 31 марта.
+func (s *Subscription) ComputeNextBillingDate(today time.Time) *time.Time {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	start := time.Date(s.StartDate.Year(), s.StartDate.Month(), s.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+
+	next := start
+	if today.After(start) {
+		months := (today.Year()-start.Year())*12 + int(today.Month()) - int(start.Month())
+		next = AddMonths(start, months)
+		if next.Before(today) {
+			next = AddMonths(start, months+1)
+		}
+	}
+
+	if s.EndDate != nil && next.After(*s.EndDate) {
+		return nil
+	}
+
+	return &next
+}
+
+// AddMonths прибавляет months месяцев, ограничивая день последним днём
+// целевого месяца (в отличие от time.AddDate, который переносит 31 января в 2 марта).
+func AddMonths(t time.Time, months int) time.Time {
+	firstOfTarget := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	lastDay := firstOfTarget.AddDate(0, 1, -1).Day()
+
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return time.Date(firstOfTarget.Year(), firstOfTarget.Month(), day, 0, 0, 0, 0, t.Location())
+}
+
+// DateIn возвращает календарную дату момента t в часовом поясе loc в том же
+// виде, в каком хранятся start_date и end_date: полночь в UTC.
+func DateIn(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// MonthStart возвращает первое число месяца даты t.
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
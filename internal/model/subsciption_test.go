@@ -0,0 +1,133 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionMarshalJSONTimestamps(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	endDate := time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		sub     Subscription
+		want    map[string]interface{}
+		missing []string
+	}{
+		{
+			name: "non-UTC timestamps are converted to UTC",
+			sub: Subscription{
+				StartDate: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+				CreatedAt: time.Date(2024, time.June, 1, 12, 30, 15, 0, moscow),
+				UpdatedAt: time.Date(2024, time.June, 2, 1, 0, 0, 0, moscow),
+			},
+			want: map[string]interface{}{
+				"start_date":        "2024-06-01",
+				"created_at":        "2024-06-01T09:30:15Z",
+				"updated_at":        "2024-06-01T22:00:00Z",
+				"next_billing_date": nil,
+			},
+			missing: []string{"end_date"},
+		},
+		{
+			name: "sub-second precision is dropped",
+			sub: Subscription{
+				StartDate: time.Date(2024, time.June, 15, 0, 0, 0, 0, time.UTC),
+				EndDate:   &endDate,
+				CreatedAt: time.Date(2024, time.June, 1, 12, 0, 0, 999999999, time.UTC),
+				UpdatedAt: time.Date(2024, time.June, 1, 12, 0, 0, 1000, time.UTC),
+			},
+			want: map[string]interface{}{
+				"start_date": "2024-06-15",
+				"end_date":   "2024-12-31",
+				"created_at": "2024-06-01T12:00:00Z",
+				"updated_at": "2024-06-01T12:00:00Z",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.sub)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+
+			var got map[string]interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+
+			for key, want := range tt.want {
+				if value, ok := got[key]; !ok || value != want {
+					t.Errorf("%s = %v, want %v", key, value, want)
+				}
+			}
+			for _, key := range tt.missing {
+				if _, ok := got[key]; ok {
+					t.Errorf("%s is present, want omitted", key)
+				}
+			}
+
+			for _, key := range []string{"created_at", "updated_at"} {
+				if _, err := time.Parse(time.RFC3339, got[key].(string)); err != nil {
+					t.Errorf("%s is not RFC3339: %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDateUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{`"2024-06-15"`, time.Date(2024, time.June, 15, 0, 0, 0, 0, time.UTC), false},
+		{`""`, time.Time{}, false},
+		{`"06-2024"`, time.Time{}, true},
+		{`"2024-06-15T00:00:00Z"`, time.Time{}, true},
+		{`20240615`, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var d Date
+			err := json.Unmarshal([]byte(tt.input), &d)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !d.Time.Equal(tt.want) || d.Location() != time.UTC {
+				t.Errorf("date = %v, want %v in UTC", d.Time, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateIn(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	newYork := time.FixedZone("EST", -5*60*60)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		loc  *time.Location
+		want string
+	}{
+		{"UTC", time.Date(2024, time.June, 15, 23, 59, 0, 0, time.UTC), time.UTC, "2024-06-15"},
+		{"ahead of UTC crosses midnight", time.Date(2024, time.June, 15, 22, 0, 0, 0, time.UTC), moscow, "2024-06-16"},
+		{"behind UTC crosses midnight", time.Date(2024, time.July, 1, 2, 0, 0, 0, time.UTC), newYork, "2024-06-30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DateIn(tt.t, tt.loc)
+			if got.Format(DateFormat) != tt.want || got.Location() != time.UTC || got.Hour() != 0 {
+				t.Errorf("DateIn = %v, want %s 00:00 UTC", got, tt.want)
+			}
+		})
+	}
+}
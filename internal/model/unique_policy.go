@@ -0,0 +1,16 @@
+package model
+
+// Политики уникальности подписок при создании (UNIQUE_POLICY).
+const (
+	// UniquePolicyNone - дубли разрешены (например, разные тарифы одного сервиса).
+	UniquePolicyNone = "none"
+	// UniquePolicyUserService - не больше одной подписки пользователя на сервис.
+	UniquePolicyUserService = "user_service"
+	// UniquePolicyUserServicePeriod - подписки пользователя на сервис не пересекаются по периоду.
+	UniquePolicyUserServicePeriod = "user_service_period"
+)
+
+// ValidUniquePolicy сообщает, поддерживается ли политика.
+func ValidUniquePolicy(policy string) bool {
+	return policy == UniquePolicyNone || policy == UniquePolicyUserService || policy == UniquePolicyUserServicePeriod
+}
@@ -0,0 +1,6 @@
+package notifier
+
+// Notifier доставляет текстовые отчёты получателям.
+type Notifier interface {
+	Send(subject, body string) error
+}
@@ -0,0 +1,57 @@
+package notifier
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+type SMTPNotifier struct {
+	host       string
+	port       int
+	username   string
+	password   string
+	from       string
+	recipients []string
+}
+
+func NewSMTPNotifier(host string, port int, username, password, from string, recipients []string) *SMTPNotifier {
+	return &SMTPNotifier{
+		host:       host,
+		port:       port,
+		username:   username,
+		password:   password,
+		from:       from,
+		recipients: recipients,
+	}
+}
+
+func (n *SMTPNotifier) Send(subject, body string) error {
+	if len(n.recipients) == 0 {
+		return fmt.Errorf("no recipients configured")
+	}
+
+	var auth smtp.Auth
+	if n.username != "" {
+		auth = smtp.PlainAuth("", n.username, n.password, n.host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + n.from,
+		"To: " + strings.Join(n.recipients, ", "),
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	addr := net.JoinHostPort(n.host, strconv.Itoa(n.port))
+	if err := smtp.SendMail(addr, auth, n.from, n.recipients, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
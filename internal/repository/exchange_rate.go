@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ExchangeRateRepository interface {
+	// GetRate возвращает последний известный курс валюты на дату date или раньше.
+	GetRate(currency string, date time.Time) (*model.ExchangeRate, error)
+}
+
+type exchangeRateRepository struct {
+	db *sql.DB
+}
+
+func NewExchangeRateRepository(db *sql.DB) ExchangeRateRepository {
+	return &exchangeRateRepository{db: db}
+}
+
+func (r *exchangeRateRepository) GetRate(currency string, date time.Time) (*model.ExchangeRate, error) {
+	query := `
+        SELECT currency, date, rate_to_base
+        FROM exchange_rates
+        WHERE currency = $1 AND date <= $2
+        ORDER BY date DESC
+        LIMIT 1
+    `
+
+	var rate model.ExchangeRate
+	err := r.db.QueryRow(query, currency, date).Scan(&rate.Currency, &rate.Date, &rate.RateToBase)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		logrus.WithError(err).WithField("currency", currency).Error("Failed to get exchange rate")
+		return nil, fmt.Errorf("failed to get exchange rate: %w", err)
+	}
+
+	return &rate, nil
+}
@@ -0,0 +1,1072 @@
+//go:build integration
+
+// Интеграционные тесты репозиториев на настоящем PostgreSQL:
+//
+//	go test -tags integration ./internal/repository/
+//
+// База берётся из DATABASE_URL, а если он не задан, поднимается контейнер
+// postgres:15-alpine через testcontainers-go; без Docker тесты пропускаются.
+// Прогон идёт в отдельной схеме, которая удаляется после тестов, поэтому
+// DATABASE_URL может указывать и на базу разработки.
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+	"subscription_service/internal/service"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var integration struct {
+	db *sql.DB
+	// skip - причина пропуска тестов, если базы нет.
+	skip string
+}
+
+func TestMain(m *testing.M) {
+	logrus.SetLevel(logrus.WarnLevel)
+
+	cleanup, err := setupDatabase(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "integration setup: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	cleanup()
+	os.Exit(code)
+}
+
+// setupDatabase подключается к базе, создаёт схему прогона и применяет миграции.
+func setupDatabase(ctx context.Context) (func(), error) {
+	baseURL := os.Getenv("DATABASE_URL")
+	stopContainer := func() {}
+
+	if baseURL == "" {
+		var err error
+		baseURL, stopContainer, err = startPostgres(ctx)
+		if err != nil {
+			integration.skip = fmt.Sprintf("DATABASE_URL is not set and Postgres container is unavailable: %v", err)
+			return func() {}, nil
+		}
+	}
+
+	admin, err := sql.Open("postgres", baseURL)
+	if err != nil {
+		stopContainer()
+		return nil, err
+	}
+
+	schema := fmt.Sprintf("integration_%d", time.Now().UnixNano())
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		stopContainer()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+
+	cleanup := func() {
+		if integration.db != nil {
+			integration.db.Close()
+		}
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			fmt.Fprintf(os.Stderr, "drop schema %s: %v\n", schema, err)
+		}
+		admin.Close()
+		stopContainer()
+	}
+
+	schemaURL, err := withSearchPath(baseURL, schema)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	if err := migrateUp(schemaURL); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	integration.db, err = sql.Open("postgres", schemaURL)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	return cleanup, nil
+}
+
+// startPostgres поднимает контейнер с той же версией PostgreSQL, что и docker-compose.
+func startPostgres(ctx context.Context) (dsn string, stop func(), err error) {
+	// testcontainers паникует, если не находит Docker.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "postgres:15-alpine",
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_USER":     "postgres",
+				"POSTGRES_PASSWORD": "postgres",
+				"POSTGRES_DB":       "subscription_db",
+			},
+			// Сервер перезапускается после initdb, готов - второе сообщение.
+			WaitingFor: wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	stop = func() {
+		if err := testcontainers.TerminateContainer(container); err != nil {
+			fmt.Fprintf(os.Stderr, "terminate postgres container: %v\n", err)
+		}
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+	port, err := container.MappedPort(ctx, "5432")
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("postgres://postgres:postgres@%s:%s/subscription_db?sslmode=disable", host, port.Port()), stop, nil
+}
+
+// withSearchPath добавляет к DSN search_path: lib/pq передаёт неизвестные
+// параметры серверу, и миграции и запросы работают в схеме прогона.
+func withSearchPath(dsn, schema string) (string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("parse database url: %w", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func migrateUp(dsn string) error {
+	dir, err := filepath.Abs("../../migrations")
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.New("file://"+dir, dsn)
+	if err != nil {
+		return fmt.Errorf("init migrations: %w", err)
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("apply migrations: %w", err)
+	}
+	return nil
+}
+
+// testDB возвращает базу с пустыми таблицами или пропускает тест.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	if integration.db == nil {
+		t.Skip(integration.skip)
+	}
+
+	_, err := integration.db.Exec(`
+        TRUNCATE subscriptions, subscriptions_archive, subscription_renewals,
+                 subscription_summaries, subscription_transfers, outbox, exchange_rates
+        RESTART IDENTITY`)
+	if err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	return integration.db
+}
+
+func day(s string) time.Time {
+	t, err := time.Parse(model.DateFormat, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// newSub собирает подписку; end "" - бессрочная.
+func newSub(userID uuid.UUID, serviceName string, price int, start, end string) *model.Subscription {
+	sub := &model.Subscription{
+		ID:          uuid.New(),
+		ServiceName: serviceName,
+		Price:       model.Money(price),
+		Currency:    "RUB",
+		Tags:        []string{},
+		UserID:      userID,
+		StartDate:   day(start),
+	}
+	if end != "" {
+		endDate := day(end)
+		sub.EndDate = &endDate
+	}
+	return sub
+}
+
+func mustCreate(t *testing.T, repo repository.SubscriptionRepository, subs ...*model.Subscription) {
+	t.Helper()
+	for _, sub := range subs {
+		if err := repo.Create(context.Background(), sub, repository.UserLimit{}); err != nil {
+			t.Fatalf("Create %s: %v", sub.ServiceName, err)
+		}
+	}
+}
+
+func newService(db *sql.DB, opts service.Options) service.SubscriptionService {
+	converter := service.NewCurrencyConverter(nil, "RUB", time.Hour, false)
+	return service.NewSubscriptionService(repository.NewSubscriptionRepository(db, false, 0), converter, opts)
+}
+
+func ids(subs []*model.Subscription) []uuid.UUID {
+	out := make([]uuid.UUID, len(subs))
+	for i, sub := range subs {
+		out[i] = sub.ID
+	}
+	return out
+}
+
+func serviceNames(subs []*model.Subscription) []string {
+	out := make([]string, len(subs))
+	for i, sub := range subs {
+		out[i] = sub.ServiceName
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestCreateAndGetByID(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	sub := newSub(uuid.New(), "Yandex Plus", 400, "2024-01-15", "")
+	sub.Tags = []string{"music", "family"}
+	sub.Metadata = map[string]interface{}{"source": "import"}
+	mustCreate(t, repo, sub)
+
+	got, err := repo.GetByID(ctx, sub.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetByID = %v, %v", got, err)
+	}
+	if got.ServiceName != sub.ServiceName || got.Price != 400 || got.Currency != "RUB" || got.Version != 1 {
+		t.Errorf("stored %+v, want %+v", got, sub)
+	}
+	if got.StartDate.Format(model.DateFormat) != "2024-01-15" || got.EndDate != nil {
+		t.Errorf("dates = %v - %v, want 2024-01-15 - open", got.StartDate, got.EndDate)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "music" || got.Metadata["source"] != "import" {
+		t.Errorf("tags = %v, metadata = %v", got.Tags, got.Metadata)
+	}
+
+	if err := repo.Create(ctx, sub, repository.UserLimit{}); !errors.Is(err, repository.ErrDuplicateID) {
+		t.Errorf("second Create error = %v, want ErrDuplicateID", err)
+	}
+
+	missing, err := repo.GetByID(ctx, uuid.New())
+	if missing != nil || err != nil {
+		t.Errorf("GetByID(missing) = %v, %v, want nil, nil", missing, err)
+	}
+}
+
+func TestCreateIfNotExistsConcurrentCallsCreateOnce(t *testing.T) {
+	db := testDB(t)
+	svc := newService(db, service.Options{Retry: service.RetryPolicy{Attempts: 5, Backoff: 10 * time.Millisecond}})
+	userID := uuid.NewString()
+
+	const callers = 8
+	var wg sync.WaitGroup
+	results := make([]*model.Subscription, callers)
+	created := make([]bool, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], created[i], errs[i] = svc.CreateIfNotExists(context.Background(), &model.CreateSubscriptionRequest{
+				ServiceName: "Netflix",
+				Price:       799,
+				UserID:      userID,
+				StartDate:   &model.Date{Time: day("2024-06-01")},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	createdCount := 0
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: %v", i, errs[i])
+		}
+		if created[i] {
+			createdCount++
+		}
+		if results[i].ID != results[0].ID {
+			t.Errorf("caller %d got %s, caller 0 got %s", i, results[i].ID, results[0].ID)
+		}
+	}
+	if createdCount != 1 {
+		t.Errorf("%d callers created the subscription, want 1", createdCount)
+	}
+
+	var rows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM subscriptions`).Scan(&rows); err != nil || rows != 1 {
+		t.Errorf("subscriptions = %d (%v), want 1", rows, err)
+	}
+}
+
+func TestUserLimitHoldsUnderConcurrentWrites(t *testing.T) {
+	db := testDB(t)
+	const limit = 3
+	svc := newService(db, service.Options{
+		MaxActivePerUser: limit,
+		Retry:            service.RetryPolicy{Attempts: 5, Backoff: 10 * time.Millisecond},
+	})
+	ctx := context.Background()
+	full := uuid.NewString()
+
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = svc.Create(ctx, &model.CreateSubscriptionRequest{
+				ServiceName: fmt.Sprintf("Service %d", i),
+				Price:       100,
+				UserID:      full,
+				StartDate:   &model.Date{Time: day("2024-01-01")},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		var limitErr *service.LimitExceededError
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.As(err, &limitErr):
+			t.Errorf("caller %d: %v, want success or LimitExceededError", i, err)
+		}
+	}
+	if succeeded != limit {
+		t.Errorf("%d creates succeeded, want %d", succeeded, limit)
+	}
+
+	other, err := svc.Create(ctx, &model.CreateSubscriptionRequest{
+		ServiceName: "Spotify",
+		Price:       169,
+		UserID:      uuid.NewString(),
+		StartDate:   &model.Date{Time: day("2024-01-01")},
+	})
+	if err != nil {
+		t.Fatalf("Create for another user: %v", err)
+	}
+
+	var limitErr *service.LimitExceededError
+	if _, err := svc.Update(ctx, other.ID.String(), &model.UpdateSubscriptionRequest{UserID: &full}, nil); !errors.As(err, &limitErr) {
+		t.Errorf("Update reassigning to a full user: %v, want LimitExceededError", err)
+	}
+	if _, err := svc.Transfer(ctx, other.ID.String(), full); !errors.As(err, &limitErr) {
+		t.Errorf("Transfer to a full user: %v, want LimitExceededError", err)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM subscriptions WHERE user_id = $1`, full).Scan(&count); err != nil || count != limit {
+		t.Errorf("user has %d subscriptions (%v), want %d", count, err, limit)
+	}
+}
+
+func TestUpdateVersionsAndNoops(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	sub := newSub(uuid.New(), "Kinopoisk", 299, "2024-01-01", "")
+	mustCreate(t, repo, sub)
+
+	stale := 7
+	_, err := repo.Update(ctx, sub.ID, map[string]interface{}{"price": 399}, &stale, false, repository.UserLimit{})
+	if !errors.Is(err, repository.ErrVersionConflict) {
+		t.Errorf("Update with stale version: %v, want ErrVersionConflict", err)
+	}
+
+	current := 1
+	updated, err := repo.Update(ctx, sub.ID, map[string]interface{}{"price": 399, "tags": []string{"video"}}, &current, false, repository.UserLimit{})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Price != 399 || updated.Version != 2 || len(updated.Tags) != 1 {
+		t.Errorf("updated = %+v, want price 399, version 2, one tag", updated)
+	}
+
+	unchanged, err := repo.Update(ctx, sub.ID, map[string]interface{}{"price": 399}, nil, true, repository.UserLimit{})
+	if !errors.Is(err, repository.ErrNotModified) || unchanged.Version != 2 {
+		t.Errorf("no-op Update = %v, %v, want version 2 with ErrNotModified", unchanged, err)
+	}
+
+	if _, err := repo.Update(ctx, uuid.New(), map[string]interface{}{"price": 1}, nil, false, repository.UserLimit{}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Update(missing) = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestDeleteChecksVersion(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	sub := newSub(uuid.New(), "Okko", 199, "2024-01-01", "2024-12-01")
+	mustCreate(t, repo, sub)
+
+	stale := 3
+	if _, err := repo.Delete(ctx, sub.ID, &stale); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Errorf("Delete with stale version: %v, want ErrVersionConflict", err)
+	}
+
+	deleted, err := repo.Delete(ctx, sub.ID, nil)
+	if err != nil || deleted.ID != sub.ID {
+		t.Fatalf("Delete = %v, %v", deleted, err)
+	}
+	if _, err := repo.Delete(ctx, sub.ID, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second Delete = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestTransferRecordsJournal(t *testing.T) {
+	db := testDB(t)
+	repo := repository.NewSubscriptionRepository(db, false, 0)
+	ctx := context.Background()
+
+	from, to := uuid.New(), uuid.New()
+	sub := newSub(from, "IVI", 399, "2024-01-01", "")
+	mustCreate(t, repo, sub)
+
+	errRejected := errors.New("rejected")
+	if _, err := repo.Transfer(ctx, sub.ID, to, repository.UserLimit{}, func(*model.Subscription) error { return errRejected }); !errors.Is(err, errRejected) {
+		t.Fatalf("Transfer with failing check = %v, want %v", err, errRejected)
+	}
+
+	transferred, err := repo.Transfer(ctx, sub.ID, to, repository.UserLimit{}, func(current *model.Subscription) error {
+		if current.UserID != from {
+			t.Errorf("check got owner %s, want %s", current.UserID, from)
+		}
+		return nil
+	})
+	if err != nil || transferred.UserID != to || transferred.Version != 2 {
+		t.Fatalf("Transfer = %+v, %v", transferred, err)
+	}
+
+	var fromUser, toUser uuid.UUID
+	if err := db.QueryRow(`SELECT from_user_id, to_user_id FROM subscription_transfers WHERE subscription_id = $1`, sub.ID).Scan(&fromUser, &toUser); err != nil {
+		t.Fatalf("transfer journal: %v", err)
+	}
+	if fromUser != from || toUser != to {
+		t.Errorf("journal %s -> %s, want %s -> %s", fromUser, toUser, from, to)
+	}
+
+	if _, err := repo.Transfer(ctx, uuid.New(), to, repository.UserLimit{}, func(*model.Subscription) error { return nil }); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Transfer(missing) = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestFindDuplicateByPolicy(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	existing := newSub(userID, "Netflix", 799, "2024-01-01", "2024-03-01")
+	mustCreate(t, repo, existing)
+
+	tests := []struct {
+		name   string
+		policy string
+		start  string
+		want   bool
+	}{
+		{"same service", model.UniquePolicyUserService, "2025-01-01", true},
+		{"overlapping period", model.UniquePolicyUserServicePeriod, "2024-03-01", true},
+		{"later period", model.UniquePolicyUserServicePeriod, "2024-03-02", false},
+		{"no policy", model.UniquePolicyNone, "2024-01-01", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := repo.FindDuplicate(ctx, newSub(userID, "Netflix", 799, tt.start, ""), tt.policy)
+			if err != nil {
+				t.Fatalf("FindDuplicate: %v", err)
+			}
+			if (found != nil) != tt.want {
+				t.Errorf("found = %v, want duplicate %t", found, tt.want)
+			}
+		})
+	}
+}
+
+func TestListServiceNameMatchingEscapesLikeSyntax(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	mustCreate(t, repo,
+		newSub(userID, "Yandex Plus", 400, "2024-01-01", ""),
+		newSub(userID, "Plus_One", 100, "2024-01-01", ""),
+		newSub(userID, "PlusXOne", 100, "2024-01-01", ""),
+		newSub(userID, "100% Music", 100, "2024-01-01", ""),
+	)
+
+	tests := []struct {
+		name   string
+		filter model.ServiceNameFilter
+		want   []string
+	}{
+		{"substring ignores case", model.ServiceNameFilter{Values: []string{"plus"}}, []string{"Plus_One", "PlusXOne", "Yandex Plus"}},
+		{"underscore is literal", model.ServiceNameFilter{Values: []string{"s_o"}}, []string{"Plus_One"}},
+		{"percent is literal", model.ServiceNameFilter{Values: []string{"100%"}}, []string{"100% Music"}},
+		{"exact ignores case", model.ServiceNameFilter{Values: []string{"yandex plus"}, Exact: true}, []string{"Yandex Plus"}},
+		{"exact does not match substrings", model.ServiceNameFilter{Values: []string{"plus"}, Exact: true}, []string{}},
+		{"any of several values", model.ServiceNameFilter{Values: []string{"music", "yandex"}}, []string{"100% Music", "Yandex Plus"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			subs, total, _, err := repo.List(ctx, model.SubscriptionFilter{ServiceName: &filter, Limit: 10})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			got := serviceNames(subs)
+			if total != len(tt.want) || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("List = %v (total %d), want %v", got, total, tt.want)
+			}
+
+			// Aggregate фильтрует по тем же шаблонам.
+			sum, err := repo.Aggregate(ctx, day("2024-01-01"), day("2024-02-01"), nil, &filter)
+			if err != nil {
+				t.Fatalf("Aggregate: %v", err)
+			}
+			wantSum := 0
+			for _, name := range tt.want {
+				if name == "Yandex Plus" {
+					wantSum += 400
+				} else {
+					wantSum += 100
+				}
+			}
+			if sum != wantSum {
+				t.Errorf("Aggregate = %d, want %d", sum, wantSum)
+			}
+		})
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	open := newSub(userID, "Open", 100, "2024-01-01", "")
+	open.Tags = []string{"music"}
+	open.Metadata = map[string]interface{}{"plan": "family"}
+	ended := newSub(userID, "Ended", 100, "2024-01-01", "2024-06-01")
+	later := newSub(uuid.New(), "Later", 100, "2025-01-01", "2025-02-01")
+	mustCreate(t, repo, open, ended, later)
+
+	yes, no := true, false
+	activeOn := day("2030-01-01")
+	from, to := day("2024-01-01"), day("2024-12-31")
+	tag := "music"
+
+	tests := []struct {
+		name   string
+		filter model.SubscriptionFilter
+		want   []string
+	}{
+		{"user", model.SubscriptionFilter{UserID: &userID}, []string{"Ended", "Open"}},
+		{"open-ended active on a far date", model.SubscriptionFilter{ActiveOn: &activeOn}, []string{"Open"}},
+		{"open ended", model.SubscriptionFilter{OpenEnded: &yes}, []string{"Open"}},
+		{"with end date", model.SubscriptionFilter{OpenEnded: &no}, []string{"Ended", "Later"}},
+		{"start date range", model.SubscriptionFilter{StartDate: &from, EndDate: &to}, []string{"Ended", "Open"}},
+		{"tag", model.SubscriptionFilter{Tag: &tag}, []string{"Open"}},
+		{"metadata", model.SubscriptionFilter{Metadata: map[string]string{"plan": "family"}}, []string{"Open"}},
+		{"ids", model.SubscriptionFilter{IDs: []uuid.UUID{later.ID, ended.ID}}, []string{"Ended", "Later"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Limit = 10
+			subs, total, _, err := repo.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if got := serviceNames(subs); total != len(tt.want) || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("List = %v (total %d), want %v", got, total, tt.want)
+			}
+
+			var exported []*model.Subscription
+			tt.filter.Limit = 0
+			err = repo.Export(ctx, tt.filter, func(sub *model.Subscription) error {
+				exported = append(exported, sub)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			if got := serviceNames(exported); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Export = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListPaginationBoundaries(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	for i := 1; i <= 5; i++ {
+		mustCreate(t, repo, newSub(userID, fmt.Sprintf("Service %d", i), 100, fmt.Sprintf("2024-0%d-01", i), ""))
+	}
+
+	tests := []struct {
+		offset   int
+		wantRows int
+	}{
+		{0, 2},
+		{2, 2},
+		{4, 1},
+		{5, 0},
+		{50, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("offset %d", tt.offset), func(t *testing.T) {
+			filter := model.SubscriptionFilter{Sort: "start_date", Limit: 2, Offset: tt.offset}
+
+			subs, total, _, err := repo.List(ctx, filter)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(subs) != tt.wantRows || total != 5 {
+				t.Errorf("List = %d rows, total %d; want %d rows, total 5", len(subs), total, tt.wantRows)
+			}
+			if len(subs) > 0 {
+				if want := fmt.Sprintf("Service %d", tt.offset+1); subs[0].ServiceName != want {
+					t.Errorf("page starts with %s, want %s", subs[0].ServiceName, want)
+				}
+			}
+
+			refs, total, err := repo.ListRefs(ctx, filter)
+			if err != nil {
+				t.Fatalf("ListRefs: %v", err)
+			}
+			if len(refs) != tt.wantRows || total != 5 {
+				t.Errorf("ListRefs = %d rows, total %d; want %d rows, total 5", len(refs), total, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestListPagesOverTiedStartDatesWithoutGapsOrDuplicates(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	want := make(map[uuid.UUID]bool)
+	for i := 0; i < 7; i++ {
+		sub := newSub(userID, "Netflix", 799, "2024-01-01", "")
+		mustCreate(t, repo, sub)
+		want[sub.ID] = true
+	}
+
+	for _, order := range []string{"", "start_date", "-start_date"} {
+		t.Run("sort "+order, func(t *testing.T) {
+			seen := make(map[uuid.UUID]bool)
+			seenRefs := make(map[uuid.UUID]bool)
+			for offset := 0; offset < len(want); offset += 2 {
+				filter := model.SubscriptionFilter{Sort: order, Limit: 2, Offset: offset}
+
+				subs, _, _, err := repo.List(ctx, filter)
+				if err != nil {
+					t.Fatalf("List offset %d: %v", offset, err)
+				}
+				for _, sub := range subs {
+					if seen[sub.ID] {
+						t.Errorf("List: %s returned twice", sub.ID)
+					}
+					seen[sub.ID] = true
+				}
+
+				refs, _, err := repo.ListRefs(ctx, filter)
+				if err != nil {
+					t.Fatalf("ListRefs offset %d: %v", offset, err)
+				}
+				for _, ref := range refs {
+					if seenRefs[ref.ID] {
+						t.Errorf("ListRefs: %s returned twice", ref.ID)
+					}
+					seenRefs[ref.ID] = true
+				}
+			}
+
+			for id := range want {
+				if !seen[id] {
+					t.Errorf("List: %s is missing from all pages", id)
+				}
+				if !seenRefs[id] {
+					t.Errorf("ListRefs: %s is missing from all pages", id)
+				}
+			}
+		})
+	}
+}
+
+func TestListExpiringWindowIsInclusive(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	mustCreate(t, repo,
+		newSub(userID, "Before", 100, "2024-01-01", "2024-05-31"),
+		newSub(userID, "First day", 100, "2024-01-01", "2024-06-01"),
+		newSub(userID, "Last day", 100, "2024-01-01", "2024-06-30"),
+		newSub(userID, "After", 100, "2024-01-01", "2024-07-01"),
+		newSub(userID, "Open", 100, "2024-01-01", ""),
+	)
+
+	subs, total, err := repo.ListExpiring(ctx, day("2024-06-01"), day("2024-06-30"), 1, 0)
+	if err != nil {
+		t.Fatalf("ListExpiring: %v", err)
+	}
+	if total != 2 || len(subs) != 1 || subs[0].ServiceName != "First day" {
+		t.Errorf("first page = %v (total %d), want [First day] of 2", serviceNames(subs), total)
+	}
+
+	subs, total, err = repo.ListExpiring(ctx, day("2024-06-01"), day("2024-06-30"), 1, 2)
+	if err != nil {
+		t.Fatalf("ListExpiring past the end: %v", err)
+	}
+	if total != 2 || len(subs) != 0 {
+		t.Errorf("page past the end = %v (total %d), want empty of 2", serviceNames(subs), total)
+	}
+}
+
+func TestAggregatesCountOverlappingMonths(t *testing.T) {
+	db := testDB(t)
+	repo := repository.NewSubscriptionRepository(db, false, 0)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	mustCreate(t, repo,
+		// Бессрочная: учитывается до конца периода - 6 месяцев.
+		newSub(userID, "Open", 100, "2024-01-01", ""),
+		// Внутри периода: март и апрель.
+		newSub(userID, "Spring", 200, "2024-03-01", "2024-05-01"),
+		// Закончилась до начала периода.
+		newSub(userID, "Past", 50, "2023-01-01", "2023-12-01"),
+		// Началась после конца периода.
+		newSub(userID, "Future", 1000, "2024-08-01", ""),
+	)
+	mustCreate(t, repo, newSub(uuid.New(), "Other user", 300, "2024-01-01", "2024-02-01"))
+
+	from, to := day("2024-01-01"), day("2024-07-01")
+
+	total, err := repo.Aggregate(ctx, from, to, &userID, nil)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	if total != 100*6+200*2 {
+		t.Errorf("Aggregate = %d, want %d", total, 100*6+200*2)
+	}
+
+	byCurrency, err := repo.AggregateByCurrency(ctx, from, to, &userID, nil)
+	if err != nil || byCurrency["RUB"] != total {
+		t.Errorf("AggregateByCurrency = %v, %v, want RUB %d", byCurrency, err, total)
+	}
+
+	byUsers, err := repo.AggregateByUsers(ctx, from, to, []uuid.UUID{userID}, nil)
+	if err != nil || byUsers[userID] != total || len(byUsers) != 1 {
+		t.Errorf("AggregateByUsers = %v, %v, want %s: %d", byUsers, err, userID, total)
+	}
+
+	months, err := repo.AggregateByMonth(ctx, from, to, &userID, nil)
+	if err != nil {
+		t.Fatalf("AggregateByMonth: %v", err)
+	}
+	sum := 0
+	for _, m := range months {
+		sum += m.Total
+		if m.Month.Format(model.DateFormat) == "2024-03-01" && m.Total != 300 {
+			t.Errorf("March total = %d, want 300", m.Total)
+		}
+	}
+	if len(months) != 6 || sum != total {
+		t.Errorf("AggregateByMonth = %d months summing to %d, want 6 months summing to %d", len(months), sum, total)
+	}
+
+	matrix, err := repo.AggregateMatrix(ctx, from, to, &userID, nil, 10)
+	if err != nil || len(matrix) != 2 {
+		t.Errorf("AggregateMatrix = %v, %v, want Open and Spring", matrix, err)
+	}
+
+	newPrice := 0
+	current, projected, err := repo.SimulatePriceChange(ctx, from, to, &userID, nil, &newPrice, nil)
+	if err != nil || current != total || projected != 0 {
+		t.Errorf("SimulatePriceChange = %d, %d, %v, want %d, 0", current, projected, err, total)
+	}
+
+	points, err := repo.Timeline(ctx, from, day("2024-06-01"), &userID, nil)
+	if err != nil {
+		t.Fatalf("Timeline: %v", err)
+	}
+	counts := make([]int, len(points))
+	for i, p := range points {
+		counts[i] = p.Count
+	}
+	if fmt.Sprint(counts) != "[1 1 2 2 1 1]" {
+		t.Errorf("Timeline = %v, want [1 1 2 2 1 1]", counts)
+	}
+
+	// Сводку пишет триггер; при выровненных по месяцу датах она даёт те же суммы.
+	summaries := repository.NewSummaryRepository(db)
+	totals, err := summaries.TotalsByCurrency(ctx, from, to, &userID)
+	if err != nil || totals["RUB"] != total {
+		t.Errorf("TotalsByCurrency = %v, %v, want RUB %d", totals, err, total)
+	}
+
+	if _, err := summaries.Rebuild(); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	totals, err = summaries.TotalsByCurrency(ctx, from, to, &userID)
+	if err != nil || totals["RUB"] != total {
+		t.Errorf("TotalsByCurrency after Rebuild = %v, %v, want RUB %d", totals, err, total)
+	}
+}
+
+func TestFacetTopAndMonthlyCost(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	mustCreate(t, repo,
+		newSub(userID, "Active", 300, "2024-01-01", ""),
+		newSub(userID, "Expired", 500, "2023-01-01", "2023-06-01"),
+		newSub(userID, "Upcoming", 100, "2025-01-01", ""),
+	)
+	today := day("2024-06-15")
+
+	statuses, err := repo.Facet(ctx, model.SubscriptionFilter{UserID: &userID}, model.FacetStatus, today)
+	if err != nil {
+		t.Fatalf("Facet: %v", err)
+	}
+	if statuses[model.StatusActive] != 1 || statuses[model.StatusExpired] != 1 || statuses[model.StatusUpcoming] != 1 {
+		t.Errorf("status facet = %v, want one of each", statuses)
+	}
+
+	top, err := repo.Top(ctx, model.TopByPrice, 2, today, &userID, nil)
+	if err != nil {
+		t.Fatalf("Top: %v", err)
+	}
+	if got := fmt.Sprint(serviceNamesInOrder(top)); got != "[Expired Active]" {
+		t.Errorf("Top by price = %s, want [Expired Active]", got)
+	}
+
+	cost, err := repo.MonthlyCostByUser(ctx, userID, today)
+	if err != nil || cost["RUB"] != 300 {
+		t.Errorf("MonthlyCostByUser = %v, %v, want RUB 300", cost, err)
+	}
+}
+
+func serviceNamesInOrder(subs []*model.Subscription) []string {
+	out := make([]string, len(subs))
+	for i, sub := range subs {
+		out[i] = sub.ServiceName
+	}
+	return out
+}
+
+func TestChangesPagesByUpdatedAtAndID(t *testing.T) {
+	repo := repository.NewSubscriptionRepository(testDB(t), false, 0)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	for i := 0; i < 3; i++ {
+		mustCreate(t, repo, newSub(userID, fmt.Sprintf("Service %d", i), 100, "2024-01-01", ""))
+	}
+
+	first, err := repo.Changes(ctx, time.Time{}, nil, 2)
+	if err != nil || len(first) != 2 {
+		t.Fatalf("Changes first page = %d rows, %v", len(first), err)
+	}
+
+	last := first[len(first)-1]
+	rest, err := repo.Changes(ctx, last.UpdatedAt, &last.ID, 2)
+	if err != nil {
+		t.Fatalf("Changes second page: %v", err)
+	}
+	seen := append(ids(first), ids(rest)...)
+	if len(seen) != 3 {
+		t.Errorf("pages returned %d subscriptions, want 3", len(seen))
+	}
+}
+
+func TestPruneAndRenewal(t *testing.T) {
+	db := testDB(t)
+	repo := repository.NewSubscriptionRepository(db, false, 0)
+
+	userID := uuid.New()
+	expired := newSub(userID, "Expired", 100, "2024-01-01", "2024-02-01")
+	renewing := newSub(userID, "Renewing", 100, "2024-01-31", "2024-02-29")
+	renewing.AutoRenew = true
+	mustCreate(t, repo, expired, renewing)
+
+	archived, err := repository.NewPruneRepository(db, false).ArchiveExpiredBatch(day("2024-06-01"), 10)
+	if err != nil || archived != 1 {
+		t.Fatalf("ArchiveExpiredBatch = %d, %v, want 1", archived, err)
+	}
+	var inArchive int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM subscriptions_archive WHERE id = $1`, expired.ID).Scan(&inArchive); err != nil || inArchive != 1 {
+		t.Errorf("archived rows = %d (%v), want 1", inArchive, err)
+	}
+
+	renewals := repository.NewRenewalRepository(db, false)
+	renewed, err := renewals.RenewDueBatch(day("2024-03-10"), 10)
+	if err != nil || len(renewed) != 1 {
+		t.Fatalf("RenewDueBatch = %v, %v, want one renewal", renewed, err)
+	}
+	// Продление отсчитывается от start_date: 31 января -> 31 марта.
+	if got := renewed[0].NewEndDate.Format(model.DateFormat); got != "2024-03-31" {
+		t.Errorf("new end_date = %s, want 2024-03-31", got)
+	}
+	if again, err := renewals.RenewDueBatch(day("2024-03-10"), 10); err != nil || len(again) != 0 {
+		t.Errorf("second RenewDueBatch = %v, %v, want no renewals", again, err)
+	}
+
+	deleted, err := repository.NewPruneRepository(db, false).DeleteExpiredBatch(day("2030-01-01"), 10)
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteExpiredBatch = %d, %v, want 0: auto_renew subscriptions are kept", deleted, err)
+	}
+}
+
+func TestOutboxRelay(t *testing.T) {
+	db := testDB(t)
+	repo := repository.NewSubscriptionRepository(db, true, 0)
+	ctx := context.Background()
+
+	sub := newSub(uuid.New(), "Netflix", 799, "2024-01-01", "")
+	mustCreate(t, repo, sub)
+	if _, err := repo.Update(ctx, sub.ID, map[string]interface{}{"price": 899}, nil, false, repository.UserLimit{}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	outbox := repository.NewOutboxRepository(db)
+	var events []string
+	sent, err := outbox.RelayBatch(10, func(event model.OutboxEvent) error {
+		if event.AggregateID != sub.ID {
+			t.Errorf("event for %s, want %s", event.AggregateID, sub.ID)
+		}
+		events = append(events, event.EventType)
+		return nil
+	})
+	if err != nil || sent != 2 {
+		t.Fatalf("RelayBatch = %d, %v, want 2", sent, err)
+	}
+	if fmt.Sprint(events) != fmt.Sprint([]string{model.EventSubscriptionCreated, model.EventSubscriptionUpdated}) {
+		t.Errorf("events = %v, want created then updated", events)
+	}
+
+	if again, err := outbox.RelayBatch(10, func(model.OutboxEvent) error { return nil }); err != nil || again != 0 {
+		t.Errorf("second RelayBatch = %d, %v, want nothing to send", again, err)
+	}
+
+	purged, err := outbox.PurgeSentBatch(time.Now().Add(time.Hour), 10)
+	if err != nil || purged != 2 {
+		t.Errorf("PurgeSentBatch = %d, %v, want 2", purged, err)
+	}
+}
+
+func TestRecomputeNormalizesOnce(t *testing.T) {
+	db := testDB(t)
+	repo := repository.NewSubscriptionRepository(db, false, 0)
+
+	sub := newSub(uuid.New(), "Netflix", 799, "2024-01-01", "")
+	sub.Tags = []string{" Music ", "music", ""}
+	sub.Currency = "usd"
+	mustCreate(t, repo, sub)
+
+	recompute := repository.NewRecomputeRepository(db)
+	last, scanned, updated, err := recompute.RecomputeBatch(uuid.Nil, 10, "RUB")
+	if err != nil || last != sub.ID || scanned != 1 || updated != 1 {
+		t.Fatalf("RecomputeBatch = %s, %d, %d, %v; want %s, 1, 1", last, scanned, updated, err, sub.ID)
+	}
+
+	got, err := repo.GetByID(context.Background(), sub.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if fmt.Sprint(got.Tags) != "[music]" || got.Currency != "USD" {
+		t.Errorf("recomputed tags = %v, currency = %q; want [music], USD", got.Tags, got.Currency)
+	}
+
+	if _, _, updated, err := recompute.RecomputeBatch(uuid.Nil, 10, "RUB"); err != nil || updated != 0 {
+		t.Errorf("second RecomputeBatch updated %d (%v), want 0", updated, err)
+	}
+}
+
+func TestExchangeRateAndMaintenance(t *testing.T) {
+	db := testDB(t)
+
+	_, err := db.Exec(`INSERT INTO exchange_rates (currency, date, rate_to_base) VALUES ('USD', '2024-01-01', 90), ('USD', '2024-02-01', 92)`)
+	if err != nil {
+		t.Fatalf("insert rates: %v", err)
+	}
+
+	rates := repository.NewExchangeRateRepository(db)
+	rate, err := rates.GetRate("USD", day("2024-01-20"))
+	if err != nil || rate == nil || rate.RateToBase != 90 {
+		t.Errorf("GetRate(2024-01-20) = %+v, %v, want 90", rate, err)
+	}
+	if rate, err := rates.GetRate("USD", day("2023-12-31")); err != nil || rate != nil {
+		t.Errorf("GetRate before first rate = %+v, %v, want nil", rate, err)
+	}
+
+	maintenance := repository.NewMaintenanceRepository(db)
+	if err := maintenance.Analyze(); err != nil {
+		t.Errorf("Analyze: %v", err)
+	}
+	if err := maintenance.Reindex(); err != nil {
+		t.Errorf("Reindex: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+type MaintenanceRepository interface {
+	Analyze() error
+	Reindex() error
+}
+
+type maintenanceRepository struct {
+	db *sql.DB
+}
+
+func NewMaintenanceRepository(db *sql.DB) MaintenanceRepository {
+	return &maintenanceRepository{db: db}
+}
+
+func (r *maintenanceRepository) Analyze() error {
+	if _, err := r.db.Exec(`ANALYZE subscriptions`); err != nil {
+		logrus.WithError(err).Error("Failed to analyze subscriptions")
+		return fmt.Errorf("failed to analyze subscriptions: %w", err)
+	}
+
+	return nil
+}
+
+func (r *maintenanceRepository) Reindex() error {
+	if _, err := r.db.Exec(`REINDEX TABLE subscriptions`); err != nil {
+		logrus.WithError(err).Error("Failed to reindex subscriptions")
+		return fmt.Errorf("failed to reindex subscriptions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+type OutboxRepository interface {
+	// RelayBatch блокирует до batchSize самых старых неотправленных событий,
+	// передаёт их publish по порядку и помечает отправленными те, что publish
+	// принял. На первой ошибке publish обработка пачки останавливается, а
+	// оставшиеся события будут переданы повторно (доставка at-least-once).
+	RelayBatch(batchSize int, publish func(model.OutboxEvent) error) (int, error)
+	// PurgeSentBatch удаляет не более batchSize событий, отправленных до before.
+	PurgeSentBatch(before time.Time, batchSize int) (int64, error)
+}
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// withOutboxEvent оборачивает изменяющий запрос с RETURNING subscriptionColumns
+// так, чтобы событие записалось в outbox той же командой, а значит и в той же
+// транзакции. Результат запроса не меняется.
+func withOutboxEvent(query, eventType string) string {
+	return `
+        WITH changed AS (` + query + `), event AS (
+            INSERT INTO outbox (aggregate_id, event_type, payload)
+            SELECT id, '` + eventType + `', to_jsonb(changed) FROM changed
+        )
+        SELECT ` + subscriptionColumns + ` FROM changed
+    `
+}
+
+func (r *outboxRepository) RelayBatch(batchSize int, publish func(model.OutboxEvent) error) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED позволяет нескольким репликам разбирать outbox параллельно.
+	rows, err := tx.Query(`
+        SELECT id, aggregate_id, event_type, payload, created_at
+        FROM outbox
+        WHERE sent_at IS NULL
+        ORDER BY id
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    `, batchSize)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to select outbox events")
+		return 0, fmt.Errorf("failed to select outbox events: %w", err)
+	}
+
+	var events []model.OutboxEvent
+	for rows.Next() {
+		var event model.OutboxEvent
+		if err := rows.Scan(&event.ID, &event.AggregateID, &event.EventType, &event.Payload, &event.CreatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate outbox events: %w", err)
+	}
+
+	sent := make([]int64, 0, len(events))
+	var publishErr error
+	for _, event := range events {
+		if publishErr = publish(event); publishErr != nil {
+			break
+		}
+		sent = append(sent, event.ID)
+	}
+
+	if len(sent) > 0 {
+		if _, err := tx.Exec(`UPDATE outbox SET sent_at = NOW() WHERE id = ANY($1)`, pq.Array(sent)); err != nil {
+			logrus.WithError(err).Error("Failed to mark outbox events as sent")
+			return 0, fmt.Errorf("failed to mark outbox events as sent: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox transaction: %w", err)
+	}
+
+	if publishErr != nil {
+		return len(sent), fmt.Errorf("failed to publish outbox event: %w", publishErr)
+	}
+	return len(sent), nil
+}
+
+func (r *outboxRepository) PurgeSentBatch(before time.Time, batchSize int) (int64, error) {
+	query := `
+        WITH batch AS (
+            SELECT id FROM outbox
+            WHERE sent_at < $1
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        )
+        DELETE FROM outbox o
+        USING batch
+        WHERE o.id = batch.id
+    `
+
+	result, err := r.db.Exec(query, before, batchSize)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to purge sent outbox events")
+		return 0, fmt.Errorf("failed to purge sent outbox events: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected, nil
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PruneRepository удаляет или архивирует закончившиеся подписки. Подписки с
+// auto_renew не трогаются: их end_date продлевает задача renewal. При включённом
+// outbox на каждую подписку пишется событие subscription.deleted.
+type PruneRepository interface {
+	// DeleteExpiredBatch удаляет не более batchSize подписок, закончившихся до cutoff.
+	DeleteExpiredBatch(cutoff time.Time, batchSize int) (int64, error)
+	// ArchiveExpiredBatch переносит не более batchSize подписок, закончившихся до cutoff,
+	// в таблицу subscriptions_archive.
+	ArchiveExpiredBatch(cutoff time.Time, batchSize int) (int64, error)
+}
+
+type pruneRepository struct {
+	db *sql.DB
+	// outbox включает запись события об удалении каждой подписки в таблицу outbox.
+	outbox bool
+}
+
+func NewPruneRepository(db *sql.DB, outbox bool) PruneRepository {
+	return &pruneRepository{db: db, outbox: outbox}
+}
+
+// expiredBatchCTE выбирает и удаляет пачку закончившихся подписок, возвращая
+// удалённые строки как removed. Событие subscription.deleted пишется той же
+// командой: для потребителей архивирование - тоже удаление.
+func (r *pruneRepository) expiredBatchCTE() string {
+	query := `
+        WITH batch AS (
+            SELECT id FROM subscriptions
+            WHERE end_date < $1 AND NOT auto_renew
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        ), removed AS (
+            DELETE FROM subscriptions s
+            USING batch
+            WHERE s.id = batch.id
+            RETURNING s.*
+        )`
+
+	if r.outbox {
+		query += `, event AS (
+            INSERT INTO outbox (aggregate_id, event_type, payload)
+            SELECT id, '` + model.EventSubscriptionDeleted + `', to_jsonb(removed) FROM removed
+        )`
+	}
+
+	return query
+}
+
+func (r *pruneRepository) DeleteExpiredBatch(cutoff time.Time, batchSize int) (int64, error) {
+	query := r.expiredBatchCTE() + `
+        SELECT COUNT(*) FROM removed
+    `
+
+	var affected int64
+	if err := r.db.QueryRow(query, cutoff, batchSize).Scan(&affected); err != nil {
+		logrus.WithError(err).Error("Failed to delete expired subscriptions")
+		return 0, fmt.Errorf("failed to delete expired subscriptions: %w", readOnlyError(err))
+	}
+
+	return affected, nil
+}
+
+func (r *pruneRepository) ArchiveExpiredBatch(cutoff time.Time, batchSize int) (int64, error) {
+	query := r.expiredBatchCTE() + `, archived AS (
+            INSERT INTO subscriptions_archive (id, data)
+            SELECT id, to_jsonb(removed) FROM removed
+        )
+        SELECT COUNT(*) FROM removed
+    `
+
+	var affected int64
+	if err := r.db.QueryRow(query, cutoff, batchSize).Scan(&affected); err != nil {
+		logrus.WithError(err).Error("Failed to archive expired subscriptions")
+		return 0, fmt.Errorf("failed to archive expired subscriptions: %w", readOnlyError(err))
+	}
+
+	return affected, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Нормализованные значения колонок в том виде, в каком их пишет сервис
+// (см. normalizeTags, sanitizeDescription и приведение currency в сервисе).
+// Выражения идемпотентны: повторный пересчёт строку не меняет.
+const (
+	// Теги: без пробелов по краям, в нижнем регистре, без пустых и повторов,
+	// в порядке первых вхождений - как в миграции 000011.
+	normalizedTagsExpr = `COALESCE(ARRAY(
+                SELECT tag
+                FROM (
+                    SELECT lower(btrim(t.tag)) AS tag, MIN(t.pos) AS pos
+                    FROM unnest(s.tags) WITH ORDINALITY AS t(tag, pos)
+                    WHERE btrim(t.tag) <> ''
+                    GROUP BY lower(btrim(t.tag))
+                ) dedup
+                ORDER BY pos
+            ), '{}')`
+	// Валюта: код в верхнем регистре, пустая - базовая ($3).
+	normalizedCurrencyExpr = `COALESCE(NULLIF(upper(btrim(s.currency)), ''), $3)`
+	// Описание: без управляющих символов, кроме перевода строки и табуляции,
+	// и без пробелов по краям.
+	sanitizedDescriptionExpr = `btrim(regexp_replace(s.description, E'[\\x01-\\x08\\x0B-\\x1F\\x7F-\\x9F]', '', 'g'), E' \t\n')`
+)
+
+// recomputeAssignments - выражения для производных и нормализуемых колонок.
+// Новая производная колонка добавляется сюда вместе с условием в recomputeStale.
+const recomputeAssignments = `
+            currency = ` + normalizedCurrencyExpr + `,
+            tags = ` + normalizedTagsExpr + `,
+            description = ` + sanitizedDescriptionExpr
+
+// recomputeStale отбирает строки, значения которых расходятся с нормализованными,
+// чтобы не трогать актуальные строки (и их updated_at) при повторных запусках.
+const recomputeStale = `
+            s.currency IS DISTINCT FROM ` + normalizedCurrencyExpr + `
+            OR s.tags IS DISTINCT FROM ` + normalizedTagsExpr + `
+            OR s.description IS DISTINCT FROM ` + sanitizedDescriptionExpr
+
+type RecomputeRepository interface {
+	// RecomputeBatch пересчитывает до batchSize строк с id > afterID (в порядке id)
+	// и возвращает последний просмотренный id, число просмотренных и обновлённых строк.
+	RecomputeBatch(afterID uuid.UUID, batchSize int, baseCurrency string) (uuid.UUID, int, int, error)
+}
+
+type recomputeRepository struct {
+	db *sql.DB
+}
+
+func NewRecomputeRepository(db *sql.DB) RecomputeRepository {
+	return &recomputeRepository{db: db}
+}
+
+func (r *recomputeRepository) RecomputeBatch(afterID uuid.UUID, batchSize int, baseCurrency string) (uuid.UUID, int, int, error) {
+	query := `
+        WITH batch AS (
+            SELECT id FROM subscriptions
+            WHERE id > $1
+            ORDER BY id
+            LIMIT $2
+        ), updated AS (
+            UPDATE subscriptions s
+            SET` + recomputeAssignments + `,
+                updated_at = NOW(),
+                version = s.version + 1
+            FROM batch
+            WHERE s.id = batch.id AND (` + recomputeStale + `)
+            RETURNING s.id
+        )
+        SELECT
+            (SELECT id FROM batch ORDER BY id DESC LIMIT 1),
+            (SELECT COUNT(*) FROM batch),
+            (SELECT COUNT(*) FROM updated)
+    `
+
+	var lastID uuid.NullUUID
+	var scanned, updated int
+	err := r.db.QueryRow(query, afterID, batchSize, baseCurrency).Scan(&lastID, &scanned, &updated)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to recompute subscriptions batch")
+		return afterID, 0, 0, fmt.Errorf("failed to recompute subscriptions batch: %w", err)
+	}
+
+	if !lastID.Valid {
+		return afterID, 0, 0, nil
+	}
+
+	return lastID.UUID, scanned, updated, nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type RenewalRepository interface {
+	// RenewDueBatch продлевает на месяц не более batchSize подписок с auto_renew,
+	// закончившихся до today, и записывает продления в subscription_renewals.
+	// Новая end_date отсчитывается от start_date, как и даты списаний: для
+	// старта 31 января это 29 февраля, затем 31 марта, а не 29 марта.
+	// Подписка, уже продлённая с той же даты окончания, повторно не продлевается.
+	RenewDueBatch(today time.Time, batchSize int) ([]model.Renewal, error)
+}
+
+type renewalRepository struct {
+	db *sql.DB
+	// outbox включает запись события о продлении в таблицу outbox.
+	outbox bool
+}
+
+func NewRenewalRepository(db *sql.DB, outbox bool) RenewalRepository {
+	return &renewalRepository{db: db, outbox: outbox}
+}
+
+// renewedEndDateExpr - end_date через месяц после месяца текущей end_date с
+// днём start_date. Прибавление месяцев к дате в PostgreSQL ограничивает день
+// последним днём месяца, как model.AddMonths, а отсчёт всегда от start_date не
+// даёт дню "сползать" после коротких месяцев.
+const renewedEndDateExpr = `(start_date + make_interval(months => (
+                (EXTRACT(YEAR FROM end_date) - EXTRACT(YEAR FROM start_date)) * 12
+                + EXTRACT(MONTH FROM end_date) - EXTRACT(MONTH FROM start_date) + 1
+            )::int))::date`
+
+func (r *renewalRepository) RenewDueBatch(today time.Time, batchSize int) ([]model.Renewal, error) {
+	// Запись в журнал и сдвиг end_date - одна команда: при конфликте в журнале
+	// (продление уже было) строка не попадает в renewed и end_date не меняется.
+	// Событие в outbox пишется той же командой.
+	event := ""
+	if r.outbox {
+		event = `, event AS (
+            INSERT INTO outbox (aggregate_id, event_type, payload)
+            SELECT u.id, '` + model.EventSubscriptionRenewed + `',
+                   to_jsonb(u) || jsonb_build_object('previous_end_date', renewed.previous_end_date)
+            FROM updated u
+            JOIN renewed ON renewed.subscription_id = u.id
+        )`
+	}
+
+	query := `
+        WITH due AS (
+            SELECT id, start_date, end_date FROM subscriptions s
+            WHERE auto_renew AND end_date < $1
+              AND NOT EXISTS (
+                  SELECT 1 FROM subscription_renewals r
+                  WHERE r.subscription_id = s.id AND r.previous_end_date = s.end_date
+              )
+            ORDER BY end_date, id
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        ), renewed AS (
+            INSERT INTO subscription_renewals (subscription_id, previous_end_date, new_end_date)
+            SELECT id, end_date, ` + renewedEndDateExpr + ` FROM due
+            ON CONFLICT (subscription_id, previous_end_date) DO NOTHING
+            RETURNING subscription_id, previous_end_date, new_end_date
+        ), updated AS (
+            UPDATE subscriptions s
+            SET end_date = renewed.new_end_date, updated_at = NOW(), version = s.version + 1
+            FROM renewed
+            WHERE s.id = renewed.subscription_id
+            RETURNING s.*
+        )` + event + `
+        SELECT u.id, u.user_id, renewed.previous_end_date, renewed.new_end_date
+        FROM updated u
+        JOIN renewed ON renewed.subscription_id = u.id
+    `
+
+	rows, err := r.db.Query(query, today, batchSize)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to renew subscriptions")
+		return nil, fmt.Errorf("failed to renew subscriptions: %w", readOnlyError(err))
+	}
+	defer rows.Close()
+
+	var renewals []model.Renewal
+	for rows.Next() {
+		var renewal model.Renewal
+		if err := rows.Scan(&renewal.SubscriptionID, &renewal.UserID, &renewal.PreviousEndDate, &renewal.NewEndDate); err != nil {
+			return nil, fmt.Errorf("failed to scan renewal: %w", err)
+		}
+		renewals = append(renewals, renewal)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to renew subscriptions: %w", err)
+	}
+
+	return renewals, nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// serializationFailure и deadlockDetected - коды ошибок PostgreSQL, после
+	// которых транзакция откатывается целиком и её можно безопасно повторить.
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+	// readOnlyTransaction - запись в БД, которая сейчас только для чтения
+	// (например, бывший primary во время переключения).
+	readOnlyTransaction = "25006"
+)
+
+// ErrReadOnly возвращается изменяющими запросами, если БД временно в режиме
+// только чтения. Операцию можно повторить после переключения.
+var ErrReadOnly = errors.New("database is read-only")
+
+// readOnlyError заменяет ошибку записи в read-only БД на ErrReadOnly, остальные
+// ошибки возвращает без изменений.
+func readOnlyError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == readOnlyTransaction {
+		return fmt.Errorf("%w: %v", ErrReadOnly, err)
+	}
+	return err
+}
+
+// IsRetryable сообщает, что операция отклонена из-за конкурентного доступа
+// (конфликт сериализации или взаимоблокировка) или временного режима только
+// чтения и её можно повторить.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrReadOnly) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+}
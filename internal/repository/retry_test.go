@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pq.Error{Code: "40001"}, true},
+		{"deadlock", &pq.Error{Code: "40P01"}, true},
+		{"wrapped serialization failure", fmt.Errorf("update: %w", &pq.Error{Code: "40001"}), true},
+		{"read-only", readOnlyError(&pq.Error{Code: "25006"}), true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"plain error", errors.New("connection reset"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
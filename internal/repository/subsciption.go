@@ -1,53 +1,422 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"subscription_service/internal/model"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
+// MonthlyCurrencyTotal - сумма подписок в одной валюте за календарный месяц.
+type MonthlyCurrencyTotal struct {
+	Month    time.Time
+	Currency string
+	Total    int
+}
+
+// ServiceUserCurrencyTotal - сумма подписок пользователя на сервис в одной валюте.
+type ServiceUserCurrencyTotal struct {
+	ServiceName string
+	UserID      uuid.UUID
+	Currency    string
+	Total       int
+}
+
+// MonthlyCount - количество подписок на конец календарного месяца.
+type MonthlyCount struct {
+	Month time.Time
+	Count int
+}
+
+// UserLimit - лимит активных подписок пользователя, который проверяется в той же
+// транзакции, что и запись: не больше Max подписок, не закончившихся к дате On.
+// Нулевой UserLimit - без проверки.
+type UserLimit struct {
+	Max int
+	On  time.Time
+}
+
 type SubscriptionRepository interface {
-	Create(sub *model.Subscription) error
-	GetByID(id uuid.UUID) (*model.Subscription, error)
-	Update(id uuid.UUID, updates map[string]interface{}) error
-	Delete(id uuid.UUID) error
-	List(filter model.SubscriptionFilter) ([]*model.Subscription, error)
-	Aggregate(startDate, endDate time.Time, userID *uuid.UUID, serviceName *string) (int, error)
+	// Create, CreateIfNotExists, Update со сменой user_id и Transfer проверяют
+	// limit под advisory-блокировкой пользователя, которому достаётся подписка,
+	// поэтому конкурентные записи не превышают лимит; при превышении
+	// возвращается ErrUserLimitExceeded. Create и CreateIfNotExists выполняются
+	// в транзакции SERIALIZABLE: конфликт с параллельной записью возвращается
+	// ошибкой 40001, и сервис повторяет создание (IsRetryable).
+	Create(ctx context.Context, sub *model.Subscription, limit UserLimit) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error)
+	// CreateIfNotExists в одной транзакции под блокировкой пользователя ищет его
+	// подписку на тот же сервис с той же датой начала и возвращает её с false;
+	// если такой нет, вызывает check и, если он не вернул ошибку, создаёт sub и
+	// возвращает её с true. Конкурентные вызовы с одним ключом не создают дублей.
+	CreateIfNotExists(ctx context.Context, sub *model.Subscription, limit UserLimit, check func() error) (*model.Subscription, bool, error)
+	// FindDuplicate ищет подписку, с которой sub нарушает политику уникальности
+	// policy (model.UniquePolicy*).
+	FindDuplicate(ctx context.Context, sub *model.Subscription, policy string) (*model.Subscription, error)
+	// MonthlyCostByUser суммирует цены подписок пользователя, активных на дату on, по валютам.
+	MonthlyCostByUser(ctx context.Context, userID uuid.UUID, on time.Time) (map[string]int, error)
+	// Update и Delete при expectedVersion != nil меняют строку, только если её
+	// version совпадает, иначе возвращают ErrVersionConflict. Update при
+	// skipUnchanged не пишет строку, если updates совпадают с текущими значениями,
+	// и возвращает текущую подписку вместе с ErrNotModified.
+	Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}, expectedVersion *int, skipUnchanged bool, limit UserLimit) (*model.Subscription, error)
+	// Delete возвращает удалённую подписку.
+	Delete(ctx context.Context, id uuid.UUID, expectedVersion *int) (*model.Subscription, error)
+	// Transfer в одной транзакции блокирует подписку, передаёт её текущее
+	// состояние в check и, если check не вернул ошибку, меняет владельца на
+	// toUserID и пишет запись в subscription_transfers.
+	Transfer(ctx context.Context, id, toUserID uuid.UUID, limit UserLimit, check func(current *model.Subscription) error) (*model.Subscription, error)
+	// List возвращает подписки, общее число подходящих под фильтр строк без
+	// учёта Limit/Offset и количество пропущенных повреждённых строк.
+	List(ctx context.Context, filter model.SubscriptionFilter) ([]*model.Subscription, int, int, error)
+	// ListRefs выбирает по тому же фильтру только id и updated_at; второе
+	// значение - общее количество без учёта Limit/Offset.
+	ListRefs(ctx context.Context, filter model.SubscriptionFilter) ([]model.SubscriptionRef, int, error)
+	// Facet считает подписки, подходящие под фильтр (без пагинации), по значениям
+	// поля field (model.Facet*); статус вычисляется на дату today.
+	Facet(ctx context.Context, filter model.SubscriptionFilter, field string, today time.Time) (map[string]int, error)
+	// Top возвращает limit подписок с наибольшей ценой (model.TopByPrice) или
+	// длительностью по состоянию на today (model.TopByDuration).
+	Top(ctx context.Context, by string, limit int, today time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]*model.Subscription, error)
+	// Changes возвращает до limit подписок с (updated_at, id) больше (since, afterID)
+	// по возрастанию; без afterID - с updated_at больше since.
+	Changes(ctx context.Context, since time.Time, afterID *uuid.UUID, limit int) ([]*model.Subscription, error)
+	// ListExpiring возвращает страницу подписок с end_date в диапазоне [from, to]
+	// (limit 0 - все) и общее количество таких подписок.
+	ListExpiring(ctx context.Context, from, to time.Time, limit, offset int) ([]*model.Subscription, int, error)
+	// Export построчно передаёт в fn все подписки, подходящие под фильтр,
+	// не загружая выборку в память целиком.
+	Export(ctx context.Context, filter model.SubscriptionFilter, fn func(*model.Subscription) error) error
+	Aggregate(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (int, error)
+	AggregateByCurrency(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (map[string]int, error)
+	AggregateByUsers(ctx context.Context, startDate, endDate time.Time, userIDs []uuid.UUID, serviceName *model.ServiceNameFilter) (map[uuid.UUID]int, error)
+	AggregateByMonth(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]MonthlyCurrencyTotal, error)
+	// AggregateMatrix группирует итог за период по (service_name, user_id, currency)
+	// в порядке service_name, user_id; возвращает не больше limit строк.
+	AggregateMatrix(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter, limit int) ([]ServiceUserCurrencyTotal, error)
+	// SimulatePriceChange считает итог за период по текущим ценам и по ценам,
+	// заменённым на newPrice или изменённым на percent процентов. Данные не меняются.
+	SimulatePriceChange(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter, newPrice *int, percent *float64) (int, int, error)
+	// Timeline считает подписки, активные на последний день каждого месяца
+	// от месяца startDate до месяца endDate включительно.
+	Timeline(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]MonthlyCount, error)
+}
+
+// ErrVersionConflict возвращается условными Update/Delete, если подписка
+// изменилась после того, как клиент её прочитал.
+var ErrVersionConflict = errors.New("subscription version conflict")
+
+// ErrNotModified возвращается Update с skipUnchanged, если изменения не меняют
+// ни одной колонки; updated_at и version при этом не трогаются.
+var ErrNotModified = errors.New("subscription not modified")
+
+// ErrUserLimitExceeded возвращается записью, после которой у пользователя стало
+// бы больше UserLimit.Max активных подписок. Запись откатывается.
+var ErrUserLimitExceeded = errors.New("active subscription limit exceeded")
+
+// ErrDuplicateID возвращается Create, если подписка с таким id уже есть.
+var ErrDuplicateID = errors.New("subscription with this id already exists")
+
+// ErrStatementTimeout возвращается тяжёлыми чтениями, прерванными по
+// statement_timeout. Оборачивает context.DeadlineExceeded, поэтому обрабатывается
+// так же, как истёкший таймаут запроса.
+var ErrStatementTimeout = fmt.Errorf("database statement timeout exceeded: %w", context.DeadlineExceeded)
+
+// ErrDuplicateSubscription возвращается Create, если вставка нарушила
+// необязательный уникальный индекс или ограничение исключения политики уникальности.
+var ErrDuplicateSubscription = errors.New("subscription violates uniqueness policy")
+
+const (
+	// uniqueViolation и exclusionViolation - коды ошибок PostgreSQL при нарушении
+	// уникального индекса и ограничения исключения.
+	uniqueViolation    = "23505"
+	exclusionViolation = "23P01"
+	// queryCanceled - код ошибки PostgreSQL при срабатывании statement_timeout.
+	queryCanceled = "57014"
+
+	primaryKeyConstraint = "subscriptions_pkey"
+)
+
+const subscriptionColumns = "id, service_name, price, currency, tags, description, user_id, start_date, end_date, created_at, updated_at, version, auto_renew, metadata"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// MalformedRowError означает, что в строке subscriptions оказался NULL в колонке,
+// которая по смыслу обязательна (например, после ручной правки данных).
+type MalformedRowError struct {
+	ID     uuid.UUID
+	Column string
+}
+
+func (e *MalformedRowError) Error() string {
+	return fmt.Sprintf("subscription %s has NULL %s", e.ID, e.Column)
+}
+
+// scanSubscription читает строку через nullable-типы, чтобы NULL в обязательной
+// колонке не ломал Scan, а возвращался как *MalformedRowError.
+// extra - приёмники для колонок, выбранных после subscriptionColumns.
+func scanSubscription(row rowScanner, extra ...interface{}) (*model.Subscription, error) {
+	var (
+		sub         model.Subscription
+		serviceName sql.NullString
+		price       sql.NullInt64
+		currency    sql.NullString
+		description sql.NullString
+		userID      uuid.NullUUID
+		startDate   sql.NullTime
+		createdAt   sql.NullTime
+		updatedAt   sql.NullTime
+		metadata    []byte
+	)
+	dest := []interface{}{
+		&sub.ID, &serviceName, &price, &currency, pq.Array(&sub.Tags), &description, &userID,
+		&startDate, &sub.EndDate, &createdAt, &updatedAt, &sub.Version, &sub.AutoRenew, &metadata,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !serviceName.Valid:
+		return nil, &MalformedRowError{ID: sub.ID, Column: "service_name"}
+	case !price.Valid:
+		return nil, &MalformedRowError{ID: sub.ID, Column: "price"}
+	case !userID.Valid:
+		return nil, &MalformedRowError{ID: sub.ID, Column: "user_id"}
+	case !startDate.Valid:
+		return nil, &MalformedRowError{ID: sub.ID, Column: "start_date"}
+	}
+
+	// currency, description и служебные даты не влияют на расчёты, поэтому NULL в них
+	// допускается и отдаётся как пустое значение.
+	sub.ServiceName = serviceName.String
+	sub.Price = model.Money(price.Int64)
+	sub.Currency = currency.String
+	sub.Description = description.String
+	sub.UserID = userID.UUID
+	if sub.Tags == nil {
+		sub.Tags = []string{}
+	}
+
+	sub.Metadata = map[string]interface{}{}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &sub.Metadata); err != nil {
+			return nil, &MalformedRowError{ID: sub.ID, Column: "metadata"}
+		}
+	}
+
+	sub.StartDate = startDate.Time.UTC()
+	if sub.EndDate != nil {
+		endDate := sub.EndDate.UTC()
+		sub.EndDate = &endDate
+	}
+	sub.CreatedAt = createdAt.Time.UTC()
+	sub.UpdatedAt = updatedAt.Time.UTC()
+
+	return &sub, nil
+}
+
+// scanSubscriptionRows читает строки выборки, пропуская повреждённые записи:
+// одна строка с NULL не должна ломать весь список. Пропущенные id логируются,
+// их количество возвращается вызывающему.
+func scanSubscriptionRows(rows *sql.Rows, fn func(*model.Subscription) error, extra ...interface{}) (int, error) {
+	skipped := 0
+	for rows.Next() {
+		sub, err := scanSubscription(rows, extra...)
+		if err != nil {
+			var malformed *MalformedRowError
+			if errors.As(err, &malformed) {
+				logrus.WithFields(logrus.Fields{
+					"id":     malformed.ID,
+					"column": malformed.Column,
+				}).Warn("Skipping malformed subscription row")
+				skipped++
+				continue
+			}
+			logrus.WithError(err).Error("Failed to scan subscription")
+			return skipped, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		if err := fn(sub); err != nil {
+			return skipped, err
+		}
+	}
+
+	return skipped, rows.Err()
 }
 
 type subscriptionRepository struct {
 	db *sql.DB
+	// outbox включает запись событий Create/Update/Delete в таблицу outbox.
+	outbox bool
+	// statementTimeout ограничивает время тяжёлых чтений на стороне БД (0 - без ограничения).
+	statementTimeout time.Duration
+}
+
+func NewSubscriptionRepository(db *sql.DB, outbox bool, statementTimeout time.Duration) SubscriptionRepository {
+	return &subscriptionRepository{db: db, outbox: outbox, statementTimeout: statementTimeout}
+}
+
+// queryer - общие методы чтения *sql.DB и *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// heavyRead выполняет тяжёлое чтение (агрегации, выгрузка). При заданном
+// statementTimeout запросы идут в транзакции с SET LOCAL statement_timeout,
+// и PostgreSQL сам прерывает их, даже если отмена на стороне приложения не сработала.
+func (r *subscriptionRepository) heavyRead(ctx context.Context, fn func(q queryer) error) error {
+	if r.statementTimeout <= 0 {
+		return fn(r.db)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SET не принимает параметры, значение подставляется как целое число миллисекунд.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", r.statementTimeout.Milliseconds())); err != nil {
+		return fmt.Errorf("failed to set statement timeout: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == queryCanceled {
+			return fmt.Errorf("%w: %v", ErrStatementTimeout, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withEvent добавляет к изменяющему запросу запись события в outbox, если она включена.
+func (r *subscriptionRepository) withEvent(query, eventType string) string {
+	if !r.outbox {
+		return query
+	}
+	return withOutboxEvent(query, eventType)
+}
+
+// serializable - уровень изоляции транзакций создания (см. createTx).
+var serializable = &sql.TxOptions{Isolation: sql.LevelSerializable}
+
+// execer - общий метод записи *sql.DB и *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (r *subscriptionRepository) Create(ctx context.Context, sub *model.Subscription, limit UserLimit) error {
+	return r.createTx(ctx, sub.UserID, limit.Max > 0, func(tx *sql.Tx) error {
+		if err := r.insert(ctx, tx, sub); err != nil {
+			return err
+		}
+		return checkUserLimit(ctx, tx, sub, limit)
+	})
+}
+
+// createTx выполняет fn в транзакции SERIALIZABLE: конфликт с параллельной
+// записью PostgreSQL откатывает с 40001, и сервис повторяет создание. Если lock,
+// на время транзакции берётся advisory-блокировка пользователя. Она сессионная и
+// берётся до BEGIN, потому что снимок SERIALIZABLE фиксируется первым запросом
+// транзакции: после ожидания блокировки внутри транзакции снимок не видел бы
+// записей, закоммиченных за это время, и каждая ожидавшая транзакция
+// откатывалась бы с 40001.
+func (r *subscriptionRepository) createTx(ctx context.Context, userID uuid.UUID, lock bool, fn func(tx *sql.Tx) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if lock {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtextextended($1, 0))`, userLockKey(userID)); err != nil {
+			logrus.WithError(err).Error("Failed to lock user subscriptions")
+			return fmt.Errorf("failed to lock user subscriptions: %w", err)
+		}
+		defer unlockUser(conn, userID)
+	}
+
+	tx, err := conn.BeginTx(ctx, serializable)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit subscription: %w", readOnlyError(err))
+	}
+	return nil
 }
 
-func NewSubscriptionRepository(db *sql.DB) SubscriptionRepository {
-	return &subscriptionRepository{db: db}
+// unlockUser снимает сессионную блокировку createTx. Если это не удалось,
+// соединение закрывается, а не возвращается в пул: блокировка снимется вместе с сессией.
+func unlockUser(conn *sql.Conn, userID uuid.UUID) {
+	_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtextextended($1, 0))`, userLockKey(userID))
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to unlock user subscriptions, closing connection")
+		_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
 }
 
-func (r *subscriptionRepository) Create(sub *model.Subscription) error {
+// insert вставляет sub, заполняя created_at, updated_at и version.
+func (r *subscriptionRepository) insert(ctx context.Context, db execer, sub *model.Subscription) error {
 	query := `
-        INSERT INTO subscriptions (id, service_name, price, user_id, start_date, end_date, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-    `
+        INSERT INTO subscriptions (` + subscriptionColumns + `)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+        RETURNING ` + subscriptionColumns
+	query = r.withEvent(query, model.EventSubscriptionCreated)
 
-	now := time.Now()
+	now := time.Now().UTC()
 	sub.CreatedAt = now
 	sub.UpdatedAt = now
+	sub.Version = 1
+	if sub.Metadata == nil {
+		sub.Metadata = map[string]interface{}{}
+	}
 
-	_, err := r.db.Exec(query,
-		sub.ID, sub.ServiceName, sub.Price, sub.UserID,
-		sub.StartDate, sub.EndDate, sub.CreatedAt, sub.UpdatedAt,
+	metadata, err := json.Marshal(sub.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode subscription metadata: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, query,
+		sub.ID, sub.ServiceName, sub.Price, sub.Currency, pq.Array(sub.Tags), sub.Description, sub.UserID,
+		sub.StartDate, sub.EndDate, sub.CreatedAt, sub.UpdatedAt, sub.Version, sub.AutoRenew, string(metadata),
 	)
 
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == primaryKeyConstraint {
+			logrus.WithField("id", sub.ID).Warn("Subscription with this id already exists")
+			return ErrDuplicateID
+		}
+		if errors.As(err, &pqErr) && (pqErr.Code == uniqueViolation || pqErr.Code == exclusionViolation) {
+			logrus.WithField("constraint", pqErr.Constraint).Warn("Subscription violates uniqueness constraint")
+			return ErrDuplicateSubscription
+		}
 		logrus.WithError(err).Error("Failed to create subscription")
-		return fmt.Errorf("failed to create subscription: %w", err)
+		return fmt.Errorf("failed to create subscription: %w", readOnlyError(err))
 	}
 
 	logrus.WithFields(logrus.Fields{
@@ -59,19 +428,90 @@ func (r *subscriptionRepository) Create(sub *model.Subscription) error {
 	return nil
 }
 
-func (r *subscriptionRepository) GetByID(id uuid.UUID) (*model.Subscription, error) {
+// userLockKey - ключ advisory-блокировки подписок пользователя.
+func userLockKey(userID uuid.UUID) string {
+	return "subscriptions:user:" + userID.String()
+}
+
+// lockUser берёт advisory-блокировку пользователя до конца транзакции tx:
+// записи, которые проверяют другие подписки того же пользователя, идут по очереди.
+func lockUser(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, userLockKey(userID)); err != nil {
+		logrus.WithError(err).Error("Failed to lock user subscriptions")
+		return fmt.Errorf("failed to lock user subscriptions: %w", err)
+	}
+	return nil
+}
+
+// checkUserLimit проверяет в транзакции tx лимит активных подписок владельца sub
+// после её записи: sub уже в таблице и учитывается, если она активна на limit.On.
+// Вызывается под блокировкой того же пользователя (lockUser или createTx).
+func checkUserLimit(ctx context.Context, tx *sql.Tx, sub *model.Subscription, limit UserLimit) error {
+	if limit.Max <= 0 || (sub.EndDate != nil && sub.EndDate.Before(limit.On)) {
+		return nil
+	}
+
+	var count int
+	err := tx.QueryRowContext(ctx, `
+        SELECT COUNT(*)
+        FROM subscriptions
+        WHERE user_id = $1 AND (end_date IS NULL OR end_date >= $2)`, sub.UserID, limit.On).Scan(&count)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to count active subscriptions")
+		return fmt.Errorf("failed to count active subscriptions: %w", err)
+	}
+
+	if count > limit.Max {
+		return ErrUserLimitExceeded
+	}
+	return nil
+}
+
+func (r *subscriptionRepository) CreateIfNotExists(ctx context.Context, sub *model.Subscription, limit UserLimit, check func() error) (*model.Subscription, bool, error) {
+	var existing *model.Subscription
+	err := r.createTx(ctx, sub.UserID, true, func(tx *sql.Tx) error {
+		found, err := scanSubscription(tx.QueryRowContext(ctx, `
+            SELECT `+subscriptionColumns+`
+            FROM subscriptions
+            WHERE user_id = $1 AND service_name = $2 AND start_date = $3
+            ORDER BY created_at, id
+            LIMIT 1`, sub.UserID, sub.ServiceName, sub.StartDate))
+		switch {
+		case err == nil:
+			existing = found
+			return nil
+		case !errors.Is(err, sql.ErrNoRows):
+			logrus.WithError(err).WithField("user_id", sub.UserID).Error("Failed to find existing subscription")
+			return fmt.Errorf("failed to find existing subscription: %w", err)
+		}
+
+		if err := check(); err != nil {
+			return err
+		}
+
+		if err := r.insert(ctx, tx, sub); err != nil {
+			return err
+		}
+		return checkUserLimit(ctx, tx, sub, limit)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if existing != nil {
+		return existing, false, nil
+	}
+	return sub, true, nil
+}
+
+func (r *subscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Subscription, error) {
 	query := `
-        SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at
+        SELECT ` + subscriptionColumns + `
         FROM subscriptions
         WHERE id = $1
     `
 
-	var sub model.Subscription
-	err := r.db.QueryRow(query, id).Scan(
-		&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID,
-		&sub.StartDate, &sub.EndDate, &sub.CreatedAt, &sub.UpdatedAt,
-	)
-
+	sub, err := scanSubscription(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -80,82 +520,365 @@ func (r *subscriptionRepository) GetByID(id uuid.UUID) (*model.Subscription, err
 		return nil, fmt.Errorf("failed to get subscription: %w", err)
 	}
 
-	return &sub, nil
+	return sub, nil
+}
+
+func (r *subscriptionRepository) FindDuplicate(ctx context.Context, sub *model.Subscription, policy string) (*model.Subscription, error) {
+	query := `
+        SELECT ` + subscriptionColumns + `
+        FROM subscriptions
+        WHERE user_id = $1 AND service_name = $2
+    `
+	args := []interface{}{sub.UserID, sub.ServiceName}
+
+	switch policy {
+	case model.UniquePolicyUserService:
+	case model.UniquePolicyUserServicePeriod:
+		query += " AND daterange(start_date, end_date, '[]') && daterange($3::date, $4::date, '[]')"
+		args = append(args, sub.StartDate, sub.EndDate)
+	default:
+		return nil, nil
+	}
+	query += " ORDER BY start_date, id LIMIT 1"
+
+	existing, err := scanSubscription(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		logrus.WithError(err).WithField("user_id", sub.UserID).Error("Failed to find duplicate subscription")
+		return nil, fmt.Errorf("failed to find duplicate subscription: %w", err)
+	}
+
+	return existing, nil
 }
 
-func (r *subscriptionRepository) Update(id uuid.UUID, updates map[string]interface{}) error {
+func (r *subscriptionRepository) MonthlyCostByUser(ctx context.Context, userID uuid.UUID, on time.Time) (map[string]int, error) {
+	query := `
+        SELECT currency, COALESCE(SUM(price), 0)
+        FROM subscriptions
+        WHERE user_id = $1 AND start_date <= $2 AND (end_date IS NULL OR end_date >= $2)
+        GROUP BY currency
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID, on)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("Failed to sum monthly cost")
+		return nil, fmt.Errorf("failed to sum monthly cost: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int)
+	for rows.Next() {
+		var currency sql.NullString
+		var total int
+		if err := rows.Scan(&currency, &total); err != nil {
+			logrus.WithError(err).Error("Failed to scan monthly cost")
+			return nil, fmt.Errorf("failed to scan monthly cost: %w", err)
+		}
+		totals[currency.String] += total
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate monthly cost: %w", err)
+	}
+
+	return totals, nil
+}
+
+func (r *subscriptionRepository) Update(ctx context.Context, id uuid.UUID, updates map[string]interface{}, expectedVersion *int, skipUnchanged bool, limit UserLimit) (*model.Subscription, error) {
 	if len(updates) == 0 {
-		return nil
+		return r.GetByID(ctx, id)
 	}
 
 	setClauses := make([]string, 0, len(updates))
-	args := make([]interface{}, 0, len(updates)+1)
+	columns := make([]string, 0, len(updates))
+	placeholders := make([]string, 0, len(updates))
+	args := make([]interface{}, 0, len(updates)+2)
 	i := 1
 
 	for field, value := range updates {
+		if list, ok := value.([]string); ok {
+			value = pq.Array(list)
+		}
 		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", field, i))
+		columns = append(columns, field)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i))
 		args = append(args, value)
 		i++
 	}
 
-	setClauses = append(setClauses, fmt.Sprintf("updated_at = $%d", i))
-	args = append(args, time.Now())
+	setClauses = append(setClauses, fmt.Sprintf("updated_at = $%d", i), "version = version + 1")
+	args = append(args, time.Now().UTC())
 	i++
 
+	where := fmt.Sprintf("id = $%d", i)
 	args = append(args, id)
+	i++
+
+	if expectedVersion != nil {
+		where += fmt.Sprintf(" AND version = $%d", i)
+		args = append(args, *expectedVersion)
+	}
+
+	if skipUnchanged {
+		// ROW(...) позволяет сравнить и одну колонку: (x) - просто скобки, не строка.
+		where += fmt.Sprintf(" AND ROW(%s) IS DISTINCT FROM ROW(%s)",
+			strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	}
 
 	query := fmt.Sprintf(`
         UPDATE subscriptions
         SET %s
-        WHERE id = $%d
-    `, strings.Join(setClauses, ", "), i)
+        WHERE %s
+        RETURNING %s
+    `, strings.Join(setClauses, ", "), where, subscriptionColumns)
+	query = r.withEvent(query, model.EventSubscriptionUpdated)
+
+	update := func(q queryer) (*model.Subscription, error) {
+		return scanSubscription(q.QueryRowContext(ctx, query, args...))
+	}
 
-	result, err := r.db.Exec(query, args...)
+	var sub *model.Subscription
+	var err error
+	if toUserID, ok := updates["user_id"].(uuid.UUID); ok && limit.Max > 0 {
+		sub, err = r.reassign(ctx, toUserID, limit, update)
+	} else {
+		sub, err = update(r.db)
+	}
 	if err != nil {
+		if errors.Is(err, ErrUserLimitExceeded) {
+			return nil, err
+		}
+		if errors.Is(err, sql.ErrNoRows) && skipUnchanged {
+			return r.unchangedOrMissing(ctx, id, expectedVersion)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, r.missingOrConflict(ctx, id, expectedVersion)
+		}
 		logrus.WithError(err).WithField("id", id).Error("Failed to update subscription")
-		return fmt.Errorf("failed to update subscription: %w", err)
+		return nil, fmt.Errorf("failed to update subscription: %w", readOnlyError(err))
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
+	// Логируются только имена полей: значения (user_id, metadata) могут быть
+	// персональными данными, а PII-хук обрабатывает лишь поля верхнего уровня.
+	fields := append([]string(nil), columns...)
+	sort.Strings(fields)
 
 	logrus.WithFields(logrus.Fields{
-		"id":     id,
-		"fields": updates,
+		"id":      id,
+		"fields":  fields,
+		"version": sub.Version,
 	}).Info("Subscription updated successfully")
 
-	return nil
+	return sub, nil
+}
+
+// reassign выполняет update, меняющий владельца на toUserID, в транзакции под
+// блокировкой нового владельца и проверяет его лимит.
+func (r *subscriptionRepository) reassign(ctx context.Context, toUserID uuid.UUID, limit UserLimit, update func(q queryer) (*model.Subscription, error)) (*model.Subscription, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, toUserID); err != nil {
+		return nil, err
+	}
+
+	sub, err := update(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkUserLimit(ctx, tx, sub, limit); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit subscription update: %w", err)
+	}
+	return sub, nil
 }
 
-func (r *subscriptionRepository) Delete(id uuid.UUID) error {
+func (r *subscriptionRepository) Delete(ctx context.Context, id uuid.UUID, expectedVersion *int) (*model.Subscription, error) {
 	query := `DELETE FROM subscriptions WHERE id = $1`
+	args := []interface{}{id}
+
+	if expectedVersion != nil {
+		query += ` AND version = $2`
+		args = append(args, *expectedVersion)
+	}
+	query = r.withEvent(query+` RETURNING `+subscriptionColumns, model.EventSubscriptionDeleted)
 
-	result, err := r.db.Exec(query, id)
+	sub, err := scanSubscription(r.db.QueryRowContext(ctx, query, args...))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, r.missingOrConflict(ctx, id, expectedVersion)
+		}
 		logrus.WithError(err).WithField("id", id).Error("Failed to delete subscription")
-		return fmt.Errorf("failed to delete subscription: %w", err)
+		return nil, fmt.Errorf("failed to delete subscription: %w", readOnlyError(err))
+	}
+
+	logrus.WithField("id", id).Info("Subscription deleted successfully")
+	return sub, nil
+}
+
+func (r *subscriptionRepository) Transfer(ctx context.Context, id, toUserID uuid.UUID, limit UserLimit, check func(current *model.Subscription) error) (*model.Subscription, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка получателя берётся до блокировки строки, в том же порядке,
+	// что и в Update со сменой user_id.
+	if limit.Max > 0 {
+		if err := lockUser(ctx, tx, toUserID); err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := scanSubscription(tx.QueryRowContext(ctx, `
+        SELECT `+subscriptionColumns+`
+        FROM subscriptions
+        WHERE id = $1
+        FOR UPDATE`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		logrus.WithError(err).WithField("id", id).Error("Failed to lock subscription for transfer")
+		return nil, fmt.Errorf("failed to lock subscription: %w", err)
+	}
+
+	if err := check(current); err != nil {
+		return nil, err
+	}
+
+	sub, err := scanSubscription(tx.QueryRowContext(ctx, `
+        UPDATE subscriptions
+        SET user_id = $2, updated_at = $3, version = version + 1
+        WHERE id = $1
+        RETURNING `+subscriptionColumns, id, toUserID, time.Now().UTC()))
+	if err != nil {
+		logrus.WithError(err).WithField("id", id).Error("Failed to transfer subscription")
+		return nil, fmt.Errorf("failed to transfer subscription: %w", readOnlyError(err))
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	if err := checkUserLimit(ctx, tx, sub, limit); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO subscription_transfers (subscription_id, from_user_id, to_user_id)
+        VALUES ($1, $2, $3)`, id, current.UserID, toUserID)
+	if err != nil {
+		logrus.WithError(err).WithField("id", id).Error("Failed to record subscription transfer")
+		return nil, fmt.Errorf("failed to record subscription transfer: %w", readOnlyError(err))
+	}
+
+	if r.outbox {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO outbox (aggregate_id, event_type, payload)
+            SELECT id, $2, to_jsonb(s) || jsonb_build_object('previous_user_id', $3::uuid)
+            FROM subscriptions s
+            WHERE id = $1`, id, model.EventSubscriptionTransferred, current.UserID)
+		if err != nil {
+			logrus.WithError(err).WithField("id", id).Error("Failed to write transfer event")
+			return nil, fmt.Errorf("failed to write transfer event: %w", readOnlyError(err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit subscription transfer: %w", readOnlyError(err))
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":           id,
+		"from_user_id": current.UserID,
+		"to_user_id":   toUserID,
+	}).Info("Subscription transferred successfully")
+
+	return sub, nil
+}
+
+// missingOrConflict объясняет, почему условный UPDATE/DELETE не затронул строк:
+// подписки нет (sql.ErrNoRows) или её version уже другая (ErrVersionConflict).
+func (r *subscriptionRepository) missingOrConflict(ctx context.Context, id uuid.UUID, expectedVersion *int) error {
+	if expectedVersion == nil {
 		return sql.ErrNoRows
 	}
 
-	logrus.WithField("id", id).Info("Subscription deleted successfully")
-	return nil
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = $1)`, id).Scan(&exists); err != nil {
+		logrus.WithError(err).WithField("id", id).Error("Failed to check subscription existence")
+		return fmt.Errorf("failed to check subscription existence: %w", err)
+	}
+
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return ErrVersionConflict
+}
+
+// unchangedOrMissing объясняет, почему UPDATE с проверкой на изменения не
+// затронул строк: подписки нет, её version другая или менять было нечего.
+func (r *subscriptionRepository) unchangedOrMissing(ctx context.Context, id uuid.UUID, expectedVersion *int) (*model.Subscription, error) {
+	current, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		return nil, sql.ErrNoRows
+	}
+	if expectedVersion != nil && current.Version != *expectedVersion {
+		return nil, ErrVersionConflict
+	}
+
+	logrus.WithField("id", id).Debug("Subscription update is a no-op, skipping write")
+	return current, ErrNotModified
+}
+
+// orderByClause строит ORDER BY по разрешённому полю сортировки. Вторичный
+// ключ id DESC делает порядок детерминированным для стабильной пагинации.
+func orderByClause(sort string) string {
+	field, desc, ok := model.ParseSort(sort)
+	if !ok {
+		field, desc = "start_date", true
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	return fmt.Sprintf(" ORDER BY %s %s, id DESC", field, direction)
 }
 
-func (r *subscriptionRepository) List(filter model.SubscriptionFilter) ([]*model.Subscription, error) {
+// buildListQuery строит запрос выборки колонок columns по фильтру. Пагинация
+// добавляется, только если заданы Limit/Offset.
+func buildListQuery(columns string, filter model.SubscriptionFilter) (string, []interface{}) {
 	query := `
-        SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at
+        SELECT ` + columns + `
         FROM subscriptions
         WHERE 1=1
     `
 	args := make([]interface{}, 0)
 	i := 1
 
+	if len(filter.IDs) > 0 {
+		ids := make([]string, len(filter.IDs))
+		for j, id := range filter.IDs {
+			ids[j] = id.String()
+		}
+		query += fmt.Sprintf(" AND id = ANY($%d::uuid[])", i)
+		args = append(args, pq.Array(ids))
+		i++
+	}
+
 	if filter.UserID != nil {
 		query += fmt.Sprintf(" AND user_id = $%d", i)
 		args = append(args, *filter.UserID)
@@ -163,8 +886,14 @@ func (r *subscriptionRepository) List(filter model.SubscriptionFilter) ([]*model
 	}
 
 	if filter.ServiceName != nil {
-		query += fmt.Sprintf(" AND service_name ILIKE $%d", i)
-		args = append(args, "%"+*filter.ServiceName+"%")
+		query += fmt.Sprintf(" AND service_name ILIKE ANY($%d)", i)
+		args = append(args, pq.Array(serviceNamePatterns(*filter.ServiceName)))
+		i++
+	}
+
+	if filter.Tag != nil {
+		query += fmt.Sprintf(" AND tags @> ARRAY[$%d]::text[]", i)
+		args = append(args, *filter.Tag)
 		i++
 	}
 
@@ -180,7 +909,47 @@ func (r *subscriptionRepository) List(filter model.SubscriptionFilter) ([]*model
 		i++
 	}
 
-	query += " ORDER BY start_date DESC"
+	if filter.CreatedFrom != nil {
+		query += fmt.Sprintf(" AND created_at >= $%d", i)
+		args = append(args, *filter.CreatedFrom)
+		i++
+	}
+
+	if filter.CreatedTo != nil {
+		query += fmt.Sprintf(" AND created_at < $%d", i)
+		args = append(args, *filter.CreatedTo)
+		i++
+	}
+
+	if filter.ActiveOn != nil {
+		query += fmt.Sprintf(" AND start_date <= $%d AND (end_date IS NULL OR end_date >= $%d)", i, i)
+		args = append(args, *filter.ActiveOn)
+		i++
+	}
+
+	if filter.UpdatedAfter != nil {
+		query += fmt.Sprintf(" AND updated_at > $%d", i)
+		args = append(args, *filter.UpdatedAfter)
+		i++
+	}
+
+	if len(filter.Metadata) > 0 {
+		// Ошибка невозможна: map[string]string всегда сериализуется.
+		contains, _ := json.Marshal(filter.Metadata)
+		query += fmt.Sprintf(" AND metadata @> $%d::jsonb", i)
+		args = append(args, string(contains))
+		i++
+	}
+
+	if filter.OpenEnded != nil {
+		if *filter.OpenEnded {
+			query += " AND end_date IS NULL"
+		} else {
+			query += " AND end_date IS NOT NULL"
+		}
+	}
+
+	query += orderByClause(filter.Sort)
 
 	if filter.Limit > 0 {
 		query += fmt.Sprintf(" LIMIT $%d", i)
@@ -193,35 +962,291 @@ func (r *subscriptionRepository) List(filter model.SubscriptionFilter) ([]*model
 		args = append(args, filter.Offset)
 	}
 
-	rows, err := r.db.Query(query, args...)
+	return query, args
+}
+
+func (r *subscriptionRepository) List(ctx context.Context, filter model.SubscriptionFilter) ([]*model.Subscription, int, int, error) {
+	// Окно считается до LIMIT/OFFSET, поэтому каждая строка страницы несёт общее
+	// число совпадений: страница и total берутся из одного снимка данных.
+	query, args := buildListQuery(subscriptionColumns+", COUNT(*) OVER ()", filter)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to list subscriptions")
-		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+		return nil, 0, 0, fmt.Errorf("failed to list subscriptions: %w", err)
 	}
 	defer rows.Close()
 
 	var subscriptions []*model.Subscription
+	var total int
+	skipped, err := scanSubscriptionRows(rows, func(sub *model.Subscription) error {
+		subscriptions = append(subscriptions, sub)
+		return nil
+	}, &total)
+	if err != nil {
+		return nil, 0, skipped, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	// Пустая страница не несёт total. Без смещения совпадений нет, а при offset
+	// за концом выборки общее число нужно посчитать отдельно.
+	if len(subscriptions) == 0 && skipped == 0 && filter.Offset > 0 {
+		if total, err = r.count(ctx, filter); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	return subscriptions, total, skipped, nil
+}
+
+// count возвращает число подписок по фильтру без учёта Limit/Offset.
+func (r *subscriptionRepository) count(ctx context.Context, filter model.SubscriptionFilter) (int, error) {
+	filter.Limit, filter.Offset, filter.Sort = 0, 0, ""
+	filtered, args := buildListQuery("1", filter)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+filtered+") f", args...).Scan(&total); err != nil {
+		logrus.WithError(err).Error("Failed to count subscriptions")
+		return 0, fmt.Errorf("failed to count subscriptions: %w", err)
+	}
+	return total, nil
+}
+
+func (r *subscriptionRepository) ListRefs(ctx context.Context, filter model.SubscriptionFilter) ([]model.SubscriptionRef, int, error) {
+	query, args := buildListQuery("id, updated_at, COUNT(*) OVER ()", filter)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list subscription refs")
+		return nil, 0, fmt.Errorf("failed to list subscription refs: %w", err)
+	}
+	defer rows.Close()
+
+	refs := make([]model.SubscriptionRef, 0)
+	var total int
 	for rows.Next() {
-		var sub model.Subscription
-		err := rows.Scan(
-			&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID,
-			&sub.StartDate, &sub.EndDate, &sub.CreatedAt, &sub.UpdatedAt,
-		)
-		if err != nil {
-			logrus.WithError(err).Error("Failed to scan subscription")
-			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		var ref model.SubscriptionRef
+		if err := rows.Scan(&ref.ID, &ref.UpdatedAt, &total); err != nil {
+			logrus.WithError(err).Error("Failed to scan subscription ref")
+			return nil, 0, fmt.Errorf("failed to scan subscription ref: %w", err)
+		}
+		ref.UpdatedAt = ref.UpdatedAt.UTC()
+		refs = append(refs, ref)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list subscription refs: %w", err)
+	}
+
+	if len(refs) == 0 && filter.Offset > 0 {
+		if total, err = r.count(ctx, filter); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return refs, total, nil
+}
+
+func (r *subscriptionRepository) ListExpiring(ctx context.Context, from, to time.Time, limit, offset int) ([]*model.Subscription, int, error) {
+	where := `
+        FROM subscriptions
+        WHERE end_date IS NOT NULL AND end_date >= $1 AND end_date <= $2`
+	query := `
+        SELECT ` + subscriptionColumns + `, COUNT(*) OVER ()` + where + `
+        ORDER BY end_date, id
+        OFFSET $3`
+	args := []interface{}{from, to, offset}
+	if limit > 0 {
+		query += " LIMIT $4"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list expiring subscriptions")
+		return nil, 0, fmt.Errorf("failed to list expiring subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*model.Subscription
+	var total int
+	skipped, err := scanSubscriptionRows(rows, func(sub *model.Subscription) error {
+		subscriptions = append(subscriptions, sub)
+		return nil
+	}, &total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list expiring subscriptions: %w", err)
+	}
+
+	// Как и в List: пустая страница за концом выборки не несёт total.
+	if len(subscriptions) == 0 && skipped == 0 && offset > 0 {
+		if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+where, from, to).Scan(&total); err != nil {
+			logrus.WithError(err).Error("Failed to count expiring subscriptions")
+			return nil, 0, fmt.Errorf("failed to count expiring subscriptions: %w", err)
+		}
+	}
+
+	return subscriptions, total, nil
+}
+
+func (r *subscriptionRepository) Facet(ctx context.Context, filter model.SubscriptionFilter, field string, today time.Time) (map[string]int, error) {
+	filter.Limit, filter.Offset = 0, 0
+	filtered, args := buildListQuery("service_name, start_date, end_date", filter)
+
+	key := "service_name"
+	if field == model.FacetStatus {
+		key = fmt.Sprintf(`
+            CASE
+                WHEN start_date > $%[1]d THEN '%[2]s'
+                WHEN end_date < $%[1]d THEN '%[3]s'
+                ELSE '%[4]s'
+            END`, len(args)+1, model.StatusUpcoming, model.StatusExpired, model.StatusActive)
+		args = append(args, today)
+	}
+
+	query := `
+        SELECT ` + key + ` AS facet, COUNT(*)
+        FROM (` + filtered + `) filtered
+        GROUP BY facet
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithError(err).WithField("facet", field).Error("Failed to count subscription facet")
+		return nil, fmt.Errorf("failed to count subscription facet: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var value string
+		var count int
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription facet: %w", err)
 		}
-		subscriptions = append(subscriptions, &sub)
+		counts[value] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count subscription facet: %w", err)
+	}
+
+	return counts, nil
+}
+
+func (r *subscriptionRepository) Top(ctx context.Context, by string, limit int, today time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]*model.Subscription, error) {
+	query := `
+        SELECT ` + subscriptionColumns + `
+        FROM subscriptions
+        WHERE 1=1
+    `
+	args := []interface{}{}
+	i := 1
+
+	if userID != nil {
+		query += fmt.Sprintf(" AND user_id = $%d", i)
+		args = append(args, *userID)
+		i++
+	}
+
+	if serviceName != nil {
+		query += fmt.Sprintf(" AND service_name ILIKE ANY($%d)", i)
+		args = append(args, pq.Array(serviceNamePatterns(*serviceName)))
+		i++
+	}
+
+	if by == model.TopByDuration {
+		// Бессрочные и ещё идущие подписки считаются до today.
+		query += fmt.Sprintf(" ORDER BY LEAST(COALESCE(end_date, $%d::date), $%d::date) - start_date DESC, id DESC", i, i)
+		args = append(args, today)
+		i++
+	} else {
+		query += " ORDER BY price DESC, id DESC"
+	}
+
+	query += fmt.Sprintf(" LIMIT $%d", i)
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list top subscriptions")
+		return nil, fmt.Errorf("failed to list top subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*model.Subscription
+	_, err = scanSubscriptionRows(rows, func(sub *model.Subscription) error {
+		subscriptions = append(subscriptions, sub)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list top subscriptions: %w", err)
 	}
 
 	return subscriptions, nil
 }
 
-func (r *subscriptionRepository) Aggregate(startDate, endDate time.Time, userID *uuid.UUID, serviceName *string) (int, error) {
+func (r *subscriptionRepository) Changes(ctx context.Context, since time.Time, afterID *uuid.UUID, limit int) ([]*model.Subscription, error) {
 	query := `
-        SELECT COALESCE(SUM(
-            price * (
-                -- количество месяцев пересечения периода подписки с заданным периодом
+        SELECT ` + subscriptionColumns + `
+        FROM subscriptions
+        WHERE updated_at > $1
+        ORDER BY updated_at, id
+        LIMIT $2
+    `
+	args := []interface{}{since, limit}
+
+	if afterID != nil {
+		query = `
+            SELECT ` + subscriptionColumns + `
+            FROM subscriptions
+            WHERE (updated_at, id) > ($1, $3)
+            ORDER BY updated_at, id
+            LIMIT $2
+        `
+		args = append(args, *afterID)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list subscription changes")
+		return nil, fmt.Errorf("failed to list subscription changes: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*model.Subscription
+	_, err = scanSubscriptionRows(rows, func(sub *model.Subscription) error {
+		subscriptions = append(subscriptions, sub)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscription changes: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+func (r *subscriptionRepository) Export(ctx context.Context, filter model.SubscriptionFilter, fn func(*model.Subscription) error) error {
+	query, args := buildListQuery(subscriptionColumns, filter)
+
+	return r.heavyRead(ctx, func(q queryer) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to export subscriptions")
+			return fmt.Errorf("failed to export subscriptions: %w", err)
+		}
+		defer rows.Close()
+
+		if _, err := scanSubscriptionRows(rows, fn); err != nil {
+			return fmt.Errorf("failed to export subscriptions: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// aggregateMonthsExpr - количество месяцев пересечения периода подписки
+// с заданным периодом ($1 - начало, $2 - конец).
+const aggregateMonthsExpr = `
                 EXTRACT(YEAR FROM age(
                     LEAST(COALESCE(end_date, $2), $2),
                     GREATEST(start_date, $1)
@@ -229,9 +1254,33 @@ func (r *subscriptionRepository) Aggregate(startDate, endDate time.Time, userID
                 EXTRACT(MONTH FROM age(
                     LEAST(COALESCE(end_date, $2), $2),
                     GREATEST(start_date, $1)
-                ))
-            )
-        ), 0)
+                ))`
+
+// aggregateTotalExpr суммирует стоимость подписок за количество месяцев
+// пересечения периода подписки с заданным периодом.
+const aggregateTotalExpr = `
+        COALESCE(SUM(price * (` + aggregateMonthsExpr + `
+        )), 0)`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// serviceNamePatterns строит шаблоны ILIKE для фильтра по названию сервиса,
+// по одному на значение. Спецсимволы LIKE во вводе экранируются, поэтому
+// "a_b" не совпадёт с "axb".
+func serviceNamePatterns(f model.ServiceNameFilter) []string {
+	patterns := make([]string, 0, len(f.Values))
+	for _, value := range f.Values {
+		pattern := likeEscaper.Replace(value)
+		if !f.Exact {
+			pattern = "%" + pattern + "%"
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+func aggregateFilters(startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (string, []interface{}) {
+	where := `
         FROM subscriptions
         WHERE start_date <= $2  -- подписка началась не позже конца периода
           AND (end_date IS NULL OR end_date >= $1)  -- и не закончилась до начала периода
@@ -240,18 +1289,27 @@ func (r *subscriptionRepository) Aggregate(startDate, endDate time.Time, userID
 	i := 3
 
 	if userID != nil {
-		query += fmt.Sprintf(" AND user_id = $%d", i)
+		where += fmt.Sprintf(" AND user_id = $%d", i)
 		args = append(args, *userID)
 		i++
 	}
 
 	if serviceName != nil {
-		query += fmt.Sprintf(" AND service_name = $%d", i)
-		args = append(args, *serviceName)
+		where += fmt.Sprintf(" AND service_name ILIKE ANY($%d)", i)
+		args = append(args, pq.Array(serviceNamePatterns(*serviceName)))
 	}
 
+	return where, args
+}
+
+func (r *subscriptionRepository) Aggregate(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (int, error) {
+	where, args := aggregateFilters(startDate, endDate, userID, serviceName)
+	query := "SELECT" + aggregateTotalExpr + where
+
 	var total int
-	err := r.db.QueryRow(query, args...).Scan(&total)
+	err := r.heavyRead(ctx, func(q queryer) error {
+		return q.QueryRowContext(ctx, query, args...).Scan(&total)
+	})
 	if err != nil {
 		logrus.WithError(err).Error("Failed to aggregate subscriptions")
 		return 0, fmt.Errorf("failed to aggregate subscriptions: %w", err)
@@ -259,3 +1317,259 @@ func (r *subscriptionRepository) Aggregate(startDate, endDate time.Time, userID
 
 	return total, nil
 }
+
+func (r *subscriptionRepository) SimulatePriceChange(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter, newPrice *int, percent *float64) (int, int, error) {
+	where, args := aggregateFilters(startDate, endDate, userID, serviceName)
+
+	projectedPrice := "price"
+	switch {
+	case newPrice != nil:
+		projectedPrice = fmt.Sprintf("$%d", len(args)+1)
+		args = append(args, *newPrice)
+	case percent != nil:
+		projectedPrice = fmt.Sprintf("ROUND(price * (1 + $%d::numeric / 100))", len(args)+1)
+		args = append(args, *percent)
+	}
+
+	query := "SELECT" + aggregateTotalExpr + `,
+        COALESCE(SUM(` + projectedPrice + ` * (` + aggregateMonthsExpr + `
+        )), 0)` + where
+
+	var current, projected int
+	err := r.heavyRead(ctx, func(q queryer) error {
+		return q.QueryRowContext(ctx, query, args...).Scan(&current, &projected)
+	})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to simulate price change")
+		return 0, 0, fmt.Errorf("failed to simulate price change: %w", err)
+	}
+
+	return current, projected, nil
+}
+
+func (r *subscriptionRepository) AggregateByCurrency(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (map[string]int, error) {
+	where, args := aggregateFilters(startDate, endDate, userID, serviceName)
+	query := "SELECT currency," + aggregateTotalExpr + where + " GROUP BY currency"
+
+	totals := make(map[string]int)
+	err := r.heavyRead(ctx, func(q queryer) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to aggregate subscriptions by currency")
+			return fmt.Errorf("failed to aggregate subscriptions by currency: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var currency sql.NullString
+			var total int
+			if err := rows.Scan(&currency, &total); err != nil {
+				logrus.WithError(err).Error("Failed to scan currency total")
+				return fmt.Errorf("failed to scan currency total: %w", err)
+			}
+			totals[currency.String] = total
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to aggregate subscriptions by currency: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+func (r *subscriptionRepository) AggregateByUsers(ctx context.Context, startDate, endDate time.Time, userIDs []uuid.UUID, serviceName *model.ServiceNameFilter) (map[uuid.UUID]int, error) {
+	where, args := aggregateFilters(startDate, endDate, nil, serviceName)
+
+	ids := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		ids[i] = id.String()
+	}
+	where += fmt.Sprintf(" AND user_id = ANY($%d::uuid[])", len(args)+1)
+	args = append(args, pq.Array(ids))
+
+	query := "SELECT user_id," + aggregateTotalExpr + where + " GROUP BY user_id"
+
+	totals := make(map[uuid.UUID]int, len(userIDs))
+	err := r.heavyRead(ctx, func(q queryer) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to aggregate subscriptions by users")
+			return fmt.Errorf("failed to aggregate subscriptions by users: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var userID uuid.UUID
+			var total int
+			if err := rows.Scan(&userID, &total); err != nil {
+				logrus.WithError(err).Error("Failed to scan user total")
+				return fmt.Errorf("failed to scan user total: %w", err)
+			}
+			totals[userID] = total
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to aggregate subscriptions by users: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+func (r *subscriptionRepository) AggregateMatrix(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter, limit int) ([]ServiceUserCurrencyTotal, error) {
+	where, args := aggregateFilters(startDate, endDate, userID, serviceName)
+	query := "SELECT service_name, user_id, currency," + aggregateTotalExpr + where + fmt.Sprintf(`
+        GROUP BY service_name, user_id, currency
+        ORDER BY service_name, user_id, currency
+        LIMIT $%d`, len(args)+1)
+	args = append(args, limit)
+
+	var totals []ServiceUserCurrencyTotal
+	err := r.heavyRead(ctx, func(q queryer) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to aggregate subscriptions by service and user")
+			return fmt.Errorf("failed to aggregate subscriptions by service and user: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var t ServiceUserCurrencyTotal
+			var currency sql.NullString
+			if err := rows.Scan(&t.ServiceName, &t.UserID, &currency, &t.Total); err != nil {
+				logrus.WithError(err).Error("Failed to scan service and user total")
+				return fmt.Errorf("failed to scan service and user total: %w", err)
+			}
+			t.Currency = currency.String
+			totals = append(totals, t)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to aggregate subscriptions by service and user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// AggregateByMonth раскладывает те же месяцы пересечения, что учитывает Aggregate,
+// по календарным месяцам, поэтому сумма помесячных значений совпадает с общей.
+func (r *subscriptionRepository) AggregateByMonth(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]MonthlyCurrencyTotal, error) {
+	where, args := aggregateFilters(startDate, endDate, userID, serviceName)
+	query := `
+        SELECT date_trunc('month', s.from_date + k * INTERVAL '1 month')::date AS month,
+               s.currency,
+               SUM(s.price)
+        FROM (
+            SELECT price, currency, GREATEST(start_date, $1) AS from_date,` + aggregateMonthsExpr + ` AS months
+            ` + where + `
+        ) s, generate_series(0, s.months::int - 1) AS k
+        GROUP BY month, s.currency
+        ORDER BY month, s.currency`
+
+	var totals []MonthlyCurrencyTotal
+	err := r.heavyRead(ctx, func(q queryer) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to aggregate subscriptions by month")
+			return fmt.Errorf("failed to aggregate subscriptions by month: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var t MonthlyCurrencyTotal
+			var currency sql.NullString
+			if err := rows.Scan(&t.Month, &currency, &t.Total); err != nil {
+				logrus.WithError(err).Error("Failed to scan monthly total")
+				return fmt.Errorf("failed to scan monthly total: %w", err)
+			}
+			t.Month = t.Month.UTC()
+			t.Currency = currency.String
+			totals = append(totals, t)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to aggregate subscriptions by month: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+func (r *subscriptionRepository) Timeline(ctx context.Context, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) ([]MonthlyCount, error) {
+	// Фильтры стоят в ON, а не в WHERE, чтобы месяцы без подписок попали в результат с нулём.
+	join := `
+            s.start_date <= (m + INTERVAL '1 month - 1 day')::date
+            AND (s.end_date IS NULL OR s.end_date >= (m + INTERVAL '1 month - 1 day')::date)`
+	args := []interface{}{startDate, endDate}
+	i := 3
+
+	if userID != nil {
+		join += fmt.Sprintf(" AND s.user_id = $%d", i)
+		args = append(args, *userID)
+		i++
+	}
+
+	if serviceName != nil {
+		join += fmt.Sprintf(" AND s.service_name ILIKE ANY($%d)", i)
+		args = append(args, pq.Array(serviceNamePatterns(*serviceName)))
+	}
+
+	query := `
+        SELECT m::date AS month, COUNT(s.id)
+        FROM generate_series(
+            date_trunc('month', $1::date),
+            date_trunc('month', $2::date),
+            INTERVAL '1 month'
+        ) AS m
+        LEFT JOIN subscriptions s ON` + join + `
+        GROUP BY m
+        ORDER BY m`
+
+	var points []MonthlyCount
+	err := r.heavyRead(ctx, func(q queryer) error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to build subscriptions timeline")
+			return fmt.Errorf("failed to build subscriptions timeline: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var p MonthlyCount
+			if err := rows.Scan(&p.Month, &p.Count); err != nil {
+				logrus.WithError(err).Error("Failed to scan timeline point")
+				return fmt.Errorf("failed to scan timeline point: %w", err)
+			}
+			p.Month = p.Month.UTC()
+			points = append(points, p)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to build subscriptions timeline: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
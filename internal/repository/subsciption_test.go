@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"subscription_service/internal/model"
+
+	"github.com/lib/pq"
+)
+
+func TestBuildListQueryOrdersByIDTiebreaker(t *testing.T) {
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"", "ORDER BY start_date DESC, id DESC"},
+		{"unknown", "ORDER BY start_date DESC, id DESC"},
+		{"-start_date", "ORDER BY start_date DESC, id DESC"},
+		{"start_date", "ORDER BY start_date ASC, id DESC"},
+		{"price", "ORDER BY price ASC, id DESC"},
+		{"-created_at", "ORDER BY created_at DESC, id DESC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			query, _ := buildListQuery("id", model.SubscriptionFilter{Sort: tt.sort, Limit: 10, Offset: 20})
+
+			if strings.Count(query, "ORDER BY") != 1 || !strings.Contains(query, tt.want) {
+				t.Fatalf("query %q does not contain %q", query, tt.want)
+			}
+
+			// Тай-брейкер должен применяться до пагинации, иначе OFFSET режет
+			// недетерминированный порядок строк с одинаковой start_date.
+			orderAt := strings.Index(query, "ORDER BY")
+			if limitAt := strings.Index(query, "LIMIT"); limitAt < orderAt {
+				t.Errorf("LIMIT precedes ORDER BY in %q", query)
+			}
+			if offsetAt := strings.Index(query, "OFFSET"); offsetAt < orderAt {
+				t.Errorf("OFFSET precedes ORDER BY in %q", query)
+			}
+		})
+	}
+}
+
+func TestOrderByClauseCoversAllSortFields(t *testing.T) {
+	for _, field := range model.SortFields {
+		for _, sort := range []string{field, "-" + field} {
+			if clause := orderByClause(sort); !strings.HasSuffix(clause, ", id DESC") {
+				t.Errorf("orderByClause(%q) = %q, want id DESC tiebreaker", sort, clause)
+			}
+		}
+	}
+}
+
+// ilike повторяет семантику ILIKE с экранированием обратной косой чертой,
+// чтобы проверять шаблоны без базы данных.
+func ilike(pattern, value string) bool {
+	var expr strings.Builder
+	expr.WriteString(`(?is)^`)
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr.WriteString(`.*`)
+		case r == '_':
+			expr.WriteString(`.`)
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString(`$`)
+	return regexp.MustCompile(expr.String()).MatchString(value)
+}
+
+// serviceNameArg возвращает шаблоны фильтра по сервису из аргументов запроса.
+func serviceNameArg(t *testing.T, query string, args []interface{}) []string {
+	t.Helper()
+
+	if !strings.Contains(query, "service_name ILIKE ANY(") {
+		t.Fatalf("query %q has no service_name filter", query)
+	}
+	for _, arg := range args {
+		if patterns, ok := arg.(*pq.StringArray); ok {
+			return *patterns
+		}
+	}
+	t.Fatalf("no service_name patterns in args %v", args)
+	return nil
+}
+
+func TestServiceNameMatchingIsSharedByListAndAggregate(t *testing.T) {
+	names := []string{"Yandex Plus", "yandex plus family", "Netflix", "Apple TV+", "100% Cloud", "my_service", "myXservice"}
+
+	tests := []struct {
+		name   string
+		filter model.ServiceNameFilter
+		want   []string
+	}{
+		{"substring is case-insensitive", model.ServiceNameFilter{Values: []string{"PLUS"}}, []string{"Yandex Plus", "yandex plus family"}},
+		{"substring in the middle", model.ServiceNameFilter{Values: []string{"flix"}}, []string{"Netflix"}},
+		{"several values", model.ServiceNameFilter{Values: []string{"netflix", "apple"}}, []string{"Netflix", "Apple TV+"}},
+		{"exact matches whole name only", model.ServiceNameFilter{Values: []string{"yandex plus"}, Exact: true}, []string{"Yandex Plus"}},
+		{"percent is literal", model.ServiceNameFilter{Values: []string{"100%"}}, []string{"100% Cloud"}},
+		{"underscore is literal", model.ServiceNameFilter{Values: []string{"my_"}}, []string{"my_service"}},
+	}
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+
+			listQuery, listArgs := buildListQuery("id", model.SubscriptionFilter{ServiceName: &filter})
+			listPatterns := serviceNameArg(t, listQuery, listArgs)
+
+			aggregateWhere, aggregateArgs := aggregateFilters(start, end, nil, &filter)
+			aggregatePatterns := serviceNameArg(t, aggregateWhere, aggregateArgs)
+
+			if strings.Join(listPatterns, "\x00") != strings.Join(aggregatePatterns, "\x00") {
+				t.Fatalf("list patterns %q differ from aggregate patterns %q", listPatterns, aggregatePatterns)
+			}
+
+			var matched []string
+			for _, name := range names {
+				for _, pattern := range listPatterns {
+					if ilike(pattern, name) {
+						matched = append(matched, name)
+						break
+					}
+				}
+			}
+			if strings.Join(matched, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("matched %q, want %q", matched, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// summaryBackfill пересобирает subscription_summaries из subscriptions; то же,
+// что делает миграция 000014 при создании таблицы.
+const summaryBackfill = `
+        INSERT INTO subscription_summaries (user_id, month, currency, delta)
+        SELECT user_id, month, currency, SUM(delta)
+        FROM (
+            SELECT user_id, date_trunc('month', start_date)::date AS month, currency, price::bigint AS delta FROM subscriptions
+            UNION ALL
+            SELECT user_id, date_trunc('month', end_date)::date, currency, -price::bigint FROM subscriptions WHERE end_date IS NOT NULL
+        ) d
+        GROUP BY user_id, month, currency`
+
+type SummaryRepository interface {
+	// TotalsByCurrency суммирует по сводке стоимость подписок за месяцы
+	// [startMonth, endMonth) по валютам. Совпадает с AggregateByCurrency, когда
+	// даты подписок и начало периода выровнены по месяцу.
+	TotalsByCurrency(ctx context.Context, startMonth, endMonth time.Time, userID *uuid.UUID) (map[string]int, error)
+	// Rebuild пересобирает сводку целиком и возвращает число строк в ней.
+	Rebuild() (int, error)
+}
+
+type summaryRepository struct {
+	db *sql.DB
+}
+
+func NewSummaryRepository(db *sql.DB) SummaryRepository {
+	return &summaryRepository{db: db}
+}
+
+func (r *summaryRepository) TotalsByCurrency(ctx context.Context, startMonth, endMonth time.Time, userID *uuid.UUID) (map[string]int, error) {
+	// Строка с delta за месяц m действует на каждый месяц с max(m, startMonth)
+	// до endMonth не включительно.
+	query := `
+        SELECT currency, COALESCE(SUM(delta * (
+            EXTRACT(YEAR FROM age($2, GREATEST(month, $1))) * 12 +
+            EXTRACT(MONTH FROM age($2, GREATEST(month, $1)))
+        )), 0)::bigint
+        FROM subscription_summaries
+        WHERE month < $2 AND $1 < $2`
+	args := []interface{}{startMonth, endMonth}
+
+	if userID != nil {
+		query += " AND user_id = $3"
+		args = append(args, *userID)
+	}
+	query += " GROUP BY currency"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to aggregate subscription summaries")
+		return nil, fmt.Errorf("failed to aggregate subscription summaries: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int)
+	for rows.Next() {
+		var currency string
+		var total int
+		if err := rows.Scan(&currency, &total); err != nil {
+			logrus.WithError(err).Error("Failed to scan summary total")
+			return nil, fmt.Errorf("failed to scan summary total: %w", err)
+		}
+		totals[currency] = total
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to aggregate subscription summaries: %w", err)
+	}
+
+	return totals, nil
+}
+
+func (r *summaryRepository) Rebuild() (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SHARE не даёт писать в subscriptions до конца пересборки, иначе триггер
+	// применил бы изменение к сводке, которую сейчас заменят.
+	if _, err := tx.Exec("LOCK TABLE subscriptions IN SHARE MODE"); err != nil {
+		logrus.WithError(err).Error("Failed to lock subscriptions for summary rebuild")
+		return 0, fmt.Errorf("failed to lock subscriptions: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM subscription_summaries"); err != nil {
+		logrus.WithError(err).Error("Failed to clear subscription summaries")
+		return 0, fmt.Errorf("failed to clear subscription summaries: %w", err)
+	}
+
+	result, err := tx.Exec(summaryBackfill)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to rebuild subscription summaries")
+		return 0, fmt.Errorf("failed to rebuild subscription summaries: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rebuilt summaries: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit summary rebuild: %w", err)
+	}
+
+	return int(rows), nil
+}
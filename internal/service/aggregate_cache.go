@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/store"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// aggregateCacheMetrics - счётчики попаданий и промахов, доступные через expvar
+// (GET /api/v1/admin/vars).
+var aggregateCacheMetrics = expvar.NewMap("aggregate_cache")
+
+// maxAggregateCacheEntries ограничивает размер кэша: ключ включает период,
+// пользователя и фильтр по сервису, поэтому различных ключей неограниченно много.
+const maxAggregateCacheEntries = 1000
+
+// Ключи кэша в общем хранилище. Записи лежат под номером поколения, поэтому
+// сброс - это увеличение счётчика поколений, а записи прошлых поколений
+// больше не читаются и истекают сами.
+const (
+	aggregateGenerationKey = "aggregate:generation"
+	aggregateEntryPrefix   = "aggregate:entry:"
+)
+
+type cachedAggregate struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// AggregateCache - кэш результатов агрегаций по нормализованным параметрам
+// запроса. Любая запись через сервис, фоновые задачи (продление, очистка) и
+// административные пересчёты сбрасывают кэш целиком, так как одна подписка
+// влияет на итоги любых периодов. Изменения курсов валют видны не позже чем
+// через ttl. nil-кэш (кэширование выключено) ничего не хранит.
+//
+// С общим хранилищем (Redis) кэш и его сбросы общие для всех реплик. Пока
+// хранилище недоступно, кэш работает в памяти процесса: сбросы с других реплик
+// до него не доходят, и итоги могут отставать от данных не больше чем на ttl.
+//
+// В памяти истёкшие записи удаляются при вставке не чаще раза в ttl, а при
+// достижении maxAggregateCacheEntries вытесняется запись, которая истекает
+// раньше всех.
+type AggregateCache struct {
+	ttl    time.Duration
+	shared store.Store
+
+	mu        sync.RWMutex
+	entries   map[string]cachedAggregate
+	nextSweep time.Time
+	// gen растёт при каждом сбросе: результат, посчитанный до сброса, не
+	// попадает в кэш (см. set).
+	gen uint64
+}
+
+// aggregateGeneration - поколения кэша на момент начала расчёта. shared < 0 -
+// общего хранилища нет или оно недоступно, и результат сохраняется в памяти.
+type aggregateGeneration struct {
+	local  uint64
+	shared int64
+}
+
+// NewAggregateCache создаёт кэш с временем жизни записей ttl; ttl <= 0 - nil
+// (кэширование выключено). shared - общее для реплик хранилище, nil - кэш
+// только в памяти. Один кэш передаётся всем, кто меняет подписки.
+func NewAggregateCache(ttl time.Duration, shared store.Store) *AggregateCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &AggregateCache{ttl: ttl, shared: shared, entries: make(map[string]cachedAggregate)}
+}
+
+// get читает результат по key в dst. При недоступном общем хранилище читает
+// из памяти.
+func (c *AggregateCache) get(ctx context.Context, key string, dst interface{}) bool {
+	if c == nil {
+		return false
+	}
+
+	value, ok, err := c.getShared(ctx, key)
+	if c.shared == nil || err != nil {
+		value, ok = c.getLocal(key)
+	}
+
+	if ok && json.Unmarshal(value, dst) == nil {
+		aggregateCacheMetrics.Add("hits", 1)
+		return true
+	}
+	aggregateCacheMetrics.Add("misses", 1)
+	return false
+}
+
+func (c *AggregateCache) getShared(ctx context.Context, key string) ([]byte, bool, error) {
+	if c.shared == nil {
+		return nil, false, nil
+	}
+
+	gen, err := c.sharedGeneration(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, ok, err := c.shared.Get(ctx, sharedAggregateKey(gen, key))
+	if err != nil {
+		aggregateCacheMetrics.Add("shared_errors", 1)
+	}
+	return value, ok, err
+}
+
+func (c *AggregateCache) getLocal(key string) ([]byte, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// sharedGeneration читает счётчик поколений; пока кэш не сбрасывался, его нет.
+func (c *AggregateCache) sharedGeneration(ctx context.Context) (int64, error) {
+	value, ok, err := c.shared.Get(ctx, aggregateGenerationKey)
+	if err != nil {
+		aggregateCacheMetrics.Add("shared_errors", 1)
+		return 0, err
+	}
+	if !ok {
+		return 0, nil
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+func sharedAggregateKey(gen int64, key string) string {
+	return aggregateEntryPrefix + strconv.FormatInt(gen, 10) + ":" + key
+}
+
+// generation возвращает поколения кэша. Их берут до расчёта агрегации и
+// передают в set.
+func (c *AggregateCache) generation(ctx context.Context) aggregateGeneration {
+	if c == nil {
+		return aggregateGeneration{}
+	}
+
+	c.mu.RLock()
+	gen := aggregateGeneration{local: c.gen, shared: -1}
+	c.mu.RUnlock()
+
+	if c.shared != nil {
+		if shared, err := c.sharedGeneration(ctx); err == nil {
+			gen.shared = shared
+		}
+	}
+	return gen
+}
+
+// set сохраняет value, только если с момента generation() кэш не сбрасывался:
+// иначе расчёт мог прочитать данные до записи, сбросившей кэш. В общем
+// хранилище такая запись ложится под старое поколение и не читается.
+func (c *AggregateCache) set(ctx context.Context, key string, value interface{}, gen aggregateGeneration) {
+	if c == nil {
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to encode aggregate for cache")
+		return
+	}
+
+	if gen.shared >= 0 {
+		err := c.shared.Set(ctx, sharedAggregateKey(gen.shared, key), data, c.ttl)
+		if err == nil {
+			return
+		}
+		aggregateCacheMetrics.Add("shared_errors", 1)
+	}
+
+	c.setLocal(key, data, gen.local)
+}
+
+func (c *AggregateCache) setLocal(key string, data []byte, gen uint64) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		aggregateCacheMetrics.Add("stale_sets", 1)
+		return
+	}
+
+	if _, ok := c.entries[key]; !ok {
+		if now.After(c.nextSweep) || len(c.entries) >= maxAggregateCacheEntries {
+			c.sweep(now)
+		}
+		if len(c.entries) >= maxAggregateCacheEntries {
+			c.evictOldest()
+		}
+	}
+	c.entries[key] = cachedAggregate{value: data, expiresAt: now.Add(c.ttl)}
+}
+
+// sweep удаляет истёкшие записи. Вызывается под c.mu.
+func (c *AggregateCache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.nextSweep = now.Add(c.ttl)
+}
+
+// evictOldest удаляет запись с самым ранним сроком истечения. Вызывается под c.mu.
+func (c *AggregateCache) evictOldest() {
+	var oldest string
+	var oldestAt time.Time
+	for key, entry := range c.entries {
+		if oldest == "" || entry.expiresAt.Before(oldestAt) {
+			oldest, oldestAt = key, entry.expiresAt
+		}
+	}
+	delete(c.entries, oldest)
+	aggregateCacheMetrics.Add("evictions", 1)
+}
+
+// Invalidate сбрасывает кэш в памяти и в общем хранилище. Вызывается после
+// любой записи, меняющей подписки или помесячную сводку.
+func (c *AggregateCache) Invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.entries = make(map[string]cachedAggregate)
+	c.gen++
+	c.mu.Unlock()
+
+	if c.shared != nil {
+		// Вызывается и из фоновых задач без контекста запроса; время ожидания
+		// ограничено таймаутами клиента хранилища.
+		if _, _, err := c.shared.Incr(context.Background(), aggregateGenerationKey, 0); err != nil {
+			aggregateCacheMetrics.Add("shared_errors", 1)
+		}
+	}
+	aggregateCacheMetrics.Add("invalidations", 1)
+}
+
+// aggregateCacheKey строит ключ из уже разобранных параметров, поэтому запросы,
+// отличающиеся только записью (регистр валюты, порядок service_name), совпадают.
+func aggregateCacheKey(kind string, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter, currency string, extra ...string) string {
+	parts := []string{kind, startDate.Format(model.DateFormat), endDate.Format(model.DateFormat), "", "", currency}
+
+	if userID != nil {
+		parts[3] = userID.String()
+	}
+
+	if serviceName != nil {
+		values := append([]string{}, serviceName.Values...)
+		sort.Strings(values)
+		parts[4] = fmt.Sprintf("%t:%s", serviceName.Exact, strings.Join(values, ","))
+	}
+
+	return strings.Join(append(parts, extra...), "|")
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"subscription_service/internal/store"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestAggregateCacheSweepsExpiredEntriesOnSet(t *testing.T) {
+	ctx := context.Background()
+	c := NewAggregateCache(time.Minute, nil)
+	c.set(ctx, "stale", 1, c.generation(ctx))
+	c.set(ctx, "fresh", 2, c.generation(ctx))
+
+	// Состариваем одну запись и разрешаем очистку при следующей вставке.
+	c.entries["stale"] = cachedAggregate{value: []byte("1"), expiresAt: time.Now().Add(-time.Second)}
+	c.nextSweep = time.Time{}
+
+	c.set(ctx, "new", 3, c.generation(ctx))
+
+	if _, ok := c.entries["stale"]; ok {
+		t.Error("expired entry survived set")
+	}
+	if len(c.entries) != 2 {
+		t.Errorf("cache has %d entries, want 2", len(c.entries))
+	}
+}
+
+func TestAggregateCacheIsBounded(t *testing.T) {
+	ctx := context.Background()
+	c := NewAggregateCache(time.Hour, nil)
+	for i := 0; i < maxAggregateCacheEntries+10; i++ {
+		c.set(ctx, strconv.Itoa(i), i, c.generation(ctx))
+	}
+
+	if len(c.entries) != maxAggregateCacheEntries {
+		t.Fatalf("cache has %d entries, want %d", len(c.entries), maxAggregateCacheEntries)
+	}
+	var value int
+	if !c.get(ctx, strconv.Itoa(maxAggregateCacheEntries+9), &value) {
+		t.Error("latest entry was evicted")
+	}
+
+	// Перезапись существующего ключа в полном кэше ничего не вытесняет.
+	c.set(ctx, strconv.Itoa(maxAggregateCacheEntries+9), 0, c.generation(ctx))
+	if len(c.entries) != maxAggregateCacheEntries {
+		t.Errorf("cache has %d entries after overwrite, want %d", len(c.entries), maxAggregateCacheEntries)
+	}
+}
+
+func TestAggregateCacheDropsResultComputedBeforeInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c := NewAggregateCache(time.Minute, nil)
+
+	gen := c.generation(ctx)
+	// Запись, сбросившая кэш, завершилась, пока агрегация считалась.
+	c.Invalidate()
+	c.set(ctx, "total", 1, gen)
+
+	var value int
+	if c.get(ctx, "total", &value) {
+		t.Error("result computed before Invalidate was cached")
+	}
+
+	c.set(ctx, "total", 2, c.generation(ctx))
+	if ok := c.get(ctx, "total", &value); !ok || value != 2 {
+		t.Errorf("get = %v, %t; want 2 cached after a fresh computation", value, ok)
+	}
+}
+
+func newSharedTestStore(t *testing.T) (*store.Redis, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	shared, err := store.NewRedis("redis://" + server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+	t.Cleanup(func() { shared.Close() })
+	return shared, server
+}
+
+func TestAggregateCacheIsSharedAcrossReplicas(t *testing.T) {
+	ctx := context.Background()
+	shared, _ := newSharedTestStore(t)
+	first, second := NewAggregateCache(time.Minute, shared), NewAggregateCache(time.Minute, shared)
+
+	first.set(ctx, "total", 100, first.generation(ctx))
+
+	var value int
+	if ok := second.get(ctx, "total", &value); !ok || value != 100 {
+		t.Fatalf("second replica get = %d, %t; want 100 cached by the first", value, ok)
+	}
+
+	// Расчёт на второй реплике начался до записи на первой.
+	gen := second.generation(ctx)
+	first.Invalidate()
+	second.set(ctx, "total", 100, gen)
+
+	if second.get(ctx, "total", &value) {
+		t.Error("entry survived Invalidate on another replica")
+	}
+	if first.get(ctx, "total", &value) {
+		t.Error("result computed before Invalidate on another replica was cached")
+	}
+}
+
+func TestAggregateCacheFallsBackToMemoryWhenSharedIsDown(t *testing.T) {
+	ctx := context.Background()
+	shared, server := newSharedTestStore(t)
+	c := NewAggregateCache(time.Minute, shared)
+	server.Close()
+
+	c.set(ctx, "total", 100, c.generation(ctx))
+
+	var value int
+	if ok := c.get(ctx, "total", &value); !ok || value != 100 {
+		t.Fatalf("get = %d, %t; want 100 from memory while Redis is down", value, ok)
+	}
+
+	c.Invalidate()
+	if c.get(ctx, "total", &value) {
+		t.Error("local entry survived Invalidate while Redis is down")
+	}
+}
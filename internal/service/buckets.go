@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"subscription_service/internal/model"
+)
+
+// granularityMonths - длина интервала временного ряда в месяцах.
+var granularityMonths = map[string]int{
+	model.GranularityMonth:   1,
+	model.GranularityQuarter: 3,
+	model.GranularityYear:    12,
+}
+
+// bucketStart - первый день интервала с шагом granularity, в который попадает t.
+func bucketStart(granularity string, t time.Time) time.Time {
+	month := t.Month()
+	switch granularity {
+	case model.GranularityQuarter:
+		month = (month-1)/3*3 + 1
+	case model.GranularityYear:
+		month = time.January
+	}
+	return time.Date(t.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// bucketLabel - подпись интервала: 2025-01, 2025-Q1 или 2025.
+func bucketLabel(granularity string, start time.Time) string {
+	switch granularity {
+	case model.GranularityQuarter:
+		return fmt.Sprintf("%d-Q%d", start.Year(), (int(start.Month())-1)/3+1)
+	case model.GranularityYear:
+		return start.Format("2006")
+	default:
+		return start.Format("2006-01")
+	}
+}
+
+// timeBuckets строит пустые интервалы, покрывающие [startDate, endDate], и
+// индекс интервала по дате его начала.
+func timeBuckets(granularity string, startDate, endDate time.Time) ([]model.BucketTotal, map[time.Time]int) {
+	buckets := []model.BucketTotal{}
+	index := make(map[time.Time]int)
+
+	for start := bucketStart(granularity, startDate); !start.After(endDate); start = start.AddDate(0, granularityMonths[granularity], 0) {
+		index[start] = len(buckets)
+		buckets = append(buckets, model.BucketTotal{
+			Period:    bucketLabel(granularity, start),
+			StartDate: start.Format(model.DateFormat),
+		})
+	}
+
+	return buckets, index
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+type rateKey struct {
+	currency string
+	date     string
+}
+
+type cachedRate struct {
+	rate      float64
+	expiresAt time.Time
+}
+
+// CurrencyConverter пересчитывает суммы между валютами через базовую валюту,
+// кэшируя курсы из таблицы exchange_rates.
+type CurrencyConverter struct {
+	repo         repository.ExchangeRateRepository
+	baseCurrency string
+	cacheTTL     time.Duration
+	// minorUnits - суммы в минимальных единицах: при пересчёте учитывается
+	// разница масштабов валют (JPY без дробной части, KWD - три знака).
+	minorUnits bool
+
+	mu    sync.RWMutex
+	cache map[rateKey]cachedRate
+}
+
+func NewCurrencyConverter(repo repository.ExchangeRateRepository, baseCurrency string, cacheTTL time.Duration, minorUnits bool) *CurrencyConverter {
+	return &CurrencyConverter{
+		repo:         repo,
+		baseCurrency: strings.ToUpper(baseCurrency),
+		cacheTTL:     cacheTTL,
+		minorUnits:   minorUnits,
+		cache:        make(map[rateKey]cachedRate),
+	}
+}
+
+func (c *CurrencyConverter) BaseCurrency() string {
+	return c.baseCurrency
+}
+
+// Convert переводит amount из валюты from в валюту to по курсам на дату date.
+// Пустая валюта (NULL в БД) считается базовой.
+func (c *CurrencyConverter) Convert(amount int, from, to string, date time.Time) (int, error) {
+	from = strings.ToUpper(from)
+	to = strings.ToUpper(to)
+	if from == "" {
+		from = c.baseCurrency
+	}
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, err := c.rate(from, date)
+	if err != nil {
+		return 0, err
+	}
+
+	toRate, err := c.rate(to, date)
+	if err != nil {
+		return 0, err
+	}
+
+	converted := float64(amount) * fromRate / toRate
+	if c.minorUnits {
+		converted *= math.Pow10(model.CurrencyScale(to) - model.CurrencyScale(from))
+	}
+	return int(math.Round(converted)), nil
+}
+
+func (c *CurrencyConverter) rate(currency string, date time.Time) (float64, error) {
+	if currency == c.baseCurrency {
+		return 1, nil
+	}
+
+	key := rateKey{currency: currency, date: date.Format("2006-01-02")}
+
+	c.mu.RLock()
+	cached, ok := c.cache[key]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.rate, nil
+	}
+
+	rate, err := c.repo.GetRate(currency, date)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get exchange rate: %w", err)
+	}
+
+	if rate == nil {
+		logrus.WithFields(logrus.Fields{
+			"currency": currency,
+			"date":     key.date,
+		}).Warn("Exchange rate not found")
+		return 0, &ValidationError{
+			Field: "currency",
+			Err:   fmt.Errorf("no exchange rate for %s on or before %s", currency, key.date),
+		}
+	}
+
+	c.mu.Lock()
+	c.cache[key] = cachedRate{rate: rate.RateToBase, expiresAt: time.Now().Add(c.cacheTTL)}
+	c.mu.Unlock()
+
+	return rate.RateToBase, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+type MaintenanceService interface {
+	Run(reindex bool) (*model.MaintenanceResult, error)
+}
+
+type maintenanceOperation struct {
+	name string
+	run  func() error
+}
+
+type maintenanceService struct {
+	repo repository.MaintenanceRepository
+}
+
+func NewMaintenanceService(repo repository.MaintenanceRepository) MaintenanceService {
+	return &maintenanceService{repo: repo}
+}
+
+func (s *maintenanceService) Run(reindex bool) (*model.MaintenanceResult, error) {
+	operations := []maintenanceOperation{
+		{name: "analyze", run: s.repo.Analyze},
+	}
+	if reindex {
+		operations = append(operations, maintenanceOperation{name: "reindex", run: s.repo.Reindex})
+	}
+
+	result := &model.MaintenanceResult{}
+	started := time.Now()
+
+	for _, op := range operations {
+		opStarted := time.Now()
+		if err := op.run(); err != nil {
+			return nil, fmt.Errorf("maintenance operation %s failed: %w", op.name, err)
+		}
+
+		duration := time.Since(opStarted)
+		result.Operations = append(result.Operations, model.MaintenanceOperation{
+			Name:       op.name,
+			DurationMs: duration.Milliseconds(),
+		})
+		logrus.WithFields(logrus.Fields{
+			"operation":   op.name,
+			"duration_ms": duration.Milliseconds(),
+		}).Info("Maintenance operation completed")
+	}
+
+	result.TotalDurationMs = time.Since(started).Milliseconds()
+	return result, nil
+}
@@ -0,0 +1,28 @@
+package service
+
+// Ключи сообщений ValidationError. Хендлер по ключу и ValidationError.Args
+// подставляет перевод сообщения на язык клиента; английский текст ошибки
+// (Error()) остаётся исходным и используется, если перевода нет.
+const (
+	MsgInvalidUUID         = "invalid_uuid"
+	MsgInvalidDate         = "invalid_date"
+	MsgInvalidTimestamp    = "invalid_timestamp"
+	MsgNegativePrice       = "negative_price"
+	MsgEmptyTag            = "empty_tag"
+	MsgTagTooLong          = "tag_too_long"
+	MsgTooManyTags         = "too_many_tags"
+	MsgDescriptionTooLong  = "description_too_long"
+	MsgStartAfterEnd       = "start_after_end"
+	MsgEmptyStartDate      = "empty_start_date"
+	MsgEndDateRequired     = "end_date_required"
+	MsgStartTooFarInFuture = "start_too_far_in_future"
+	MsgStartTooFarInPast   = "start_too_far_in_past"
+	MsgTooManyIDs          = "too_many_ids"
+	MsgMetadataNotFlat     = "metadata_not_flat"
+	MsgMetadataTooLarge    = "metadata_too_large"
+	MsgEmptyMetadataKey    = "empty_metadata_key"
+	MsgInvalidAmount       = "invalid_amount"
+	MsgAmountUnsupported   = "amount_unsupported"
+	MsgPriceAndAmount      = "price_and_amount"
+	MsgSameOwner           = "same_owner"
+)
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type RecomputeService interface {
+	Run(ctx context.Context, afterID *uuid.UUID, batchSize int) (*model.RecomputeResult, error)
+}
+
+type recomputeService struct {
+	repo         repository.RecomputeRepository
+	baseCurrency string
+	// cache сбрасывается после батчей, изменивших строки: производные колонки
+	// участвуют в агрегациях.
+	cache *AggregateCache
+}
+
+func NewRecomputeService(repo repository.RecomputeRepository, baseCurrency string, cache *AggregateCache) RecomputeService {
+	return &recomputeService{repo: repo, baseCurrency: baseCurrency, cache: cache}
+}
+
+// Run проходит таблицу батчами по возрастанию id, начиная после afterID.
+// Если контекст отменён между батчами, возвращается частичный результат с
+// Completed=false и LastID, с которого можно продолжить.
+func (s *recomputeService) Run(ctx context.Context, afterID *uuid.UUID, batchSize int) (*model.RecomputeResult, error) {
+	cursor := uuid.Nil
+	if afterID != nil {
+		cursor = *afterID
+	}
+
+	result := &model.RecomputeResult{}
+	started := time.Now()
+
+	for {
+		if ctx.Err() != nil {
+			logrus.WithField("last_id", cursor).Warn("Recompute interrupted, can be resumed from last_id")
+			break
+		}
+
+		lastID, scanned, updated, err := s.repo.RecomputeBatch(cursor, batchSize, s.baseCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recompute after %s: %w", cursor, err)
+		}
+		if updated > 0 {
+			s.cache.Invalidate()
+		}
+
+		if scanned == 0 {
+			result.Completed = true
+			break
+		}
+
+		cursor = lastID
+		result.Batches++
+		result.RowsScanned += scanned
+		result.RowsUpdated += updated
+
+		logrus.WithFields(logrus.Fields{
+			"batch":        result.Batches,
+			"rows_scanned": result.RowsScanned,
+			"rows_updated": result.RowsUpdated,
+			"last_id":      cursor,
+		}).Info("Recompute batch completed")
+
+		if scanned < batchSize {
+			result.Completed = true
+			break
+		}
+	}
+
+	if cursor != uuid.Nil {
+		result.LastID = &cursor
+	}
+	result.DurationMs = time.Since(started).Milliseconds()
+
+	return result, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"subscription_service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrRetriesExhausted возвращается, если операция так и не прошла из-за
+// конкурентного доступа к БД после всех повторов.
+var ErrRetriesExhausted = errors.New("operation failed due to concurrent access, retries exhausted")
+
+// RetryPolicy - повторы многошаговых операций при конфликте сериализации и
+// взаимоблокировке. Attempts - число повторов после первой попытки (0 - без
+// повторов); пауза перед повтором начинается с Backoff и удваивается.
+type RetryPolicy struct {
+	Attempts int
+	Backoff  time.Duration
+}
+
+// withRetry выполняет fn и повторяет её по s.opts.Retry, пока ошибка
+// retryable. fn должна целиком перечитывать данные: каждая попытка начинается
+// с чистого состояния, так как БД уже откатила предыдущую. Если БД так и
+// осталась только для чтения, возвращается ошибка с ErrReadOnly. Пауза
+// прерывается отменой ctx.
+func (s *subscriptionService) withRetry(ctx context.Context, op string, fn func() error) error {
+	backoff := s.opts.Retry.Backoff
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !repository.IsRetryable(err) {
+			return err
+		}
+
+		if attempt > s.opts.Retry.Attempts {
+			if errors.Is(err, ErrReadOnly) {
+				return err
+			}
+			return fmt.Errorf("%w: %s: %v", ErrRetriesExhausted, op, err)
+		}
+
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"operation": op,
+			"attempt":   attempt,
+		}).Warn("Database conflict, retrying operation")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
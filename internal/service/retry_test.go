@@ -0,0 +1,114 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"subscription_service/internal/mocks"
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+	"subscription_service/internal/service"
+
+	"github.com/lib/pq"
+)
+
+func TestCreateRetriesOnConcurrencyErrors(t *testing.T) {
+	errOther := errors.New("connection reset")
+
+	tests := []struct {
+		name      string
+		attempts  int
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"serialization failure is retried", 2, []error{&pq.Error{Code: "40001"}}, 2, nil},
+		{"deadlock is retried", 2, []error{&pq.Error{Code: "40P01"}}, 2, nil},
+		{"mixed conflicts within limit", 2, []error{&pq.Error{Code: "40P01"}, &pq.Error{Code: "40001"}}, 3, nil},
+		{"attempts exhausted", 2, []error{&pq.Error{Code: "40001"}, &pq.Error{Code: "40001"}, &pq.Error{Code: "40001"}}, 3, service.ErrRetriesExhausted},
+		{"no retries configured", 0, []error{&pq.Error{Code: "40001"}}, 1, service.ErrRetriesExhausted},
+		{"other database error is not retried", 2, []error{&pq.Error{Code: "23502"}}, 1, nil},
+		{"non-database error is not retried", 2, []error{errOther}, 1, errOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			repo := &mocks.SubscriptionRepository{
+				CreateFn: func(context.Context, *model.Subscription, repository.UserLimit) error {
+					calls++
+					if calls <= len(tt.errs) {
+						return tt.errs[calls-1]
+					}
+					return nil
+				},
+			}
+			svc := newTestService(repo, service.Options{
+				Retry: service.RetryPolicy{Attempts: tt.attempts, Backoff: time.Millisecond},
+			})
+
+			_, err := svc.Create(context.Background(), &model.CreateSubscriptionRequest{
+				ServiceName: "Yandex Plus",
+				Price:       400,
+				UserID:      testUserID,
+				StartDate:   &model.Date{Time: date("2024-06-01")},
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("repository called %d times, want %d", calls, tt.wantCalls)
+			}
+
+			switch {
+			case tt.wantCalls > len(tt.errs):
+				if err != nil {
+					t.Errorf("Create error = %v, want success after retry", err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Create error = %v, want %v", err, tt.wantErr)
+				}
+			default:
+				if err == nil || errors.Is(err, service.ErrRetriesExhausted) {
+					t.Errorf("Create error = %v, want the original error", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateIfNotExistsRetriesSerializationFailure(t *testing.T) {
+	calls, checks := 0, 0
+	repo := &mocks.SubscriptionRepository{
+		CreateIfNotExistsFn: func(_ context.Context, sub *model.Subscription, _ repository.UserLimit, check func() error) (*model.Subscription, bool, error) {
+			calls++
+			if err := check(); err != nil {
+				return nil, false, err
+			}
+			checks++
+			if calls == 1 {
+				// Параллельная вставка того же пользователя закоммичена раньше.
+				return nil, false, fmt.Errorf("failed to commit subscription: %w", &pq.Error{Code: "40001"})
+			}
+			return sub, true, nil
+		},
+	}
+	svc := newTestService(repo, service.Options{
+		Retry: service.RetryPolicy{Attempts: 2, Backoff: time.Millisecond},
+	})
+
+	_, created, err := svc.CreateIfNotExists(context.Background(), &model.CreateSubscriptionRequest{
+		ServiceName: "Yandex Plus",
+		Price:       400,
+		UserID:      testUserID,
+		StartDate:   &model.Date{Time: date("2024-06-01")},
+	})
+	if err != nil {
+		t.Fatalf("CreateIfNotExists: %v", err)
+	}
+	if !created || calls != 2 || checks != 2 {
+		t.Errorf("created = %t, calls = %d, checks = %d; want a second attempt with checks rerun", created, calls, checks)
+	}
+}
@@ -1,10 +1,17 @@
 package service
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"subscription_service/internal/model"
 	"subscription_service/internal/repository"
@@ -15,11 +22,41 @@ import (
 
 var (
 	ErrNoUpdates = errors.New("no fields to update")
+	// ErrVersionConflict - подписка изменилась после чтения клиентом (If-Match не совпал).
+	ErrVersionConflict = errors.New("subscription has been modified, version does not match")
+	// ErrExportTruncated - выгрузка остановлена на лимите MaxExportRows; подходящих
+	// строк больше, и клиенту стоит сузить фильтры.
+	ErrExportTruncated = errors.New("export truncated at row limit")
+	// ErrNotModified - обновление не меняет ни одного поля (при SkipNoopUpdates);
+	// Update возвращает вместе с ней текущую подписку.
+	ErrNotModified = errors.New("subscription not modified")
+	// ErrDuplicateID - подписка с переданным клиентом id уже существует.
+	ErrDuplicateID = errors.New("subscription with this id already exists")
+	// ErrReadOnly - БД временно только для чтения (переключение primary), запись
+	// стоит повторить позже. Оборачивает исходную ошибку репозитория.
+	ErrReadOnly = errors.New("database is temporarily read-only")
+)
+
+const (
+	maxTags              = 10
+	maxTagLength         = 50
+	maxDescriptionLength = 500
+	maxMetadataBytes     = 2048
+
+	maxExpiringMonths = 24
+	defaultTopLimit   = 5
+	maxShiftMonths    = 120
+
+	defaultChangesLimit = 100
 )
 
 type ValidationError struct {
 	Field string
 	Err   error
+	// Key и Args - ключ сообщения (Msg*) и его параметры для перевода в хендлере;
+	// пустой Key - сообщение не переводится.
+	Key  string
+	Args []interface{}
 }
 
 func (e *ValidationError) Error() string {
@@ -41,28 +78,430 @@ func (e *NotFoundError) Error() string {
 	return fmt.Sprintf("subscription with id '%s' not found", e.ID)
 }
 
+// DuplicateSubscriptionError означает, что создание нарушило бы политику
+// уникальности. ExistingID пуст, если конфликт поймал индекс БД при гонке.
+type DuplicateSubscriptionError struct {
+	Policy     string
+	ExistingID string
+}
+
+func (e *DuplicateSubscriptionError) Error() string {
+	if e.ExistingID == "" {
+		return fmt.Sprintf("subscription violates uniqueness policy '%s'", e.Policy)
+	}
+	return fmt.Sprintf("subscription violates uniqueness policy '%s': conflicts with subscription '%s'", e.Policy, e.ExistingID)
+}
+
+// LimitExceededError означает, что у пользователя уже максимум активных подписок.
+type LimitExceededError struct {
+	UserID string
+	Limit  int
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("user '%s' already has the maximum of %d active subscriptions", e.UserID, e.Limit)
+}
+
 type SubscriptionService interface {
-	Create(req *model.CreateSubscriptionRequest) (*model.Subscription, error)
-	GetByID(id string) (*model.Subscription, error)
-	Update(id string, req *model.UpdateSubscriptionRequest) error
-	Delete(id string) error
-	List(userID, serviceName *string, startDate, endDate *string, limit, offset int) ([]*model.Subscription, error)
-	Aggregate(req *model.AggregateRequest) (*model.AggregateResponse, error)
+	Create(ctx context.Context, req *model.CreateSubscriptionRequest) (*model.Subscription, error)
+	CreateIfNotExists(ctx context.Context, req *model.CreateSubscriptionRequest) (*model.Subscription, bool, error)
+	GetByID(ctx context.Context, id string) (*model.Subscription, error)
+	// Update и Delete при expectedVersion != nil выполняются, только если
+	// подписка не менялась с этой версии, иначе возвращают ErrVersionConflict.
+	Update(ctx context.Context, id string, req *model.UpdateSubscriptionRequest, expectedVersion *int) (*model.Subscription, error)
+	Shift(ctx context.Context, id string, months int) (*model.Subscription, error)
+	// Schema описывает фильтры, сортировки и перечислимые значения списка
+	// по тем же спискам, которыми проверяются запросы.
+	Schema() *model.SubscriptionSchema
+	// Transfer атомарно передаёт подписку пользователю toUserID с записью в
+	// журнал передач; лимит активных подписок проверяется у получателя.
+	Transfer(ctx context.Context, id, toUserID string) (*model.Subscription, error)
+	// Delete возвращает удалённую подписку.
+	Delete(ctx context.Context, id string, expectedVersion *int) (*model.Subscription, error)
+	List(ctx context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionPage, error)
+	// ListRefs возвращает по тем же фильтрам только id и updated_at.
+	ListRefs(ctx context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionRefPage, error)
+	// Query - список по структурированному запросу; проверяет fields и sort,
+	// выбор полей ответа остаётся хендлеру.
+	Query(ctx context.Context, req *model.SubscriptionQuery) (*model.SubscriptionPage, error)
+	Export(ctx context.Context, req *model.ListSubscriptionsRequest, fn func(*model.Subscription) error) error
+	// Top возвращает подписки с наибольшей ценой или длительностью.
+	Top(ctx context.Context, req *model.TopSubscriptionsRequest) ([]*model.Subscription, error)
+	// Changes возвращает страницу подписок, изменённых после курсора.
+	Changes(ctx context.Context, req *model.ChangesRequest) (*model.ChangesPage, error)
+	// ListExpiring возвращает страницу подписок, заканчивающихся в окне months
+	// (limit 0 - все).
+	ListExpiring(ctx context.Context, months, limit, offset int) (*model.ExpiringSubscriptions, error)
+	Aggregate(ctx context.Context, req *model.AggregateRequest) (*model.AggregateResponse, error)
+	AggregateMonthly(ctx context.Context, req *model.AggregateRequest) (*model.AggregateMonthlyResponse, error)
+	// AggregateMatrix разбивает итог за период по парам (service_name, user_id).
+	AggregateMatrix(ctx context.Context, req *model.AggregateRequest) (*model.AggregateServiceUserResponse, error)
+	CompareAggregate(ctx context.Context, req *model.CompareAggregateRequest) (*model.CompareAggregateResponse, error)
+	AggregateBatch(ctx context.Context, req *model.BatchAggregateRequest) (*model.BatchAggregateResponse, error)
+	Timeline(ctx context.Context, req *model.TimelineRequest) ([]model.TimelinePoint, error)
+	SimulatePriceChange(ctx context.Context, req *model.SimulatePriceChangeRequest) (*model.SimulatePriceChangeResponse, error)
+	// UserMonthlyCost - суммарная месячная стоимость подписок пользователя,
+	// активных сегодня, в базовой валюте.
+	UserMonthlyCost(ctx context.Context, userID string) (*model.AggregateResponse, error)
+	// DeletionPreview показывает, какие подписки и какая выручка затронет
+	// удаление данных пользователя. Данные не меняются.
+	DeletionPreview(ctx context.Context, userID string) (*model.UserDeletionPreview, error)
+}
+
+type Options struct {
+	MaxBatchUsers int
+	// MaxAggregateYears ограничивает длину периода агрегации (0 - без ограничения).
+	MaxAggregateYears int
+	// MonthPrecision приводит даты подписок и фильтров к первому числу месяца.
+	MonthPrecision bool
+	// DefaultSort - сортировка списка по умолчанию, например "-start_date".
+	DefaultSort string
+	// Location - часовой пояс для вычисления пресетов period (today, week, month).
+	Location *time.Location
+	// MaxFutureStartMonths - насколько далеко в будущем может быть start_date (0 - без проверки).
+	MaxFutureStartMonths int
+	// MaxPastStartYears - насколько давно может быть start_date (0 - без проверки).
+	MaxPastStartYears int
+	// MaxActivePerUser - лимит активных подписок одного пользователя (0 - без лимита).
+	MaxActivePerUser int
+	// Warnings - правила мягкой валидации при создании.
+	Warnings WarningRules
+	// MaxExportRows - максимум строк в одной выгрузке (0 - без ограничения).
+	MaxExportRows int
+	// MaxListIDs - максимум id в фильтре ids списка (0 - без ограничения).
+	MaxListIDs int
+	// AggregateCache - кэш результатов Aggregate и AggregateMonthly (nil - выключен).
+	// Тот же кэш сбрасывают фоновые задачи и административные пересчёты.
+	AggregateCache *AggregateCache
+	// UniquePolicy - политика уникальности при создании (model.UniquePolicy*); пусто - none.
+	UniquePolicy string
+	// EndDateRequiredServices - сервисы (без учёта регистра), подписки на которые
+	// создаются только с end_date (например, срочные контракты).
+	EndDateRequiredServices []string
+	// SkipNoopUpdates - не писать Update, совпадающий с текущими значениями,
+	// чтобы не сдвигать updated_at и version.
+	SkipNoopUpdates bool
+	// MaxAggregateGroups ограничивает число строк разбивки по (service_name, user_id);
+	// при превышении запрос отклоняется с просьбой сузить фильтры (0 - без ограничения).
+	MaxAggregateGroups int
+	// Retry - повторы Create, CreateIfNotExists, Shift и Transfer при конфликтах
+	// сериализации и взаимоблокировках в БД.
+	Retry RetryPolicy
+	// MinorUnits - price хранится в минимальных единицах валюты (копейки, центы;
+	// для JPY - иены). Включает поле amount в запросах и ответах.
+	MinorUnits bool
+	// Summaries - помесячная сводка для Aggregate; nil - итог всегда считается
+	// по подпискам. Сводка точна только для дат с точностью до месяца.
+	Summaries repository.SummaryRepository
+	// SortFields - разрешённые поля сортировки списка; пустой - все model.SortFields.
+	SortFields []string
 }
 
 type subscriptionService struct {
-	repo repository.SubscriptionRepository
+	repo      repository.SubscriptionRepository
+	converter *CurrencyConverter
+	opts      Options
+	cache     *AggregateCache
+}
+
+func NewSubscriptionService(repo repository.SubscriptionRepository, converter *CurrencyConverter, opts Options) SubscriptionService {
+	return &subscriptionService{
+		repo:      repo,
+		converter: converter,
+		opts:      opts,
+		cache:     opts.AggregateCache,
+	}
+}
+
+func (s *subscriptionService) Create(ctx context.Context, req *model.CreateSubscriptionRequest) (*model.Subscription, error) {
+	sub, err := s.newSubscription(req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.withRetry(ctx, "create", func() error {
+		if err := s.checkNew(ctx, sub, req); err != nil {
+			return err
+		}
+
+		if err := s.repo.Create(ctx, sub, s.userLimit(req.OverrideLimit)); err != nil {
+			return s.createError(err, sub)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.Invalidate()
+	s.setComputedFields(sub)
+	return sub, nil
+}
+
+// CreateIfNotExists возвращает существующую подписку с тем же пользователем,
+// сервисом и датой начала, а при её отсутствии создаёт новую.
+func (s *subscriptionService) CreateIfNotExists(ctx context.Context, req *model.CreateSubscriptionRequest) (*model.Subscription, bool, error) {
+	sub, err := s.newSubscription(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var result *model.Subscription
+	var created bool
+	err = s.withRetry(ctx, "create_if_not_exists", func() error {
+		// Проверки нужны, только если подписки ещё нет; репозиторий вызывает их
+		// под той же блокировкой, что и поиск существующей.
+		var checkErr error
+		result, created, err = s.repo.CreateIfNotExists(ctx, sub, s.userLimit(req.OverrideLimit), func() error {
+			checkErr = s.checkNew(ctx, sub, req)
+			return checkErr
+		})
+		if checkErr != nil {
+			return checkErr
+		}
+		if err != nil {
+			return s.createError(err, sub)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !created {
+		logrus.WithField("id", result.ID).Info("Subscription already exists, skipping create")
+		s.setComputedFields(result)
+		return result, false, nil
+	}
+
+	s.cache.Invalidate()
+	s.setComputedFields(sub)
+	return sub, true, nil
+}
+
+// Shift сдвигает start_date и, если задана, end_date на months месяцев одним
+// обновлением. День месяца ограничивается последним днём целевого месяца.
+func (s *subscriptionService) Shift(ctx context.Context, id string, months int) (*model.Subscription, error) {
+	if months == 0 || months > maxShiftMonths || months < -maxShiftMonths {
+		return nil, &ValidationError{
+			Field: "months",
+			Err:   fmt.Errorf("months must be non-zero and between -%d and %d", maxShiftMonths, maxShiftMonths),
+		}
+	}
+
+	var updated *model.Subscription
+	err := s.withRetry(ctx, "shift", func() error {
+		sub, err := s.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		startDate := s.normalizeDate(model.AddMonths(sub.StartDate, months))
+		updates := map[string]interface{}{"start_date": startDate}
+
+		if sub.EndDate != nil {
+			endDate := s.normalizeDate(model.AddMonths(*sub.EndDate, months))
+			if endDate.Before(startDate) {
+				return &ValidationError{
+					Field: "months",
+					Err:   errors.New("shifted end_date would be before start_date"),
+				}
+			}
+			updates["end_date"] = endDate
+		}
+
+		updated, err = s.repo.Update(ctx, sub.ID, updates, nil, false, repository.UserLimit{})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &NotFoundError{ID: id}
+			}
+			if errors.Is(err, repository.ErrReadOnly) {
+				return fmt.Errorf("%w: %w", ErrReadOnly, err)
+			}
+			return fmt.Errorf("failed to shift subscription: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.Invalidate()
+	s.setComputedFields(updated)
+	return updated, nil
+}
+
+func (s *subscriptionService) Transfer(ctx context.Context, id, toUserID string) (*model.Subscription, error) {
+	uuidID, err := uuid.Parse(id)
+	if err != nil {
+		logrus.WithError(err).WithField("id", id).Error("Invalid UUID format")
+		return nil, &ValidationError{
+			Field: "id",
+			Err:   fmt.Errorf("invalid UUID format: %w", err),
+			Key:   MsgInvalidUUID,
+		}
+	}
+
+	toUUID, err := uuid.Parse(toUserID)
+	if err != nil {
+		return nil, &ValidationError{
+			Field: "to_user_id",
+			Err:   fmt.Errorf("invalid UUID format: %w", err),
+			Key:   MsgInvalidUUID,
+		}
+	}
+
+	// Проверка выполняется под блокировкой строки, поэтому владелец не может
+	// смениться между проверкой и передачей. Лимит получателя репозиторий
+	// проверяет в той же транзакции.
+	check := func(current *model.Subscription) error {
+		if current.UserID == toUUID {
+			return &ValidationError{
+				Field: "to_user_id",
+				Err:   errors.New("subscription already belongs to this user"),
+				Key:   MsgSameOwner,
+			}
+		}
+		return nil
+	}
+
+	var sub *model.Subscription
+	err = s.withRetry(ctx, "transfer", func() error {
+		sub, err = s.repo.Transfer(ctx, uuidID, toUUID, s.userLimit(false), check)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &NotFoundError{ID: id}
+			}
+			if errors.Is(err, repository.ErrUserLimitExceeded) {
+				return s.limitExceeded(toUUID)
+			}
+			if errors.Is(err, repository.ErrReadOnly) {
+				return fmt.Errorf("%w: %w", ErrReadOnly, err)
+			}
+			// Ошибки check (ValidationError) возвращаются как есть.
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.Invalidate()
+	s.setComputedFields(sub)
+	return sub, nil
+}
+
+func (s *subscriptionService) sortFields() []string {
+	if len(s.opts.SortFields) == 0 {
+		return model.SortFields
+	}
+	return s.opts.SortFields
+}
+
+func (s *subscriptionService) Schema() *model.SubscriptionSchema {
+	return &model.SubscriptionSchema{
+		Filters:    model.ListFilters,
+		SortFields: s.sortFields(),
+		Fields:     model.QueryFields,
+		Facets:     []string{model.FacetServiceName, model.FacetStatus},
+		Enums: map[string][]string{
+			"status":        {model.StatusActive, model.StatusUpcoming, model.StatusExpired},
+			"billing_cycle": model.BillingCycles,
+			"period":        model.ListPeriods,
+		},
+		Currency:     "ISO 4217",
+		BaseCurrency: s.converter.BaseCurrency(),
+	}
+}
+
+// checkNew выполняет проверки новой подписки перед вставкой: мягкую
+// валидацию и политику уникальности. Лимит пользователя проверяет репозиторий
+// в транзакции вставки (см. userLimit).
+func (s *subscriptionService) checkNew(ctx context.Context, sub *model.Subscription, req *model.CreateSubscriptionRequest) error {
+	if err := s.checkWarnings(sub, req.AllowWarnings); err != nil {
+		return err
+	}
+
+	return s.checkUnique(ctx, sub)
+}
+
+// userLimit возвращает лимит MaxActivePerUser для записи, которая добавляет
+// пользователю подписку. Репозиторий проверяет его в той же транзакции, что и
+// запись; override (создание администратором) снимает проверку.
+func (s *subscriptionService) userLimit(override bool) repository.UserLimit {
+	if s.opts.MaxActivePerUser <= 0 || override {
+		return repository.UserLimit{}
+	}
+	return repository.UserLimit{Max: s.opts.MaxActivePerUser, On: s.today()}
+}
+
+// limitExceeded - ошибка сервиса для repository.ErrUserLimitExceeded.
+func (s *subscriptionService) limitExceeded(userID uuid.UUID) error {
+	return &LimitExceededError{UserID: userID.String(), Limit: s.opts.MaxActivePerUser}
 }
 
-func NewSubscriptionService(repo repository.SubscriptionRepository) SubscriptionService {
-	return &subscriptionService{repo: repo}
+// checkUnique отклоняет создание подписки, нарушающей политику UniquePolicy.
+// Конкурентные вставки надёжно отсекает только необязательный индекс из
+// migrations/optional; эта проверка даёт понятную ошибку с id существующей подписки.
+func (s *subscriptionService) checkUnique(ctx context.Context, sub *model.Subscription) error {
+	if s.opts.UniquePolicy == "" || s.opts.UniquePolicy == model.UniquePolicyNone {
+		return nil
+	}
+
+	existing, err := s.repo.FindDuplicate(ctx, sub, s.opts.UniquePolicy)
+	if err != nil {
+		return fmt.Errorf("failed to check subscription uniqueness: %w", err)
+	}
+
+	if existing != nil {
+		return &DuplicateSubscriptionError{Policy: s.opts.UniquePolicy, ExistingID: existing.ID.String()}
+	}
+
+	return nil
 }
 
-func (s *subscriptionService) Create(req *model.CreateSubscriptionRequest) (*model.Subscription, error) {
+// createError сопоставляет ошибку вставки sub в репозитории с ошибкой сервиса.
+func (s *subscriptionService) createError(err error, sub *model.Subscription) error {
+	switch {
+	case errors.Is(err, repository.ErrUserLimitExceeded):
+		return s.limitExceeded(sub.UserID)
+	case errors.Is(err, repository.ErrDuplicateID):
+		return ErrDuplicateID
+	case errors.Is(err, repository.ErrDuplicateSubscription):
+		return &DuplicateSubscriptionError{Policy: s.opts.UniquePolicy}
+	case errors.Is(err, repository.ErrReadOnly):
+		return fmt.Errorf("%w: %w", ErrReadOnly, err)
+	default:
+		return fmt.Errorf("failed to create subscription: %w", err)
+	}
+}
+
+// newSubscription проверяет запрос на создание и собирает из него подписку.
+func (s *subscriptionService) newSubscription(req *model.CreateSubscriptionRequest) (*model.Subscription, error) {
 	if req.Price < 0 {
 		return nil, &ValidationError{
 			Field: "price",
 			Err:   errors.New("price cannot be negative"),
+			Key:   MsgNegativePrice,
+		}
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	req.Tags = tags
+
+	description, err := sanitizeDescription(req.Description)
+	if err != nil {
+		return nil, err
+	}
+	req.Description = description
+
+	if req.Metadata != nil {
+		if _, err := encodeMetadata(req.Metadata); err != nil {
+			return nil, err
 		}
 	}
 
@@ -75,24 +514,95 @@ func (s *subscriptionService) Create(req *model.CreateSubscriptionRequest) (*mod
 		}
 	}
 
-	if err := s.repo.Create(sub); err != nil {
-		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	sub.StartDate = s.normalizeDate(sub.StartDate)
+	if err := s.validateStartDate(sub.StartDate); err != nil {
+		return nil, err
+	}
+	if sub.EndDate != nil {
+		endDate := s.normalizeDate(*sub.EndDate)
+		sub.EndDate = &endDate
+	} else if s.requiresEndDate(sub.ServiceName) {
+		return nil, &ValidationError{
+			Field: "end_date",
+			Err:   fmt.Errorf("end_date is required for service %q", sub.ServiceName),
+			Key:   MsgEndDateRequired,
+			Args:  []interface{}{sub.ServiceName},
+		}
+	}
+
+	if sub.Currency == "" {
+		sub.Currency = s.converter.BaseCurrency()
+	}
+	sub.Currency = strings.ToUpper(sub.Currency)
+
+	if req.Amount != "" {
+		if req.Price != 0 {
+			return nil, errPriceAndAmount()
+		}
+		price, err := s.priceFromAmount(req.Amount, sub.Currency)
+		if err != nil {
+			return nil, err
+		}
+		sub.Price = model.Money(price)
 	}
 
 	return sub, nil
 }
 
-func (s *subscriptionService) GetByID(id string) (*model.Subscription, error) {
+// priceFromAmount переводит amount в основных единицах в минимальные единицы
+// currency, проверяя, что знаков после точки не больше, чем у валюты.
+func (s *subscriptionService) priceFromAmount(amount, currency string) (int, error) {
+	if !s.opts.MinorUnits {
+		return 0, &ValidationError{
+			Field: "amount",
+			Err:   errors.New("amount is supported only when prices are stored in minor units"),
+			Key:   MsgAmountUnsupported,
+		}
+	}
+
+	scale := model.CurrencyScale(currency)
+	price, err := model.ParseMinorUnits(amount, scale)
+	if err != nil {
+		return 0, &ValidationError{
+			Field: "amount",
+			Err:   err,
+			Key:   MsgInvalidAmount,
+			Args:  []interface{}{scale, currency},
+		}
+	}
+	return price, nil
+}
+
+func errPriceAndAmount() error {
+	return &ValidationError{
+		Field: "amount",
+		Err:   errors.New("price and amount are mutually exclusive"),
+		Key:   MsgPriceAndAmount,
+	}
+}
+
+// requiresEndDate сообщает, входит ли сервис в EndDateRequiredServices.
+func (s *subscriptionService) requiresEndDate(serviceName string) bool {
+	for _, name := range s.opts.EndDateRequiredServices {
+		if strings.EqualFold(strings.TrimSpace(serviceName), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *subscriptionService) GetByID(ctx context.Context, id string) (*model.Subscription, error) {
 	uuidID, err := uuid.Parse(id)
 	if err != nil {
 		logrus.WithError(err).WithField("id", id).Error("Invalid UUID format")
 		return nil, &ValidationError{
 			Field: "id",
 			Err:   fmt.Errorf("invalid UUID format: %w", err),
+			Key:   MsgInvalidUUID,
 		}
 	}
 
-	sub, err := s.repo.GetByID(uuidID)
+	sub, err := s.repo.GetByID(ctx, uuidID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get subscription: %w", err)
 	}
@@ -101,16 +611,18 @@ func (s *subscriptionService) GetByID(id string) (*model.Subscription, error) {
 		return nil, &NotFoundError{ID: id}
 	}
 
+	s.setComputedFields(sub)
 	return sub, nil
 }
 
-func (s *subscriptionService) Update(id string, req *model.UpdateSubscriptionRequest) error {
+func (s *subscriptionService) Update(ctx context.Context, id string, req *model.UpdateSubscriptionRequest, expectedVersion *int) (*model.Subscription, error) {
 	uuidID, err := uuid.Parse(id)
 	if err != nil {
 		logrus.WithError(err).WithField("id", id).Error("Invalid UUID format")
-		return &ValidationError{
+		return nil, &ValidationError{
 			Field: "id",
 			Err:   fmt.Errorf("invalid UUID format: %w", err),
+			Key:   MsgInvalidUUID,
 		}
 	}
 
@@ -122,183 +634,1434 @@ func (s *subscriptionService) Update(id string, req *model.UpdateSubscriptionReq
 
 	if req.Price != nil {
 		if *req.Price < 0 {
-			return &ValidationError{
+			return nil, &ValidationError{
 				Field: "price",
 				Err:   errors.New("price cannot be negative"),
+				Key:   MsgNegativePrice,
 			}
 		}
 		updates["price"] = *req.Price
 	}
 
+	if req.Tags != nil {
+		tags, err := normalizeTags(*req.Tags)
+		if err != nil {
+			return nil, err
+		}
+		updates["tags"] = tags
+	}
+
+	if req.Currency != nil {
+		updates["currency"] = strings.ToUpper(*req.Currency)
+	}
+
+	if req.Amount != nil {
+		if req.Price != nil {
+			return nil, errPriceAndAmount()
+		}
+		price, err := s.updatedPriceFromAmount(ctx, uuidID, *req.Amount, req.Currency)
+		if err != nil {
+			return nil, err
+		}
+		updates["price"] = price
+	}
+
+	if req.Description != nil {
+		description, err := sanitizeDescription(*req.Description)
+		if err != nil {
+			return nil, err
+		}
+		updates["description"] = description
+	}
+
 	if req.UserID != nil {
 		userID, err := uuid.Parse(*req.UserID)
 		if err != nil {
-			return &ValidationError{
+			return nil, &ValidationError{
 				Field: "user_id",
 				Err:   fmt.Errorf("invalid UUID format: %w", err),
+				Key:   MsgInvalidUUID,
 			}
 		}
 		updates["user_id"] = userID
 	}
 
 	if req.StartDate != nil {
-		startDate, err := time.Parse("2006-01-02", *req.StartDate)
-		if err != nil {
-			return &ValidationError{
+		if req.StartDate.IsZero() {
+			return nil, &ValidationError{
 				Field: "start_date",
-				Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+				Err:   errors.New("start_date cannot be empty"),
+				Key:   MsgEmptyStartDate,
 			}
 		}
+		startDate := s.normalizeDate(req.StartDate.Time)
+		if err := s.validateStartDate(startDate); err != nil {
+			return nil, err
+		}
 		updates["start_date"] = startDate
 	}
 
 	if req.EndDate != nil {
-		if *req.EndDate == "" {
+		if req.EndDate.IsZero() {
 			updates["end_date"] = nil
 		} else {
-			endDate, err := time.Parse("2006-01-02", *req.EndDate)
-			if err != nil {
-				logrus.WithError(err).Error("Invalid end date format")
-				return &ValidationError{
-					Field: "end_date",
-					Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
-				}
-			}
-			updates["end_date"] = endDate
+			updates["end_date"] = s.normalizeDate(req.EndDate.Time)
+		}
+	}
+
+	if req.AutoRenew != nil {
+		updates["auto_renew"] = *req.AutoRenew
+	}
+
+	if req.Metadata != nil {
+		metadata, err := encodeMetadata(req.Metadata)
+		if err != nil {
+			return nil, err
 		}
+		updates["metadata"] = metadata
 	}
 
 	if len(updates) == 0 {
-		return ErrNoUpdates
+		return nil, ErrNoUpdates
 	}
 
-	if err := s.repo.Update(uuidID, updates); err != nil {
+	// Смена владельца добавляет подписку новому пользователю и проверяется по
+	// его лимиту так же, как создание.
+	var limit repository.UserLimit
+	var reassignedTo uuid.UUID
+	if userID, ok := updates["user_id"].(uuid.UUID); ok {
+		reassignedTo = userID
+		limit = s.userLimit(false)
+	}
+
+	sub, err := s.repo.Update(ctx, uuidID, updates, expectedVersion, s.opts.SkipNoopUpdates, limit)
+	if errors.Is(err, repository.ErrNotModified) {
+		s.setComputedFields(sub)
+		return sub, ErrNotModified
+	}
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return &NotFoundError{ID: id}
+			return nil, &NotFoundError{ID: id}
 		}
-		return fmt.Errorf("failed to update subscription: %w", err)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrVersionConflict
+		}
+		if errors.Is(err, repository.ErrUserLimitExceeded) {
+			return nil, s.limitExceeded(reassignedTo)
+		}
+		if errors.Is(err, repository.ErrReadOnly) {
+			return nil, fmt.Errorf("%w: %w", ErrReadOnly, err)
+		}
+		return nil, fmt.Errorf("failed to update subscription: %w", err)
 	}
 
-	return nil
+	s.cache.Invalidate()
+	s.setComputedFields(sub)
+	return sub, nil
+}
+
+// updatedPriceFromAmount - priceFromAmount для PUT: без новой валюты в запросе
+// масштаб берётся по текущей валюте подписки.
+func (s *subscriptionService) updatedPriceFromAmount(ctx context.Context, id uuid.UUID, amount string, currency *string) (int, error) {
+	if currency != nil {
+		return s.priceFromAmount(amount, strings.ToUpper(*currency))
+	}
+
+	current, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if current == nil {
+		return 0, &NotFoundError{ID: id.String()}
+	}
+	return s.priceFromAmount(amount, current.Currency)
 }
 
-func (s *subscriptionService) Delete(id string) error {
+func (s *subscriptionService) Delete(ctx context.Context, id string, expectedVersion *int) (*model.Subscription, error) {
 	uuidID, err := uuid.Parse(id)
 	if err != nil {
 		logrus.WithError(err).WithField("id", id).Error("Invalid UUID format")
-		return &ValidationError{
+		return nil, &ValidationError{
 			Field: "id",
 			Err:   fmt.Errorf("invalid UUID format: %w", err),
+			Key:   MsgInvalidUUID,
 		}
 	}
 
-	if err := s.repo.Delete(uuidID); err != nil {
+	sub, err := s.repo.Delete(ctx, uuidID, expectedVersion)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return &NotFoundError{ID: id}
+			return nil, &NotFoundError{ID: id}
+		}
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrVersionConflict
 		}
-		return fmt.Errorf("failed to delete subscription: %w", err)
+		if errors.Is(err, repository.ErrReadOnly) {
+			return nil, fmt.Errorf("%w: %w", ErrReadOnly, err)
+		}
+		return nil, fmt.Errorf("failed to delete subscription: %w", err)
 	}
 
-	return nil
+	s.cache.Invalidate()
+	s.setComputedFields(sub)
+	return sub, nil
 }
 
-func (s *subscriptionService) List(userID, serviceName *string, startDate, endDate *string, limit, offset int) ([]*model.Subscription, error) {
-	filter := model.SubscriptionFilter{
-		Limit:  limit,
-		Offset: offset,
+func (s *subscriptionService) List(ctx context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionPage, error) {
+	filter, period, err := s.buildListFilter(req)
+	if err != nil {
+		return nil, err
 	}
 
-	if userID != nil {
-		uuidUserID, err := uuid.Parse(*userID)
-		if err != nil {
-			logrus.WithError(err).WithField("user_id", *userID).Error("Invalid user_id format")
-			return nil, &ValidationError{
-				Field: "user_id",
-				Err:   fmt.Errorf("invalid UUID format: %w", err),
+	page := &model.SubscriptionPage{Period: period}
+
+	var skipped int
+	page.Items, page.Total, skipped, err = s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	s.setComputedFields(page.Items...)
+
+	if skipped > 0 {
+		page.Warnings = append(page.Warnings, fmt.Sprintf("%d rows skipped due to data errors", skipped))
+	}
+
+	if page.Facets, err = s.facets(ctx, req.Facets, filter); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// facets считает запрошенные фасеты по фильтру списка. Значения можно
+// передать повтором параметра или через запятую; повторы отбрасываются.
+func (s *subscriptionService) facets(ctx context.Context, raw []string, filter model.SubscriptionFilter) (map[string]map[string]int, error) {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, value := range raw {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" || seen[field] {
+				continue
+			}
+			if field != model.FacetServiceName && field != model.FacetStatus {
+				return nil, &ValidationError{
+					Field: "facets",
+					Err:   fmt.Errorf("unsupported facet %q, supported: service_name, status", field),
+				}
 			}
+			seen[field] = true
+			fields = append(fields, field)
 		}
-		filter.UserID = &uuidUserID
 	}
 
-	if serviceName != nil {
-		filter.ServiceName = serviceName
+	if len(fields) == 0 {
+		return nil, nil
 	}
 
-	if startDate != nil {
-		sd, err := time.Parse("2006-01-02", *startDate)
+	today := s.today()
+
+	facets := make(map[string]map[string]int, len(fields))
+	for _, field := range fields {
+		counts, err := s.repo.Facet(ctx, filter, field, today)
 		if err != nil {
-			logrus.WithError(err).WithField("start_date", *startDate).Error("Invalid start_date format")
+			return nil, fmt.Errorf("failed to count facet %s: %w", field, err)
+		}
+		facets[field] = counts
+	}
+
+	return facets, nil
+}
+
+func (s *subscriptionService) Query(ctx context.Context, req *model.SubscriptionQuery) (*model.SubscriptionPage, error) {
+	for _, field := range req.Fields {
+		if !model.ValidQueryField(field) {
 			return nil, &ValidationError{
-				Field: "start_date",
-				Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+				Field: "fields",
+				Err:   fmt.Errorf("unknown field %q", field),
 			}
 		}
-		filter.StartDate = &sd
 	}
 
-	if endDate != nil {
-		ed, err := time.Parse("2006-01-02", *endDate)
-		if err != nil {
-			logrus.WithError(err).WithField("end_date", *endDate).Error("Invalid end_date format")
+	// В теле, в отличие от query-строки, неизвестная сортировка - ошибка, а не предупреждение.
+	if req.Sort != "" {
+		if _, _, ok := model.ParseSortIn(req.Sort, s.sortFields()); !ok {
 			return nil, &ValidationError{
-				Field: "end_date",
-				Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+				Field: "sort",
+				Err:   fmt.Errorf("unknown sort field %q", req.Sort),
 			}
 		}
-		filter.EndDate = &ed
 	}
 
-	subscriptions, err := s.repo.List(filter)
+	return s.List(ctx, req.ListRequest())
+}
+
+func (s *subscriptionService) ListRefs(ctx context.Context, req *model.ListSubscriptionsRequest) (*model.SubscriptionRefPage, error) {
+	filter, period, err := s.buildListFilter(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+		return nil, err
 	}
 
-	return subscriptions, nil
+	refs, total, err := s.repo.ListRefs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscription refs: %w", err)
+	}
+
+	return &model.SubscriptionRefPage{Items: refs, Total: total, Period: period}, nil
 }
 
-func (s *subscriptionService) Aggregate(req *model.AggregateRequest) (*model.AggregateResponse, error) {
-	startDate, err := time.Parse("2006-01-02", req.StartDate)
+// buildListFilter строит фильтр списка и раскрывает пресет period в диапазон created_at.
+func (s *subscriptionService) buildListFilter(req *model.ListSubscriptionsRequest) (model.SubscriptionFilter, *model.ResolvedPeriod, error) {
+	filter, err := s.buildFilter(req)
+	if err != nil {
+		return filter, nil, err
+	}
+
+	if req.Period == nil {
+		return filter, nil, nil
+	}
+
+	period, err := s.resolvePeriod(*req.Period, time.Now())
 	if err != nil {
-		logrus.WithError(err).WithField("start_date", req.StartDate).Error("Invalid start_date format")
+		return filter, nil, err
+	}
+	filter.CreatedFrom = &period.From
+	filter.CreatedTo = &period.To
+
+	return filter, period, nil
+}
+
+// setComputedFields заполняет вычисляемые поля (next_billing_date, duration_months,
+// months_remaining) относительно сегодняшней даты в настроенном часовом поясе.
+func (s *subscriptionService) setComputedFields(subs ...*model.Subscription) {
+	today := s.today()
+	for _, sub := range subs {
+		sub.SetComputedFields(today)
+		s.setAmount(sub)
+	}
+}
+
+// setAmount заполняет amount - price с масштабом валюты - в режиме MinorUnits.
+func (s *subscriptionService) setAmount(sub *model.Subscription) {
+	if s.opts.MinorUnits {
+		sub.Amount = model.FormatMinorUnits(int(sub.Price), model.CurrencyScale(sub.Currency))
+	}
+}
+
+// resolvePeriod вычисляет диапазон created_at для пресета в настроенном часовом поясе.
+func (s *subscriptionService) resolvePeriod(period string, now time.Time) (*model.ResolvedPeriod, error) {
+	loc := s.location()
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	resolved := &model.ResolvedPeriod{Name: period}
+	switch period {
+	case "today":
+		resolved.From = today
+		resolved.To = today.AddDate(0, 0, 1)
+	case "week":
+		// неделя начинается с понедельника
+		offset := (int(today.Weekday()) + 6) % 7
+		resolved.From = today.AddDate(0, 0, -offset)
+		resolved.To = resolved.From.AddDate(0, 0, 7)
+	case "month":
+		resolved.From = model.MonthStart(today)
+		resolved.To = resolved.From.AddDate(0, 1, 0)
+	default:
 		return nil, &ValidationError{
-			Field: "start_date",
-			Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+			Field: "period",
+			Err:   fmt.Errorf("unknown period %q, expected today, week or month", period),
 		}
 	}
 
-	endDate, err := time.Parse("2006-01-02", req.EndDate)
+	return resolved, nil
+}
+
+func (s *subscriptionService) Export(ctx context.Context, req *model.ListSubscriptionsRequest, fn func(*model.Subscription) error) error {
+	filter, _, err := s.buildListFilter(req)
 	if err != nil {
-		logrus.WithError(err).WithField("end_date", req.EndDate).Error("Invalid end_date format")
-		return nil, &ValidationError{
-			Field: "end_date",
-			Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+		return err
+	}
+
+	// При лимите выбирается на одну строку больше: её наличие означает, что
+	// выгрузка неполная.
+	filter.Limit = 0
+	if s.opts.MaxExportRows > 0 {
+		filter.Limit = s.opts.MaxExportRows + 1
+	}
+	filter.Offset = 0
+
+	today := s.today()
+	exported := 0
+	err = s.repo.Export(ctx, filter, func(sub *model.Subscription) error {
+		if s.opts.MaxExportRows > 0 && exported == s.opts.MaxExportRows {
+			return ErrExportTruncated
+		}
+		exported++
+		sub.SetComputedFields(today)
+		s.setAmount(sub)
+		return fn(sub)
+	})
+	if err != nil {
+		if errors.Is(err, ErrExportTruncated) {
+			return ErrExportTruncated
 		}
+		return fmt.Errorf("failed to export subscriptions: %w", err)
 	}
 
-	if startDate.After(endDate) {
+	return nil
+}
+
+// ListExpiring возвращает подписки, заканчивающиеся в текущем месяце или в
+// следующие months месяцев. Границы считаются по календарным месяцам в
+// настроенном часовом поясе, что соответствует месячной модели дат.
+func (s *subscriptionService) ListExpiring(ctx context.Context, months, limit, offset int) (*model.ExpiringSubscriptions, error) {
+	if months < 0 || months > maxExpiringMonths {
 		return nil, &ValidationError{
-			Field: "date_range",
-			Err:   errors.New("start_date must be before or equal to end_date"),
+			Field: "months",
+			Err:   fmt.Errorf("months must be between 0 and %d", maxExpiringMonths),
 		}
 	}
 
-	var userIDPtr *uuid.UUID
+	from, to := expiringWindow(s.location(), time.Now(), months)
+
+	subs, total, err := s.repo.ListExpiring(ctx, from, to, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiring subscriptions: %w", err)
+	}
+
+	return &model.ExpiringSubscriptions{From: from, To: to, Items: subs, Total: total}, nil
+}
+
+// expiringWindow возвращает первый день текущего месяца и последний день
+// месяца, отстоящего на months вперёд. Даты возвращаются в UTC, как хранятся в БД.
+func expiringWindow(loc *time.Location, now time.Time, months int) (time.Time, time.Time) {
+	now = now.In(loc)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	// AddDate нормализует переполнение: первое число следующего месяца минус один день
+	// корректно даёт 28/29/30/31 число без учёта дня исходной даты.
+	to := from.AddDate(0, months+1, -1)
+	return from, to
+}
+
+func (s *subscriptionService) location() *time.Location {
+	if s.opts.Location == nil {
+		return time.UTC
+	}
+	return s.opts.Location
+}
+
+// today возвращает сегодняшнюю дату в настроенном часовом поясе.
+func (s *subscriptionService) today() time.Time {
+	return model.DateIn(time.Now(), s.location())
+}
+
+// buildFilter проверяет параметры запроса списка и преобразует их в фильтр репозитория.
+func (s *subscriptionService) buildFilter(req *model.ListSubscriptionsRequest) (model.SubscriptionFilter, error) {
+	sort := s.opts.DefaultSort
+	if req.Sort != nil {
+		if _, _, ok := model.ParseSortIn(*req.Sort, s.sortFields()); !ok {
+			return model.SubscriptionFilter{}, &ValidationError{
+				Field: "sort",
+				Err:   fmt.Errorf("unknown sort field %q", *req.Sort),
+			}
+		}
+		sort = *req.Sort
+	}
+
+	filter := model.SubscriptionFilter{
+		Sort:   sort,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}
+
+	ids, err := s.parseIDs(req.IDs)
+	if err != nil {
+		return filter, err
+	}
+	filter.IDs = ids
+
 	if req.UserID != nil {
 		uuidUserID, err := uuid.Parse(*req.UserID)
 		if err != nil {
 			logrus.WithError(err).WithField("user_id", *req.UserID).Error("Invalid user_id format")
-			return nil, &ValidationError{
+			return filter, &ValidationError{
 				Field: "user_id",
 				Err:   fmt.Errorf("invalid UUID format: %w", err),
+				Key:   MsgInvalidUUID,
+			}
+		}
+		filter.UserID = &uuidUserID
+	}
+
+	var exact *bool
+	if req.Exact != nil {
+		parsed, err := strconv.ParseBool(*req.Exact)
+		if err != nil {
+			return filter, &ValidationError{
+				Field: "exact",
+				Err:   fmt.Errorf("invalid boolean value %q", *req.Exact),
+			}
+		}
+		exact = &parsed
+	}
+	filter.ServiceName = serviceNameFilter(req.ServiceNames, exact)
+
+	if req.Tag != nil {
+		tags, err := normalizeTags([]string{*req.Tag})
+		if err != nil {
+			return filter, err
+		}
+		filter.Tag = &tags[0]
+	}
+
+	if req.StartDate != nil {
+		sd, err := time.Parse("2006-01-02", *req.StartDate)
+		if err != nil {
+			logrus.WithError(err).WithField("start_date", *req.StartDate).Error("Invalid start_date format")
+			return filter, &ValidationError{
+				Field: "start_date",
+				Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+				Key:   MsgInvalidDate,
+			}
+		}
+		sd = s.normalizeDate(sd)
+		filter.StartDate = &sd
+	}
+
+	if req.EndDate != nil {
+		ed, err := time.Parse("2006-01-02", *req.EndDate)
+		if err != nil {
+			logrus.WithError(err).WithField("end_date", *req.EndDate).Error("Invalid end_date format")
+			return filter, &ValidationError{
+				Field: "end_date",
+				Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+				Key:   MsgInvalidDate,
+			}
+		}
+		ed = s.normalizeDate(ed)
+		filter.EndDate = &ed
+	}
+
+	if req.CreatedFrom != nil {
+		from, err := time.Parse(time.RFC3339, *req.CreatedFrom)
+		if err != nil {
+			return filter, &ValidationError{
+				Field: "created_from",
+				Err:   fmt.Errorf("invalid timestamp format, expected RFC3339: %w", err),
+				Key:   MsgInvalidTimestamp,
+			}
+		}
+		filter.CreatedFrom = &from
+	}
+
+	if req.CreatedTo != nil {
+		to, err := time.Parse(time.RFC3339, *req.CreatedTo)
+		if err != nil {
+			return filter, &ValidationError{
+				Field: "created_to",
+				Err:   fmt.Errorf("invalid timestamp format, expected RFC3339: %w", err),
+				Key:   MsgInvalidTimestamp,
+			}
+		}
+		filter.CreatedTo = &to
+	}
+
+	if req.OpenEnded != nil {
+		openEnded, err := strconv.ParseBool(*req.OpenEnded)
+		if err != nil {
+			return filter, &ValidationError{
+				Field: "open_ended",
+				Err:   fmt.Errorf("invalid boolean value %q", *req.OpenEnded),
+			}
+		}
+		filter.OpenEnded = &openEnded
+	}
+
+	if req.ActiveOn != nil {
+		activeOn, err := time.Parse("2006-01-02", *req.ActiveOn)
+		if err != nil {
+			logrus.WithError(err).WithField("active_on", *req.ActiveOn).Error("Invalid active_on format")
+			return filter, &ValidationError{
+				Field: "active_on",
+				Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+				Key:   MsgInvalidDate,
+			}
+		}
+		activeOn = s.normalizeDate(activeOn)
+		filter.ActiveOn = &activeOn
+	}
+
+	if req.UpdatedAfter != nil {
+		after, err := time.Parse(time.RFC3339, *req.UpdatedAfter)
+		if err != nil {
+			return filter, &ValidationError{
+				Field: "updated_after",
+				Err:   fmt.Errorf("invalid timestamp format, expected RFC3339: %w", err),
+				Key:   MsgInvalidTimestamp,
+			}
+		}
+		filter.UpdatedAfter = &after
+	}
+
+	for key := range req.Metadata {
+		if key == "" {
+			return filter, &ValidationError{
+				Field: "meta",
+				Err:   errors.New("metadata key cannot be empty"),
+				Key:   MsgEmptyMetadataKey,
+			}
+		}
+	}
+	if len(req.Metadata) > 0 {
+		filter.Metadata = req.Metadata
+	}
+
+	return filter, nil
+}
+
+func (s *subscriptionService) Aggregate(ctx context.Context, req *model.AggregateRequest) (*model.AggregateResponse, error) {
+	startDate, endDate, err := s.parseAggregateRange(req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDPtr, err := parseOptionalUserID(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := serviceNameFilter(req.ServiceNames, req.Exact)
+
+	var currency string
+	if req.Currency != nil {
+		currency = strings.ToUpper(*req.Currency)
+	}
+
+	key := aggregateCacheKey("total", startDate, endDate, userIDPtr, serviceName, currency)
+	var cached model.AggregateResponse
+	if s.cache.get(ctx, key, &cached) {
+		return &cached, nil
+	}
+	gen := s.cache.generation(ctx)
+
+	var resp *model.AggregateResponse
+	switch {
+	case s.summariesCover(startDate, serviceName):
+		resp, err = s.aggregateFromSummaries(ctx, currency, startDate, endDate, userIDPtr)
+		if err != nil {
+			return nil, err
+		}
+	case currency != "":
+		resp, err = s.aggregateInCurrency(ctx, currency, startDate, endDate, userIDPtr, serviceName)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		total, err := s.repo.Aggregate(ctx, startDate, endDate, userIDPtr, serviceName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate subscriptions: %w", err)
+		}
+		resp = &model.AggregateResponse{TotalPrice: model.Money(total)}
+	}
+
+	s.cache.set(ctx, key, resp, gen)
+	return resp, nil
+}
+
+// AggregateMonthly возвращает помесячную разбивку за период и, по запросу, общую
+// сумму. Оба значения считаются из одного запроса, поэтому итог всегда равен
+// сумме помесячных значений. Валюты пересчитываются по курсу на конец периода,
+// как и в Aggregate.
+func (s *subscriptionService) AggregateMonthly(ctx context.Context, req *model.AggregateRequest) (*model.AggregateMonthlyResponse, error) {
+	startDate, endDate, err := s.parseAggregateRange(req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDPtr, err := parseOptionalUserID(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := serviceNameFilter(req.ServiceNames, req.Exact)
+
+	var currency string
+	if req.Currency != nil {
+		currency = strings.ToUpper(*req.Currency)
+	}
+
+	granularity := model.GranularityMonth
+	if req.Granularity != nil {
+		granularity = *req.Granularity
+	}
+	if _, ok := granularityMonths[granularity]; !ok {
+		return nil, &ValidationError{
+			Field: "granularity",
+			Err:   fmt.Errorf("unknown granularity %q, expected month, quarter or year", granularity),
+		}
+	}
+
+	key := aggregateCacheKey("monthly", startDate, endDate, userIDPtr, serviceName, currency, strconv.FormatBool(req.IncludeTotal), granularity)
+	var cached model.AggregateMonthlyResponse
+	if s.cache.get(ctx, key, &cached) {
+		return &cached, nil
+	}
+	gen := s.cache.generation(ctx)
+
+	rows, err := s.repo.AggregateByMonth(ctx, startDate, endDate, userIDPtr, serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate subscriptions: %w", err)
+	}
+
+	monthly := []model.MonthlyTotal{}
+	buckets, bucketIndex := timeBuckets(granularity, startDate, endDate)
+	total := 0
+	for _, row := range rows {
+		amount := row.Total
+		if currency != "" {
+			amount, err = s.converter.Convert(row.Total, row.Currency, currency, endDate)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		month := row.Month.Format("2006-01")
+		if n := len(monthly); n > 0 && monthly[n-1].Month == month {
+			monthly[n-1].TotalPrice += model.Money(amount)
+		} else {
+			monthly = append(monthly, model.MonthlyTotal{Month: month, TotalPrice: model.Money(amount)})
+		}
+		if i, ok := bucketIndex[bucketStart(granularity, row.Month)]; ok {
+			buckets[i].TotalPrice += model.Money(amount)
+		}
+		total += amount
+	}
+
+	resp := &model.AggregateMonthlyResponse{
+		Currency:    currency,
+		Monthly:     monthly,
+		Granularity: granularity,
+		Buckets:     buckets,
+	}
+	if req.IncludeTotal {
+		totalPrice := model.Money(total)
+		resp.TotalPrice = &totalPrice
+	}
+
+	s.cache.set(ctx, key, resp, gen)
+	return resp, nil
+}
+
+func (s *subscriptionService) AggregateMatrix(ctx context.Context, req *model.AggregateRequest) (*model.AggregateServiceUserResponse, error) {
+	startDate, endDate, err := s.parseAggregateRange(req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDPtr, err := parseOptionalUserID(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := serviceNameFilter(req.ServiceNames, req.Exact)
+
+	var currency string
+	if req.Currency != nil {
+		currency = strings.ToUpper(*req.Currency)
+	}
+
+	// Строк на одну больше лимита: её наличие означает, что групп слишком много.
+	// Строки разбиты ещё и по валюте, поэтому лимит проверяется с запасом.
+	limit := math.MaxInt32
+	if s.opts.MaxAggregateGroups > 0 {
+		limit = s.opts.MaxAggregateGroups + 1
+	}
+
+	rows, err := s.repo.AggregateMatrix(ctx, startDate, endDate, userIDPtr, serviceName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate subscriptions: %w", err)
+	}
+
+	if s.opts.MaxAggregateGroups > 0 && len(rows) > s.opts.MaxAggregateGroups {
+		return nil, &ValidationError{
+			Field: "group_by",
+			Err:   fmt.Errorf("more than %d groups, narrow the filters (user_id, service_name, period)", s.opts.MaxAggregateGroups),
+		}
+	}
+
+	groups := []model.ServiceUserTotal{}
+	for _, row := range rows {
+		amount := row.Total
+		if currency != "" {
+			amount, err = s.converter.Convert(row.Total, row.Currency, currency, endDate)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Строки одной пары с разными валютами идут подряд.
+		if n := len(groups); n > 0 && groups[n-1].ServiceName == row.ServiceName && groups[n-1].UserID == row.UserID {
+			groups[n-1].TotalPrice += model.Money(amount)
+		} else {
+			groups = append(groups, model.ServiceUserTotal{ServiceName: row.ServiceName, UserID: row.UserID, TotalPrice: model.Money(amount)})
+		}
+	}
+
+	return &model.AggregateServiceUserResponse{Currency: currency, Groups: groups}, nil
+}
+
+// CompareAggregate считает итоги двух периодов через Aggregate с одинаковыми
+// фильтрами и возвращает разницу B - A. Периоды валидируются независимо;
+// в ошибке валидации поле получает префикс периода (period_a./period_b.).
+func (s *subscriptionService) CompareAggregate(ctx context.Context, req *model.CompareAggregateRequest) (*model.CompareAggregateResponse, error) {
+	periodA, err := s.aggregatePeriod(ctx, "period_a", req.PeriodAStart, req.PeriodAEnd, req)
+	if err != nil {
+		return nil, err
+	}
+
+	periodB, err := s.aggregatePeriod(ctx, "period_b", req.PeriodBStart, req.PeriodBEnd, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.CompareAggregateResponse{
+		PeriodA: model.PeriodTotal{StartDate: req.PeriodAStart, EndDate: req.PeriodAEnd, TotalPrice: periodA.TotalPrice},
+		PeriodB: model.PeriodTotal{StartDate: req.PeriodBStart, EndDate: req.PeriodBEnd, TotalPrice: periodB.TotalPrice},
+		Delta:   periodB.TotalPrice - periodA.TotalPrice,
+		// Валюта одинакова для обоих периодов: либо запрошенная, либо пустая.
+		Currency: periodA.Currency,
+	}
+
+	if periodA.TotalPrice != 0 {
+		percent := math.Round(float64(resp.Delta)/float64(periodA.TotalPrice)*10000) / 100
+		resp.DeltaPercent = &percent
+	}
+
+	return resp, nil
+}
+
+func (s *subscriptionService) aggregatePeriod(ctx context.Context, name, start, end string, req *model.CompareAggregateRequest) (*model.AggregateResponse, error) {
+	result, err := s.Aggregate(ctx, &model.AggregateRequest{
+		UserID:       req.UserID,
+		ServiceNames: req.ServiceNames,
+		Exact:        req.Exact,
+		StartDate:    start,
+		EndDate:      end,
+		Currency:     req.Currency,
+	})
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			return nil, &ValidationError{Field: name + "." + validationErr.Field, Err: validationErr.Err}
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Timeline возвращает количество подписок, активных на конец каждого месяца периода.
+// Длина периода ограничена так же, как у агрегации.
+func (s *subscriptionService) Timeline(ctx context.Context, req *model.TimelineRequest) ([]model.TimelinePoint, error) {
+	startDate, endDate, err := s.parseAggregateRange(req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDPtr, err := parseOptionalUserID(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.repo.Timeline(ctx, startDate, endDate, userIDPtr, serviceNameFilter(req.ServiceNames, req.Exact))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build timeline: %w", err)
+	}
+
+	points := make([]model.TimelinePoint, 0, len(counts))
+	for _, c := range counts {
+		points = append(points, model.TimelinePoint{Month: c.Month.Format("2006-01"), ActiveCount: c.Count})
+	}
+
+	return points, nil
+}
+
+// aggregateInCurrency суммирует подписки по каждой валюте отдельно и переводит
+// суммы в целевую валюту по курсу на конец периода.
+// SimulatePriceChange оценивает, как изменится итог за период, если поменять
+// цену подписок на сервис. Суммы считаются так же, как в Aggregate без currency.
+func (s *subscriptionService) SimulatePriceChange(ctx context.Context, req *model.SimulatePriceChangeRequest) (*model.SimulatePriceChangeResponse, error) {
+	if (req.NewPrice == nil) == (req.PercentChange == nil) {
+		return nil, &ValidationError{
+			Field: "new_price",
+			Err:   errors.New("exactly one of new_price and percent_change is required"),
+		}
+	}
+
+	if req.PercentChange != nil && *req.PercentChange < -100 {
+		return nil, &ValidationError{
+			Field: "percent_change",
+			Err:   errors.New("percent_change cannot be less than -100"),
+		}
+	}
+
+	startDate, endDate, err := s.parseAggregateRange(req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDPtr, err := parseOptionalUserID(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := serviceNameFilter([]string{req.ServiceName}, req.Exact)
+	if serviceName == nil {
+		return nil, &ValidationError{
+			Field: "service_name",
+			Err:   errors.New("service_name is required"),
+		}
+	}
+
+	current, projected, err := s.repo.SimulatePriceChange(ctx, startDate, endDate, userIDPtr, serviceName, req.NewPrice, req.PercentChange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate price change: %w", err)
+	}
+
+	return &model.SimulatePriceChangeResponse{
+		CurrentTotal:   model.Money(current),
+		ProjectedTotal: model.Money(projected),
+		Delta:          model.Money(projected - current),
+	}, nil
+}
+
+func (s *subscriptionService) Top(ctx context.Context, req *model.TopSubscriptionsRequest) ([]*model.Subscription, error) {
+	by := req.By
+	if by == "" {
+		by = model.TopByPrice
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultTopLimit
+	}
+
+	userIDPtr, err := parseOptionalUserID(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := s.today()
+
+	subs, err := s.repo.Top(ctx, by, limit, today, userIDPtr, serviceNameFilter(req.ServiceNames, req.Exact))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list top subscriptions: %w", err)
+	}
+
+	s.setComputedFields(subs...)
+	return subs, nil
+}
+
+func (s *subscriptionService) Changes(ctx context.Context, req *model.ChangesRequest) (*model.ChangesPage, error) {
+	since, err := time.Parse(time.RFC3339, req.Since)
+	if err != nil {
+		return nil, &ValidationError{
+			Field: "since",
+			Err:   fmt.Errorf("invalid timestamp format, expected RFC3339: %w", err),
+			Key:   MsgInvalidTimestamp,
+		}
+	}
+
+	var afterID *uuid.UUID
+	if req.AfterID != nil {
+		id, err := uuid.Parse(*req.AfterID)
+		if err != nil {
+			return nil, &ValidationError{
+				Field: "after_id",
+				Err:   fmt.Errorf("invalid UUID format: %w", err),
+				Key:   MsgInvalidUUID,
 			}
 		}
-		userIDPtr = &uuidUserID
+		afterID = &id
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultChangesLimit
+	}
+
+	// Лишняя строка показывает, есть ли следующая страница.
+	subs, err := s.repo.Changes(ctx, since, afterID, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscription changes: %w", err)
+	}
+
+	page := &model.ChangesPage{
+		Data:        subs,
+		NextSince:   since,
+		NextAfterID: afterID,
+	}
+	if len(subs) > limit {
+		page.Data = subs[:limit]
+		page.HasMore = true
+	}
+	if len(page.Data) > 0 {
+		last := page.Data[len(page.Data)-1]
+		page.NextSince = last.UpdatedAt
+		page.NextAfterID = &last.ID
+	}
+
+	s.setComputedFields(page.Data...)
+	return page, nil
+}
+
+func (s *subscriptionService) UserMonthlyCost(ctx context.Context, userID string) (*model.AggregateResponse, error) {
+	uuidUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, &ValidationError{
+			Field: "user_id",
+			Err:   fmt.Errorf("invalid UUID format: %w", err),
+			Key:   MsgInvalidUUID,
+		}
+	}
+
+	today := s.today()
+
+	totals, err := s.repo.MonthlyCostByUser(ctx, uuidUserID, today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum monthly cost: %w", err)
+	}
+
+	currency := s.converter.BaseCurrency()
+	total := 0
+	for from, amount := range totals {
+		converted, err := s.converter.Convert(amount, from, currency, today)
+		if err != nil {
+			return nil, err
+		}
+		total += converted
+	}
+
+	return &model.AggregateResponse{TotalPrice: model.Money(total), Currency: currency}, nil
+}
+
+func (s *subscriptionService) DeletionPreview(ctx context.Context, userID string) (*model.UserDeletionPreview, error) {
+	uuidUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, &ValidationError{
+			Field: "user_id",
+			Err:   fmt.Errorf("invalid UUID format: %w", err),
+			Key:   MsgInvalidUUID,
+		}
+	}
+
+	subs, _, skipped, err := s.repo.List(ctx, model.SubscriptionFilter{UserID: &uuidUserID, Sort: "start_date"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user subscriptions: %w", err)
+	}
+	if skipped > 0 {
+		logrus.WithFields(logrus.Fields{"user_id": uuidUserID, "skipped": skipped}).Warn("Deletion preview skipped malformed subscriptions")
 	}
 
-	total, err := s.repo.Aggregate(startDate, endDate, userIDPtr, req.ServiceName)
+	s.setComputedFields(subs...)
+
+	preview := &model.UserDeletionPreview{
+		UserID:        uuidUserID,
+		Count:         len(subs),
+		Subscriptions: subs,
+		Currency:      s.converter.BaseCurrency(),
+	}
+	if preview.Subscriptions == nil {
+		preview.Subscriptions = []*model.Subscription{}
+	}
+
+	today := s.today()
+
+	// Выручка - тот же расчёт, что и у агрегации, за всю историю пользователя.
+	if len(subs) > 0 && !subs[0].StartDate.After(today) {
+		revenue, err := s.aggregateInCurrency(ctx, preview.Currency, subs[0].StartDate, today, &uuidUserID, nil)
+		if err != nil {
+			return nil, err
+		}
+		preview.TotalRevenue = revenue.TotalPrice
+	}
+
+	return preview, nil
+}
+
+func (s *subscriptionService) aggregateInCurrency(ctx context.Context, currency string, startDate, endDate time.Time, userID *uuid.UUID, serviceName *model.ServiceNameFilter) (*model.AggregateResponse, error) {
+	totals, err := s.repo.AggregateByCurrency(ctx, startDate, endDate, userID, serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate subscriptions: %w", err)
+	}
+
+	return s.convertTotals(totals, currency, endDate)
+}
+
+// summariesCover сообщает, можно ли посчитать итог по сводке: она включена,
+// период начинается с первого числа месяца (даты подписок при точности "month"
+// уже выровнены) и нет фильтра по сервису, которого в сводке нет.
+func (s *subscriptionService) summariesCover(startDate time.Time, serviceName *model.ServiceNameFilter) bool {
+	return s.opts.Summaries != nil && startDate.Day() == 1 && serviceName == nil
+}
+
+// aggregateFromSummaries - Aggregate по помесячной сводке. Как и в живом запросе,
+// месяц, на который приходится конец периода, не учитывается; без currency
+// суммы валют складываются без пересчёта.
+func (s *subscriptionService) aggregateFromSummaries(ctx context.Context, currency string, startDate, endDate time.Time, userID *uuid.UUID) (*model.AggregateResponse, error) {
+	totals, err := s.opts.Summaries.TotalsByCurrency(ctx, startDate, model.MonthStart(endDate), userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to aggregate subscriptions: %w", err)
 	}
 
-	return &model.AggregateResponse{TotalPrice: total}, nil
+	if currency != "" {
+		return s.convertTotals(totals, currency, endDate)
+	}
+
+	total := 0
+	for _, amount := range totals {
+		total += amount
+	}
+	return &model.AggregateResponse{TotalPrice: model.Money(total)}, nil
+}
+
+// convertTotals переводит суммы по валютам в currency по курсу на date и складывает.
+func (s *subscriptionService) convertTotals(totals map[string]int, currency string, date time.Time) (*model.AggregateResponse, error) {
+	total := 0
+	for from, amount := range totals {
+		converted, err := s.converter.Convert(amount, from, currency, date)
+		if err != nil {
+			return nil, err
+		}
+		total += converted
+	}
+
+	return &model.AggregateResponse{TotalPrice: model.Money(total), Currency: currency}, nil
+}
+
+func (s *subscriptionService) AggregateBatch(ctx context.Context, req *model.BatchAggregateRequest) (*model.BatchAggregateResponse, error) {
+	startDate, endDate, err := s.parseAggregateRange(req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]struct{}, len(req.UserIDs))
+	userIDs := make([]uuid.UUID, 0, len(req.UserIDs))
+	for _, raw := range req.UserIDs {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			logrus.WithError(err).WithField("user_id", raw).Error("Invalid user_id format")
+			return nil, &ValidationError{
+				Field: "user_ids",
+				Err:   fmt.Errorf("invalid UUID format %q: %w", raw, err),
+				Key:   MsgInvalidUUID,
+			}
+		}
+		if _, ok := seen[userID]; ok {
+			continue
+		}
+		seen[userID] = struct{}{}
+		userIDs = append(userIDs, userID)
+	}
+
+	if len(userIDs) == 0 {
+		return nil, &ValidationError{
+			Field: "user_ids",
+			Err:   errors.New("at least one user_id is required"),
+		}
+	}
+
+	if s.opts.MaxBatchUsers > 0 && len(userIDs) > s.opts.MaxBatchUsers {
+		return nil, &ValidationError{
+			Field: "user_ids",
+			Err:   fmt.Errorf("too many user_ids: %d, maximum is %d", len(userIDs), s.opts.MaxBatchUsers),
+		}
+	}
+
+	var serviceNames []string
+	if req.ServiceName != nil {
+		serviceNames = []string{*req.ServiceName}
+	}
+
+	totals, err := s.repo.AggregateByUsers(ctx, startDate, endDate, userIDs, serviceNameFilter(serviceNames, req.Exact))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate subscriptions: %w", err)
+	}
+
+	resp := &model.BatchAggregateResponse{Totals: make(map[string]model.Money, len(userIDs))}
+	for _, userID := range userIDs {
+		resp.Totals[userID.String()] = model.Money(totals[userID])
+	}
+
+	return resp, nil
+}
+
+// normalizeTags обрезает пробелы, приводит теги к нижнему регистру и убирает
+// повторы с сохранением порядка, чтобы "Work" и "work" были одним тегом.
+// Лимит maxTags проверяется уже после удаления повторов.
+func normalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			return nil, &ValidationError{
+				Field: "tags",
+				Err:   errors.New("tag cannot be empty"),
+				Key:   MsgEmptyTag,
+			}
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, &ValidationError{
+				Field: "tags",
+				Err:   fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength),
+				Key:   MsgTagTooLong,
+				Args:  []interface{}{tag, maxTagLength},
+			}
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > maxTags {
+		return nil, &ValidationError{
+			Field: "tags",
+			Err:   fmt.Errorf("too many tags: %d, maximum is %d", len(normalized), maxTags),
+			Key:   MsgTooManyTags,
+			Args:  []interface{}{len(normalized), maxTags},
+		}
+	}
+
+	return normalized, nil
+}
+
+// encodeMetadata проверяет, что metadata - плоский объект со скалярными
+// значениями в пределах maxMetadataBytes, и возвращает его JSON для записи в БД.
+func encodeMetadata(metadata map[string]interface{}) (string, error) {
+	for key, value := range metadata {
+		if key == "" {
+			return "", &ValidationError{
+				Field: "metadata",
+				Err:   errors.New("metadata key cannot be empty"),
+				Key:   MsgEmptyMetadataKey,
+			}
+		}
+		switch value.(type) {
+		case string, float64, bool, nil:
+		default:
+			return "", &ValidationError{
+				Field: "metadata",
+				Err:   fmt.Errorf("metadata value for key %q must be a string, number, boolean or null", key),
+				Key:   MsgMetadataNotFlat,
+				Args:  []interface{}{key},
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	if len(encoded) > maxMetadataBytes {
+		return "", &ValidationError{
+			Field: "metadata",
+			Err:   fmt.Errorf("metadata is larger than %d bytes", maxMetadataBytes),
+			Key:   MsgMetadataTooLarge,
+			Args:  []interface{}{maxMetadataBytes},
+		}
+	}
+
+	return string(encoded), nil
+}
+
+// sanitizeDescription удаляет управляющие символы (кроме перевода строки и
+// табуляции), обрезает пробелы по краям и проверяет длину.
+func sanitizeDescription(description string) (string, error) {
+	description = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, description))
+
+	if utf8.RuneCountInString(description) > maxDescriptionLength {
+		return "", &ValidationError{
+			Field: "description",
+			Err:   fmt.Errorf("description is longer than %d characters", maxDescriptionLength),
+			Key:   MsgDescriptionTooLong,
+			Args:  []interface{}{maxDescriptionLength},
+		}
+	}
+
+	return description, nil
+}
+
+// validateStartDate отклоняет start_date дальше MaxFutureStartMonths в будущем
+// или раньше MaxPastStartYears в прошлом - обычно это опечатка в годе.
+func (s *subscriptionService) validateStartDate(startDate time.Time) error {
+	today := s.today()
+
+	if s.opts.MaxFutureStartMonths > 0 && startDate.After(model.AddMonths(today, s.opts.MaxFutureStartMonths)) {
+		return &ValidationError{
+			Field: "start_date",
+			Err:   fmt.Errorf("start_date must not be more than %d months in the future", s.opts.MaxFutureStartMonths),
+			Key:   MsgStartTooFarInFuture,
+			Args:  []interface{}{s.opts.MaxFutureStartMonths},
+		}
+	}
+
+	if s.opts.MaxPastStartYears > 0 && startDate.Before(today.AddDate(-s.opts.MaxPastStartYears, 0, 0)) {
+		return &ValidationError{
+			Field: "start_date",
+			Err:   fmt.Errorf("start_date must not be more than %d years in the past", s.opts.MaxPastStartYears),
+			Key:   MsgStartTooFarInPast,
+			Args:  []interface{}{s.opts.MaxPastStartYears},
+		}
+	}
+
+	return nil
+}
+
+// normalizeDate приводит дату к началу месяца, если включена месячная точность.
+func (s *subscriptionService) normalizeDate(t time.Time) time.Time {
+	if s.opts.MonthPrecision {
+		return model.MonthStart(t)
+	}
+	return t
+}
+
+// parseAggregateRange разбирает период агрегации и проверяет, что он не длиннее MaxAggregateYears.
+func (s *subscriptionService) parseAggregateRange(start, end string) (time.Time, time.Time, error) {
+	startDate, endDate, err := parseDateRange(start, end)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if s.opts.MaxAggregateYears > 0 && endDate.After(startDate.AddDate(s.opts.MaxAggregateYears, 0, 0)) {
+		return time.Time{}, time.Time{}, &ValidationError{
+			Field: "date_range",
+			Err:   fmt.Errorf("date range must not exceed %d years", s.opts.MaxAggregateYears),
+		}
+	}
+
+	return startDate, endDate, nil
+}
+
+// serviceNameFilter строит фильтр по сервису. List и агрегации сопоставляют
+// название одинаково: по умолчанию по подстроке, при exact=true - целиком.
+// Значения можно передать повтором параметра или через запятую; пустые
+// элементы отбрасываются.
+func serviceNameFilter(names []string, exact *bool) *model.ServiceNameFilter {
+	var values []string
+	for _, raw := range names {
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				values = append(values, name)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return &model.ServiceNameFilter{Values: values, Exact: exact != nil && *exact}
+}
+
+// parseIDs разбирает фильтр ids: значения можно передать повтором параметра
+// или через запятую, дубликаты отбрасываются.
+func (s *subscriptionService) parseIDs(raw []string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	seen := make(map[uuid.UUID]struct{})
+	for _, value := range raw {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			id, err := uuid.Parse(part)
+			if err != nil {
+				return nil, &ValidationError{
+					Field: "ids",
+					Err:   fmt.Errorf("invalid UUID format %q: %w", part, err),
+					Key:   MsgInvalidUUID,
+				}
+			}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+
+	if s.opts.MaxListIDs > 0 && len(ids) > s.opts.MaxListIDs {
+		return nil, &ValidationError{
+			Field: "ids",
+			Err:   fmt.Errorf("too many ids: %d, maximum is %d", len(ids), s.opts.MaxListIDs),
+			Key:   MsgTooManyIDs,
+			Args:  []interface{}{len(ids), s.opts.MaxListIDs},
+		}
+	}
+
+	return ids, nil
+}
+
+// parseOptionalUserID разбирает необязательный user_id из query-параметров.
+func parseOptionalUserID(raw *string) (*uuid.UUID, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	userID, err := uuid.Parse(*raw)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", *raw).Error("Invalid user_id format")
+		return nil, &ValidationError{
+			Field: "user_id",
+			Err:   fmt.Errorf("invalid UUID format: %w", err),
+			Key:   MsgInvalidUUID,
+		}
+	}
+
+	return &userID, nil
+}
+
+func parseDateRange(start, end string) (time.Time, time.Time, error) {
+	startDate, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		logrus.WithError(err).WithField("start_date", start).Error("Invalid start_date format")
+		return time.Time{}, time.Time{}, &ValidationError{
+			Field: "start_date",
+			Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+			Key:   MsgInvalidDate,
+		}
+	}
+
+	endDate, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		logrus.WithError(err).WithField("end_date", end).Error("Invalid end_date format")
+		return time.Time{}, time.Time{}, &ValidationError{
+			Field: "end_date",
+			Err:   fmt.Errorf("invalid date format, expected YYYY-MM-DD: %w", err),
+			Key:   MsgInvalidDate,
+		}
+	}
+
+	if startDate.After(endDate) {
+		return time.Time{}, time.Time{}, &ValidationError{
+			Field: "date_range",
+			Err:   errors.New("start_date must be before or equal to end_date"),
+			Key:   MsgStartAfterEnd,
+		}
+	}
+
+	return startDate, endDate, nil
 }
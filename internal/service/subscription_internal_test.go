@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiringWindow(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	newYork := time.FixedZone("EST", -5*60*60)
+
+	tests := []struct {
+		name     string
+		loc      *time.Location
+		now      time.Time
+		months   int
+		from, to string
+	}{
+		{"same month", time.UTC, time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC), 0, "2024-06-01", "2024-06-30"},
+		{"same month on last day", time.UTC, time.Date(2024, time.January, 31, 23, 59, 0, 0, time.UTC), 0, "2024-01-01", "2024-01-31"},
+		{"end of month into leap February", time.UTC, time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC), 1, "2024-01-01", "2024-02-29"},
+		{"end of month into short February", time.UTC, time.Date(2023, time.January, 31, 0, 0, 0, 0, time.UTC), 1, "2023-01-01", "2023-02-28"},
+		{"into 30-day month", time.UTC, time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC), 1, "2024-03-01", "2024-04-30"},
+		{"across year end", time.UTC, time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC), 2, "2024-12-01", "2025-02-28"},
+		{"timezone ahead of UTC is already next month", moscow, time.Date(2024, time.January, 31, 22, 0, 0, 0, time.UTC), 0, "2024-02-01", "2024-02-29"},
+		{"timezone behind UTC is still previous month", newYork, time.Date(2024, time.March, 1, 2, 0, 0, 0, time.UTC), 0, "2024-02-01", "2024-02-29"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := expiringWindow(tt.loc, tt.now, tt.months)
+
+			if got := from.Format("2006-01-02"); got != tt.from {
+				t.Errorf("from = %s, want %s", got, tt.from)
+			}
+			if got := to.Format("2006-01-02"); got != tt.to {
+				t.Errorf("to = %s, want %s", got, tt.to)
+			}
+			if from.Location() != time.UTC || to.Location() != time.UTC {
+				t.Errorf("window %v - %v is not in UTC", from, to)
+			}
+		})
+	}
+}
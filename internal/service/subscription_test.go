@@ -0,0 +1,373 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"subscription_service/internal/mocks"
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+	"subscription_service/internal/service"
+
+	"github.com/google/uuid"
+)
+
+const testUserID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func newTestService(repo *mocks.SubscriptionRepository, opts service.Options) service.SubscriptionService {
+	converter := service.NewCurrencyConverter(nil, "RUB", time.Hour, false)
+	return service.NewSubscriptionService(repo, converter, opts)
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse(model.DateFormat, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestCreateNormalizesDatesToPrecision(t *testing.T) {
+	tests := []struct {
+		name           string
+		monthPrecision bool
+		start, end     string
+		wantStart      string
+		wantEnd        string
+	}{
+		{"day keeps mid-month dates", false, "2024-06-15", "2024-09-30", "2024-06-15", "2024-09-30"},
+		{"month truncates mid-month dates", true, "2024-06-15", "2024-09-30", "2024-06-01", "2024-09-01"},
+		{"month keeps first of month", true, "2024-06-01", "2024-07-01", "2024-06-01", "2024-07-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored *model.Subscription
+			repo := &mocks.SubscriptionRepository{
+				CreateFn: func(_ context.Context, sub *model.Subscription, _ repository.UserLimit) error {
+					stored = sub
+					return nil
+				},
+			}
+			svc := newTestService(repo, service.Options{MonthPrecision: tt.monthPrecision})
+
+			_, err := svc.Create(context.Background(), &model.CreateSubscriptionRequest{
+				ServiceName: "Yandex Plus",
+				Price:       400,
+				UserID:      testUserID,
+				StartDate:   &model.Date{Time: date(tt.start)},
+				EndDate:     &model.Date{Time: date(tt.end)},
+			})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			if got := stored.StartDate.Format(model.DateFormat); got != tt.wantStart {
+				t.Errorf("start_date = %s, want %s", got, tt.wantStart)
+			}
+			if got := stored.EndDate.Format(model.DateFormat); got != tt.wantEnd {
+				t.Errorf("end_date = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestCreateIfNotExistsChecksOnlyNewSubscriptions(t *testing.T) {
+	existing := mocks.NewSubscription()
+
+	tests := []struct {
+		name        string
+		existing    *model.Subscription
+		wantCreated bool
+		wantErr     bool
+	}{
+		{"existing subscription is returned without checks", existing, false, false},
+		{"new subscription runs checks in the create transaction", nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checked := false
+			repo := &mocks.SubscriptionRepository{
+				CreateIfNotExistsFn: func(_ context.Context, sub *model.Subscription, _ repository.UserLimit, check func() error) (*model.Subscription, bool, error) {
+					if tt.existing != nil {
+						return tt.existing, false, nil
+					}
+					checked = true
+					if err := check(); err != nil {
+						return nil, false, err
+					}
+					return sub, true, nil
+				},
+			}
+			// Цена выше порога мягкой валидации: проверка отклоняет новую подписку.
+			svc := newTestService(repo, service.Options{Warnings: service.WarningRules{PriceAbove: 100}})
+
+			sub, created, err := svc.CreateIfNotExists(context.Background(), &model.CreateSubscriptionRequest{
+				ServiceName: existing.ServiceName,
+				Price:       400,
+				UserID:      testUserID,
+				StartDate:   &model.Date{Time: existing.StartDate},
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateIfNotExists error = %v, want error %t", err, tt.wantErr)
+			}
+			if created != tt.wantCreated {
+				t.Errorf("created = %t, want %t", created, tt.wantCreated)
+			}
+			if checked != (tt.existing == nil) {
+				t.Errorf("checks ran = %t, want %t", checked, tt.existing == nil)
+			}
+			if tt.existing != nil && sub.ID != existing.ID {
+				t.Errorf("returned %s, want existing %s", sub.ID, existing.ID)
+			}
+		})
+	}
+}
+
+func TestUpdateReassignmentChecksNewOwnerLimit(t *testing.T) {
+	newOwner := uuid.New()
+
+	tests := []struct {
+		name      string
+		userID    *string
+		wantLimit bool
+	}{
+		{"reassignment passes the limit", strPtr(newOwner.String()), true},
+		{"other fields skip the limit", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var limit repository.UserLimit
+			repo := &mocks.SubscriptionRepository{
+				UpdateFn: func(_ context.Context, _ uuid.UUID, _ map[string]interface{}, _ *int, _ bool, l repository.UserLimit) (*model.Subscription, error) {
+					limit = l
+					if l.Max > 0 {
+						// Репозиторий нашёл у нового владельца превышение лимита.
+						return nil, fmt.Errorf("update: %w", repository.ErrUserLimitExceeded)
+					}
+					return mocks.NewSubscription(), nil
+				},
+			}
+			svc := newTestService(repo, service.Options{MaxActivePerUser: 2})
+
+			_, err := svc.Update(context.Background(), uuid.NewString(), &model.UpdateSubscriptionRequest{
+				UserID:      tt.userID,
+				ServiceName: strPtr("Netflix"),
+			}, nil)
+
+			if got := limit.Max > 0; got != tt.wantLimit {
+				t.Fatalf("limit = %+v, want limit passed %t", limit, tt.wantLimit)
+			}
+
+			if !tt.wantLimit {
+				if err != nil {
+					t.Fatalf("Update: %v", err)
+				}
+				return
+			}
+
+			var limitErr *service.LimitExceededError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("Update error = %v, want LimitExceededError", err)
+			}
+			if limitErr.UserID != newOwner.String() || limitErr.Limit != 2 {
+				t.Errorf("LimitExceededError = %+v, want user %s and limit 2", limitErr, newOwner)
+			}
+		})
+	}
+}
+
+func TestListFilterDatesFollowPrecision(t *testing.T) {
+	tests := []struct {
+		name           string
+		monthPrecision bool
+		value          string
+		want           string
+	}{
+		{"day keeps mid-month value", false, "2024-06-15", "2024-06-15"},
+		{"day keeps month end", false, "2024-02-29", "2024-02-29"},
+		{"month truncates mid-month value", true, "2024-06-15", "2024-06-01"},
+		{"month truncates month end", true, "2024-02-29", "2024-02-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter model.SubscriptionFilter
+			repo := &mocks.SubscriptionRepository{
+				ListFn: func(_ context.Context, f model.SubscriptionFilter) ([]*model.Subscription, int, int, error) {
+					filter = f
+					return nil, 0, 0, nil
+				},
+			}
+			svc := newTestService(repo, service.Options{MonthPrecision: tt.monthPrecision})
+
+			_, err := svc.List(context.Background(), &model.ListSubscriptionsRequest{
+				StartDate: strPtr(tt.value),
+				EndDate:   strPtr(tt.value),
+				ActiveOn:  strPtr(tt.value),
+				Limit:     10,
+			})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+
+			for name, got := range map[string]*time.Time{
+				"start_date": filter.StartDate,
+				"end_date":   filter.EndDate,
+				"active_on":  filter.ActiveOn,
+			} {
+				if got == nil || got.Format(model.DateFormat) != tt.want {
+					t.Errorf("%s = %v, want %s", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestListRejectsInvalidFilterDate(t *testing.T) {
+	svc := newTestService(&mocks.SubscriptionRepository{}, service.Options{MonthPrecision: true})
+
+	_, err := svc.List(context.Background(), &model.ListSubscriptionsRequest{StartDate: strPtr("06-2024"), Limit: 10})
+
+	var validationErr *service.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "start_date" {
+		t.Fatalf("List error = %v, want validation error on start_date", err)
+	}
+}
+
+func TestListUsesConfiguredDefaultSort(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultSort string
+		sort        *string
+		want        string
+	}{
+		{"default", "-start_date", nil, "-start_date"},
+		{"configured default", "price", nil, "price"},
+		{"explicit sort wins", "-start_date", strPtr("-created_at"), "-created_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter model.SubscriptionFilter
+			repo := &mocks.SubscriptionRepository{
+				ListFn: func(_ context.Context, f model.SubscriptionFilter) ([]*model.Subscription, int, int, error) {
+					filter = f
+					return nil, 0, 0, nil
+				},
+			}
+			svc := newTestService(repo, service.Options{DefaultSort: tt.defaultSort})
+
+			if _, err := svc.List(context.Background(), &model.ListSubscriptionsRequest{Sort: tt.sort, Limit: 10}); err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if filter.Sort != tt.want {
+				t.Errorf("sort = %q, want %q", filter.Sort, tt.want)
+			}
+		})
+	}
+}
+
+func TestListExpiringMonthsBounds(t *testing.T) {
+	tests := []struct {
+		months  int
+		wantErr bool
+	}{
+		{-1, true},
+		{0, false},
+		{24, false},
+		{25, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.months), func(t *testing.T) {
+			repo := &mocks.SubscriptionRepository{
+				ListExpiringFn: func(_ context.Context, from, to time.Time, limit, offset int) ([]*model.Subscription, int, error) {
+					if from.Day() != 1 || to.AddDate(0, 0, 1).Day() != 1 {
+						t.Errorf("window %v - %v is not aligned to month boundaries", from, to)
+					}
+					if limit != 10 || offset != 20 {
+						t.Errorf("page = %d/%d, want 10/20", limit, offset)
+					}
+					return nil, 0, nil
+				},
+			}
+			svc := newTestService(repo, service.Options{})
+
+			_, err := svc.ListExpiring(context.Background(), tt.months, 10, 20)
+
+			var validationErr *service.ValidationError
+			if got := errors.As(err, &validationErr); got != tt.wantErr {
+				t.Fatalf("ListExpiring(%d) error = %v, wantErr %v", tt.months, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListAndAggregateShareServiceNameFilter(t *testing.T) {
+	boolPtr := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		name  string
+		names []string
+		exact *bool
+		want  *model.ServiceNameFilter
+	}{
+		{"substring by default", []string{"plus"}, nil, &model.ServiceNameFilter{Values: []string{"plus"}}},
+		{"exact", []string{"Yandex Plus"}, boolPtr(true), &model.ServiceNameFilter{Values: []string{"Yandex Plus"}, Exact: true}},
+		{"comma separated and repeated", []string{"netflix, apple", "", "ivi"}, boolPtr(false), &model.ServiceNameFilter{Values: []string{"netflix", "apple", "ivi"}}},
+		{"blank means no filter", []string{" , "}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var listFilter, aggregateFilter *model.ServiceNameFilter
+			repo := &mocks.SubscriptionRepository{
+				ListFn: func(_ context.Context, f model.SubscriptionFilter) ([]*model.Subscription, int, int, error) {
+					listFilter = f.ServiceName
+					return nil, 0, 0, nil
+				},
+				AggregateFn: func(_ context.Context, _, _ time.Time, _ *uuid.UUID, serviceName *model.ServiceNameFilter) (int, error) {
+					aggregateFilter = serviceName
+					return 0, nil
+				},
+			}
+			svc := newTestService(repo, service.Options{})
+
+			var exact *string
+			if tt.exact != nil {
+				exact = strPtr(strconv.FormatBool(*tt.exact))
+			}
+			if _, err := svc.List(context.Background(), &model.ListSubscriptionsRequest{ServiceNames: tt.names, Exact: exact, Limit: 10}); err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if _, err := svc.Aggregate(context.Background(), &model.AggregateRequest{
+				ServiceNames: tt.names,
+				Exact:        tt.exact,
+				StartDate:    "2024-01-01",
+				EndDate:      "2024-12-31",
+			}); err != nil {
+				t.Fatalf("Aggregate: %v", err)
+			}
+
+			if !reflect.DeepEqual(listFilter, tt.want) {
+				t.Errorf("list filter = %+v, want %+v", listFilter, tt.want)
+			}
+			if !reflect.DeepEqual(aggregateFilter, tt.want) {
+				t.Errorf("aggregate filter = %+v, want %+v", aggregateFilter, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+type SummaryService interface {
+	// Rebuild пересобирает помесячную сводку из подписок. Нужен после правок в
+	// обход триггера (восстановление из бэкапа, TRUNCATE) или смены DATE_PRECISION.
+	Rebuild() (*model.SummaryRebuildResult, error)
+}
+
+type summaryService struct {
+	repo repository.SummaryRepository
+	// cache сбрасывается после пересборки: Aggregate читает сводку.
+	cache *AggregateCache
+}
+
+func NewSummaryService(repo repository.SummaryRepository, cache *AggregateCache) SummaryService {
+	return &summaryService{repo: repo, cache: cache}
+}
+
+func (s *summaryService) Rebuild() (*model.SummaryRebuildResult, error) {
+	started := time.Now()
+
+	rows, err := s.repo.Rebuild()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rebuild summaries: %w", err)
+	}
+	s.cache.Invalidate()
+
+	result := &model.SummaryRebuildResult{
+		Rows:       rows,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"rows":        result.Rows,
+		"duration_ms": result.DurationMs,
+	}).Info("Subscription summaries rebuilt")
+
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"subscription_service/internal/model"
+)
+
+// WarningRules - пороги мягкой валидации при создании подписки. Нарушение не
+// является ошибкой данных, но требует явного подтверждения (allow_warnings).
+// Нулевое значение отключает правило.
+type WarningRules struct {
+	// PriceAbove - цена выше этого значения считается необычно высокой.
+	PriceAbove int
+	// StartOlderThanMonths - start_date раньше, чем столько месяцев назад.
+	StartOlderThanMonths int
+}
+
+// WarningsError означает, что подписка не создана из-за предупреждений,
+// которые клиент не разрешил.
+type WarningsError struct {
+	Warnings []string
+}
+
+func (e *WarningsError) Error() string {
+	return "subscription has validation warnings: " + strings.Join(e.Warnings, "; ")
+}
+
+// softWarnings проверяет подписку по WarningRules и возвращает предупреждения.
+func (s *subscriptionService) softWarnings(sub *model.Subscription) []string {
+	rules := s.opts.Warnings
+	var warnings []string
+
+	if rules.PriceAbove > 0 && int(sub.Price) > rules.PriceAbove {
+		warnings = append(warnings, fmt.Sprintf("price %d is unusually high (above %d)", sub.Price, rules.PriceAbove))
+	}
+
+	if rules.StartOlderThanMonths > 0 {
+		if sub.StartDate.Before(model.AddMonths(s.today(), -rules.StartOlderThanMonths)) {
+			warnings = append(warnings, fmt.Sprintf("start_date %s is more than %d months in the past",
+				sub.StartDate.Format(model.DateFormat), rules.StartOlderThanMonths))
+		}
+	}
+
+	return warnings
+}
+
+// checkWarnings блокирует создание при предупреждениях, если они не разрешены,
+// а разрешённые сохраняет в sub.Warnings для ответа.
+func (s *subscriptionService) checkWarnings(sub *model.Subscription, allow bool) error {
+	warnings := s.softWarnings(sub)
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	if !allow {
+		return &WarningsError{Warnings: warnings}
+	}
+
+	sub.Warnings = warnings
+	return nil
+}
@@ -0,0 +1,176 @@
+// Package store - общее для реплик состояние в Redis: окна ограничения частоты
+// запросов и кэш агрегаций. Без REDIS_URL обе возможности работают на
+// локальном состоянии процесса, а при ошибках Redis временно возвращаются к нему.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// Store - операции, которые нужны ограничителю частоты и кэшу агрегаций.
+// Ошибка означает, что общее состояние недоступно и вызывающий должен перейти
+// на локальное.
+type Store interface {
+	// Incr увеличивает счётчик key и возвращает новое значение и время до его
+	// истечения. Новый счётчик живёт ttl; ttl <= 0 - без истечения.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error)
+	// Get возвращает значение key; ok = false, если ключа нет.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set сохраняет value под key на время ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// ErrUnavailable возвращается без обращения к Redis в течение retryInterval
+// после ошибки соединения.
+var ErrUnavailable = errors.New("redis is unavailable")
+
+const (
+	// defaultTimeout - таймаут соединения и команд, если он не задан в URL
+	// (dial_timeout, read_timeout, write_timeout). Недоступный Redis не должен
+	// заметно задерживать запросы.
+	defaultTimeout = 200 * time.Millisecond
+	// retryInterval - сколько не обращаться к Redis после ошибки.
+	retryInterval = 5 * time.Second
+)
+
+// incrScript атомарно увеличивает счётчик и задаёт время жизни новому.
+var incrScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 and tonumber(ARGV[1]) > 0 then
+    redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {n, redis.call('PTTL', KEYS[1])}`)
+
+// Redis - Store на клиенте go-redis. После ошибки соединения он retryInterval
+// отвечает ErrUnavailable, не дожидаясь таймаутов, и пишет в лог только смену
+// состояния, а не каждую ошибку.
+type Redis struct {
+	client *redis.Client
+
+	mu        sync.Mutex
+	down      bool
+	downUntil time.Time
+}
+
+// NewRedis создаёт клиент по URL вида redis://[:password@]host:port/db.
+// Соединение не проверяется: Redis может подняться позже сервиса.
+func NewRedis(url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = defaultTimeout
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = defaultTimeout
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = defaultTimeout
+	}
+	// Ошибка сразу переводит вызывающего на локальное состояние; повтор
+	// только удвоил бы задержку.
+	opts.MaxRetries = -1
+
+	return &Redis{client: redis.NewClient(opts)}, nil
+}
+
+// Ping проверяет соединение; при ошибке Redis считается недоступным.
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.do(ctx, func() error {
+		return r.client.Ping(ctx).Err()
+	})
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+func (r *Redis) Incr(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	var count, remaining int64
+	err := r.do(ctx, func() error {
+		values, err := incrScript.Run(ctx, r.client, []string{key}, ttl.Milliseconds()).Int64Slice()
+		if err != nil {
+			return err
+		}
+		count, remaining = values[0], values[1]
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// PTTL отрицательный, если у ключа нет срока жизни.
+	if remaining < 0 {
+		remaining = 0
+	}
+	return count, time.Duration(remaining) * time.Millisecond, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var value []byte
+	found := true
+	err := r.do(ctx, func() error {
+		var err error
+		value, err = r.client.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			found = false
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value, found, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.do(ctx, func() error {
+		return r.client.Set(ctx, key, value, ttl).Err()
+	})
+}
+
+// do выполняет команду, если Redis не помечен недоступным, и обновляет
+// пометку по результату. Отмена контекста вызывающим не считается сбоем Redis.
+func (r *Redis) do(ctx context.Context, command func() error) error {
+	now := time.Now()
+
+	r.mu.Lock()
+	skip := r.down && now.Before(r.downUntil)
+	r.mu.Unlock()
+	if skip {
+		return ErrUnavailable
+	}
+
+	err := command()
+	if err != nil && ctx.Err() != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		if !r.down {
+			logrus.WithError(err).Warn("Redis is unavailable, falling back to local state")
+		}
+		r.down = true
+		r.downUntil = now.Add(retryInterval)
+		return err
+	}
+
+	if r.down {
+		logrus.Info("Redis is available again")
+		r.down = false
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	r, err := NewRedis("redis://" + server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r, server
+}
+
+func TestRedisIncrSetsTTLOnFirstIncrement(t *testing.T) {
+	r, server := newTestRedis(t)
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		count, ttl, err := r.Incr(ctx, "window", time.Minute)
+		if err != nil {
+			t.Fatalf("Incr: %v", err)
+		}
+		if count != want || ttl <= 0 || ttl > time.Minute {
+			t.Errorf("Incr = %d, %v; want %d with ttl up to 1m", count, ttl, want)
+		}
+	}
+
+	server.FastForward(time.Minute)
+	if count, _, err := r.Incr(ctx, "window", time.Minute); err != nil || count != 1 {
+		t.Errorf("Incr after expiry = %d, %v; want a new window", count, err)
+	}
+
+	// Без ttl счётчик не истекает.
+	if _, ttl, err := r.Incr(ctx, "generation", 0); err != nil || ttl != 0 {
+		t.Errorf("Incr without ttl = %v, %v; want no expiry", ttl, err)
+	}
+	if server.TTL("generation") != 0 {
+		t.Errorf("generation ttl = %v, want none", server.TTL("generation"))
+	}
+}
+
+func TestRedisGetSet(t *testing.T) {
+	r, _ := newTestRedis(t)
+	ctx := context.Background()
+
+	if _, ok, err := r.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %t, %v; want not found", ok, err)
+	}
+
+	if err := r.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if value, ok, err := r.Get(ctx, "key"); !ok || err != nil || string(value) != "value" {
+		t.Errorf("Get = %q, %t, %v; want value", value, ok, err)
+	}
+}
+
+func TestRedisBacksOffAfterFailure(t *testing.T) {
+	r, server := newTestRedis(t)
+	ctx := context.Background()
+
+	server.Close()
+	if _, _, err := r.Get(ctx, "key"); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("first Get = %v, want a connection error", err)
+	}
+	if _, _, err := r.Get(ctx, "key"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Get right after failure = %v, want ErrUnavailable without a round trip", err)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	r.mu.Lock()
+	r.downUntil = time.Now()
+	r.mu.Unlock()
+
+	if err := r.Set(ctx, "key", []byte("value"), time.Minute); err != nil {
+		t.Errorf("Set after retry interval = %v, want Redis to be used again", err)
+	}
+}
+
+func TestRedisIgnoresCallerCancellation(t *testing.T) {
+	r, _ := newTestRedis(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := r.Get(ctx, "key"); err == nil {
+		t.Fatal("Get with canceled context succeeded")
+	}
+
+	if _, _, err := r.Get(context.Background(), "key"); err != nil {
+		t.Errorf("Get after a canceled call = %v, want Redis still available", err)
+	}
+}
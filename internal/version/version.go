@@ -0,0 +1,10 @@
+// Package version содержит сведения о сборке, подставляемые через -ldflags:
+//
+//	go build -ldflags "-X subscription_service/internal/version.Version=v1.2.3 ..."
+package version
+
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildTime = "unknown"
+)
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"subscription_service/internal/events"
+	"subscription_service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OutboxRelay переносит события из таблицы outbox в publisher пачками по batchSize
+// и удаляет отправленные события старше retention (0 - не удалять).
+type OutboxRelay struct {
+	repo      repository.OutboxRepository
+	publisher events.Publisher
+	batchSize int
+	retention time.Duration
+}
+
+func NewOutboxRelay(repo repository.OutboxRepository, publisher events.Publisher, batchSize int, retention time.Duration) *OutboxRelay {
+	return &OutboxRelay{repo: repo, publisher: publisher, batchSize: batchSize, retention: retention}
+}
+
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	total := 0
+	for ctx.Err() == nil {
+		sent, err := r.repo.RelayBatch(r.batchSize, r.publisher.Publish)
+		total += sent
+		if err != nil {
+			return err
+		}
+		if sent < r.batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logrus.WithField("sent", total).Info("Outbox events relayed")
+	}
+
+	return r.purge(ctx)
+}
+
+func (r *OutboxRelay) purge(ctx context.Context) error {
+	if r.retention <= 0 {
+		return nil
+	}
+
+	before := time.Now().Add(-r.retention)
+
+	var total int64
+	for ctx.Err() == nil {
+		purged, err := r.repo.PurgeSentBatch(before, r.batchSize)
+		total += purged
+		if err != nil {
+			return err
+		}
+		if purged < int64(r.batchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		logrus.WithField("purged", total).Info("Sent outbox events purged")
+	}
+	return nil
+}
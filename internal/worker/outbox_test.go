@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscription_service/internal/events"
+	"subscription_service/internal/model"
+)
+
+type fakeOutboxRepo struct {
+	purged  []int64
+	befores []time.Time
+}
+
+func (r *fakeOutboxRepo) RelayBatch(int, func(model.OutboxEvent) error) (int, error) {
+	return 0, nil
+}
+
+func (r *fakeOutboxRepo) PurgeSentBatch(before time.Time, _ int) (int64, error) {
+	r.befores = append(r.befores, before)
+	if len(r.purged) == 0 {
+		return 0, nil
+	}
+	n := r.purged[0]
+	r.purged = r.purged[1:]
+	return n, nil
+}
+
+func TestOutboxRelayPurgesSentEvents(t *testing.T) {
+	repo := &fakeOutboxRepo{purged: []int64{10, 10, 3}}
+	relay := NewOutboxRelay(repo, events.LogPublisher{}, 10, 24*time.Hour)
+
+	start := time.Now()
+	if err := relay.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(repo.befores) != 3 {
+		t.Fatalf("PurgeSentBatch called %d times, want 3", len(repo.befores))
+	}
+	if want := start.Add(-24 * time.Hour); repo.befores[0].Before(want.Add(-time.Second)) || repo.befores[0].After(time.Now().Add(-24*time.Hour)) {
+		t.Errorf("before = %v, want about %v", repo.befores[0], want)
+	}
+}
+
+func TestOutboxRelayKeepsEventsWithoutRetention(t *testing.T) {
+	repo := &fakeOutboxRepo{}
+	relay := NewOutboxRelay(repo, events.LogPublisher{}, 10, 0)
+
+	if err := relay.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(repo.befores) != 0 {
+		t.Errorf("PurgeSentBatch called %d times with retention disabled", len(repo.befores))
+	}
+}
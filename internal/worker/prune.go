@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Pruner удаляет или архивирует подписки, закончившиеся более AfterMonths месяцев назад.
+type Pruner struct {
+	repo        repository.PruneRepository
+	afterMonths int
+	archive     bool
+	batchSize   int
+	cache       Invalidator
+}
+
+func NewPruner(repo repository.PruneRepository, afterMonths int, archive bool, batchSize int, cache Invalidator) *Pruner {
+	return &Pruner{
+		repo:        repo,
+		afterMonths: afterMonths,
+		archive:     archive,
+		batchSize:   batchSize,
+		cache:       cache,
+	}
+}
+
+func (p *Pruner) Run(ctx context.Context) error {
+	cutoff := model.MonthStart(time.Now()).AddDate(0, -p.afterMonths, 0)
+
+	var total int64
+	for ctx.Err() == nil {
+		var affected int64
+		var err error
+		if p.archive {
+			affected, err = p.repo.ArchiveExpiredBatch(cutoff, p.batchSize)
+		} else {
+			affected, err = p.repo.DeleteExpiredBatch(cutoff, p.batchSize)
+		}
+		if err != nil {
+			return err
+		}
+		if affected > 0 && p.cache != nil {
+			p.cache.Invalidate()
+		}
+
+		total += affected
+		if affected < int64(p.batchSize) {
+			break
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"cutoff":   cutoff.Format("2006-01-02"),
+		"archive":  p.archive,
+		"affected": total,
+	}).Info("Expired subscriptions pruned")
+
+	return nil
+}
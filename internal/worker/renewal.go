@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"subscription_service/internal/model"
+	"subscription_service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Renewer продлевает на месяц подписки с auto_renew, дата окончания которых прошла.
+type Renewer struct {
+	repo      repository.RenewalRepository
+	batchSize int
+	// location - часовой пояс, в котором определяется сегодняшняя дата.
+	location *time.Location
+	cache    Invalidator
+}
+
+func NewRenewer(repo repository.RenewalRepository, batchSize int, location *time.Location, cache Invalidator) *Renewer {
+	return &Renewer{repo: repo, batchSize: batchSize, location: location, cache: cache}
+}
+
+// Run обрабатывает продления пачками, пока они есть. Подписка, просроченная на
+// несколько месяцев, продлевается по месяцу за проход до сегодняшнего дня.
+func (r *Renewer) Run(ctx context.Context) error {
+	today := model.DateIn(time.Now(), r.location)
+
+	var total int
+	for ctx.Err() == nil {
+		renewals, err := r.repo.RenewDueBatch(today, r.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(renewals) > 0 && r.cache != nil {
+			r.cache.Invalidate()
+		}
+
+		for _, renewal := range renewals {
+			logrus.WithFields(logrus.Fields{
+				"id":                renewal.SubscriptionID,
+				"user_id":           renewal.UserID,
+				"previous_end_date": renewal.PreviousEndDate.Format("2006-01-02"),
+				"new_end_date":      renewal.NewEndDate.Format("2006-01-02"),
+			}).Info("Subscription auto-renewed")
+		}
+
+		total += len(renewals)
+		if len(renewals) < r.batchSize {
+			break
+		}
+	}
+
+	logrus.WithField("renewed", total).Info("Auto-renewal finished")
+	return nil
+}
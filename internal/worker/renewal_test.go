@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"subscription_service/internal/model"
+)
+
+type renewalRepoFunc func(today time.Time, batchSize int) ([]model.Renewal, error)
+
+func (f renewalRepoFunc) RenewDueBatch(today time.Time, batchSize int) ([]model.Renewal, error) {
+	return f(today, batchSize)
+}
+
+func TestRenewerUsesConfiguredLocation(t *testing.T) {
+	for _, loc := range []*time.Location{time.UTC, time.FixedZone("LINT", 14*60*60), time.FixedZone("BIT", -12*60*60)} {
+		t.Run(loc.String(), func(t *testing.T) {
+			var got time.Time
+			renewer := NewRenewer(renewalRepoFunc(func(today time.Time, _ int) ([]model.Renewal, error) {
+				got = today
+				return nil, nil
+			}), 10, loc, nil)
+
+			before := model.DateIn(time.Now(), loc)
+			if err := renewer.Run(context.Background()); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			after := model.DateIn(time.Now(), loc)
+
+			if !got.Equal(before) && !got.Equal(after) {
+				t.Errorf("today = %v, want %v in %s", got, before, loc)
+			}
+		})
+	}
+}
+
+func TestRenewerProcessesFullBatchesUntilDone(t *testing.T) {
+	batches := [][]model.Renewal{make([]model.Renewal, 2), make([]model.Renewal, 2), make([]model.Renewal, 1)}
+	calls := 0
+	renewer := NewRenewer(renewalRepoFunc(func(time.Time, int) ([]model.Renewal, error) {
+		calls++
+		if calls > len(batches) {
+			return nil, errors.New("called after a partial batch")
+		}
+		return batches[calls-1], nil
+	}), 2, time.UTC, nil)
+
+	if err := renewer.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if calls != len(batches) {
+		t.Errorf("RenewDueBatch called %d times, want %d", calls, len(batches))
+	}
+}
+
+type invalidatorFunc func()
+
+func (f invalidatorFunc) Invalidate() { f() }
+
+func TestRenewerInvalidatesCacheAfterRenewals(t *testing.T) {
+	batches := [][]model.Renewal{make([]model.Renewal, 1), nil}
+	invalidations := 0
+	renewer := NewRenewer(renewalRepoFunc(func(time.Time, int) ([]model.Renewal, error) {
+		batch := batches[0]
+		batches = batches[1:]
+		return batch, nil
+	}), 1, time.UTC, invalidatorFunc(func() { invalidations++ }))
+
+	if err := renewer.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if invalidations != 1 {
+		t.Errorf("cache invalidated %d times, want once for the batch with renewals", invalidations)
+	}
+}
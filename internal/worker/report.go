@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"subscription_service/internal/notifier"
+	"subscription_service/internal/service"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ExpiringReporter отправляет отчёт о подписках, заканчивающихся в текущем
+// месяце или в следующие months месяцев.
+type ExpiringReporter struct {
+	service  service.SubscriptionService
+	notifier notifier.Notifier
+	months   int
+}
+
+func NewExpiringReporter(service service.SubscriptionService, n notifier.Notifier, months int) *ExpiringReporter {
+	return &ExpiringReporter{service: service, notifier: n, months: months}
+}
+
+func (r *ExpiringReporter) Run(ctx context.Context) error {
+	expiring, err := r.service.ListExpiring(ctx, r.months, 0, 0)
+	if err != nil {
+		return err
+	}
+	subs, from, to := expiring.Items, expiring.From, expiring.To
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Subscriptions expiring between %s and %s: %d\n\n",
+		from.Format("2006-01-02"), to.Format("2006-01-02"), len(subs))
+	for _, sub := range subs {
+		fmt.Fprintf(&body, "%s\t%s\tuser=%s\tprice=%d %s\tends=%s\n",
+			sub.ID, sub.ServiceName, sub.UserID, sub.Price, sub.Currency, sub.EndDate.Format("2006-01-02"))
+	}
+
+	subject := fmt.Sprintf("Expiring subscriptions report: %d", len(subs))
+	if err := r.notifier.Send(subject, body.String()); err != nil {
+		logrus.WithError(err).WithField("count", len(subs)).Error("Failed to deliver expiring subscriptions report")
+		return err
+	}
+
+	logrus.WithField("count", len(subs)).Info("Expiring subscriptions report delivered")
+	return nil
+}
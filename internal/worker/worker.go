@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Invalidator сбрасывает кэш, который зависит от данных, меняемых задачей
+// (кэш агрегаций: *service.AggregateCache). nil - сбрасывать нечего.
+type Invalidator interface {
+	Invalidate()
+}
+
+// RunPeriodic запускает job сразу и далее каждые interval, пока не отменён ctx.
+// Ошибки job логируются и не прерывают расписание.
+func RunPeriodic(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	log := logrus.WithField("job", name)
+	log.WithField("interval", interval.String()).Info("Background job started")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil {
+			log.WithError(err).Error("Background job failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Background job stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunScheduled запускает job по cron-расписанию schedule в часовом поясе loc,
+// пока не отменён ctx. В отличие от RunPeriodic, при старте job не выполняется:
+// перезапуск или деплой не сдвигает и не дублирует запуски.
+func RunScheduled(ctx context.Context, name string, schedule cron.Schedule, loc *time.Location, job func(ctx context.Context) error) {
+	log := logrus.WithField("job", name)
+
+	for {
+		next := schedule.Next(time.Now().In(loc))
+		log.WithField("next_run", next.Format(time.RFC3339)).Info("Background job scheduled")
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Info("Background job stopped")
+			return
+		case <-timer.C:
+		}
+
+		if err := job(ctx); err != nil {
+			log.WithError(err).Error("Background job failed")
+		}
+	}
+}
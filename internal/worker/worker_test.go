@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// everyInterval - расписание с фиксированным шагом для тестов без ожидания минут.
+type everyInterval time.Duration
+
+func (e everyInterval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+func TestRunScheduledWaitsForFirstRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		RunScheduled(ctx, "test", everyInterval(50*time.Millisecond), time.UTC, func(context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if n := runs.Load(); n != 0 {
+		t.Fatalf("job ran %d times before the first scheduled time", n)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runs.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := runs.Load(); n < 2 {
+		t.Fatalf("job ran %d times, want at least 2", n)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunScheduled did not stop after cancel")
+	}
+}
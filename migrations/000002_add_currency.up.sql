@@ -0,0 +1,14 @@
+-- Подписки, созданные до появления валют, хранили цены в рублях, поэтому
+-- существующие строки заполняются 'RUB' независимо от BASE_CURRENCY. DEFAULT
+-- снимается в 000017: новые строки всегда получают валюту от сервиса.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency CHAR(3) NOT NULL,
+    
+    date DATE NOT NULL,
+    
+    rate_to_base NUMERIC(20, 8) NOT NULL CHECK (rate_to_base > 0),
+    
+    PRIMARY KEY (currency, date)
+);
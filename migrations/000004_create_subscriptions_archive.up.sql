@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS subscriptions_archive (
+    id UUID PRIMARY KEY,
+    
+    data JSONB NOT NULL,
+    
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_service_start ON subscriptions(user_id, service_name, start_date);
@@ -0,0 +1 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS description VARCHAR(500) NOT NULL DEFAULT '';
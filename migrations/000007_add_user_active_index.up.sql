@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_end_date ON subscriptions(user_id, end_date);
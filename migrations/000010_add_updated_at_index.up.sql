@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_subscriptions_updated_at_id ON subscriptions(updated_at, id);
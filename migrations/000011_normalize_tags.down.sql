@@ -0,0 +1,2 @@
+-- Исходный регистр и повторы тегов не сохраняются, откатывать нечего.
+SELECT 1;
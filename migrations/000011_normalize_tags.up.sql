@@ -0,0 +1,18 @@
+-- Приводит уже сохранённые теги к виду, который пишет сервис: без пробелов по
+-- краям, в нижнем регистре, без пустых и повторов (порядок первых вхождений сохраняется).
+UPDATE subscriptions s
+SET tags = normalized.tags
+FROM (
+    SELECT id, COALESCE(ARRAY(
+        SELECT tag
+        FROM (
+            SELECT lower(btrim(t.tag)) AS tag, MIN(t.pos) AS pos
+            FROM unnest(sub.tags) WITH ORDINALITY AS t(tag, pos)
+            WHERE btrim(t.tag) <> ''
+            GROUP BY lower(btrim(t.tag))
+        ) dedup
+        ORDER BY pos
+    ), '{}') AS tags
+    FROM subscriptions sub
+) normalized
+WHERE s.id = normalized.id AND s.tags IS DISTINCT FROM normalized.tags;
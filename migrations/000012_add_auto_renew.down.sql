@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS subscription_renewals;
+DROP INDEX IF EXISTS idx_subscriptions_auto_renew_end_date;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS auto_renew;
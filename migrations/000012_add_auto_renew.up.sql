@@ -0,0 +1,17 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS auto_renew BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_auto_renew_end_date ON subscriptions(end_date) WHERE auto_renew;
+
+-- Журнал продлений. Уникальность (subscription_id, previous_end_date) не даёт
+-- продлить подписку дважды с одной и той же даты окончания.
+CREATE TABLE IF NOT EXISTS subscription_renewals (
+    id BIGSERIAL PRIMARY KEY,
+
+    subscription_id UUID NOT NULL,
+    previous_end_date DATE NOT NULL,
+    new_end_date DATE NOT NULL,
+
+    renewed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    UNIQUE (subscription_id, previous_end_date)
+);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS subscription_summaries_sync ON subscriptions;
+DROP FUNCTION IF EXISTS subscription_summaries_sync();
+DROP FUNCTION IF EXISTS subscription_summaries_add(UUID, CHAR(3), DATE, DATE, BIGINT);
+DROP TABLE IF EXISTS subscription_summaries;
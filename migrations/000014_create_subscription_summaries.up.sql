@@ -0,0 +1,61 @@
+-- Помесячная сводка для агрегаций. delta - изменение месячной стоимости
+-- подписок пользователя в валюте currency начиная с month: +price в месяце
+-- start_date и -price в месяце end_date. Стоимость за месяц - нарастающая сумма
+-- delta, поэтому бессрочная подписка занимает одну строку.
+CREATE TABLE IF NOT EXISTS subscription_summaries (
+    user_id UUID NOT NULL,
+    month DATE NOT NULL,
+    currency CHAR(3) NOT NULL,
+
+    delta BIGINT NOT NULL,
+
+    PRIMARY KEY (user_id, month, currency)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_summaries_month ON subscription_summaries(month);
+
+-- Сводка обновляется триггером в той же транзакции, что и подписка, поэтому
+-- учитывает любые записи: API, фоновые задачи, ручные правки.
+CREATE OR REPLACE FUNCTION subscription_summaries_add(p_user_id UUID, p_currency CHAR(3), p_start DATE, p_end DATE, p_price BIGINT)
+RETURNS void AS $$
+BEGIN
+    INSERT INTO subscription_summaries (user_id, month, currency, delta)
+    VALUES (p_user_id, date_trunc('month', p_start)::date, p_currency, p_price)
+    ON CONFLICT (user_id, month, currency) DO UPDATE SET delta = subscription_summaries.delta + EXCLUDED.delta;
+
+    IF p_end IS NOT NULL THEN
+        INSERT INTO subscription_summaries (user_id, month, currency, delta)
+        VALUES (p_user_id, date_trunc('month', p_end)::date, p_currency, -p_price)
+        ON CONFLICT (user_id, month, currency) DO UPDATE SET delta = subscription_summaries.delta + EXCLUDED.delta;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION subscription_summaries_sync()
+RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM subscription_summaries_add(OLD.user_id, OLD.currency, OLD.start_date, OLD.end_date, -OLD.price);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM subscription_summaries_add(NEW.user_id, NEW.currency, NEW.start_date, NEW.end_date, NEW.price);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS subscription_summaries_sync ON subscriptions;
+CREATE TRIGGER subscription_summaries_sync
+    AFTER INSERT OR DELETE OR UPDATE OF user_id, currency, price, start_date, end_date ON subscriptions
+    FOR EACH ROW EXECUTE FUNCTION subscription_summaries_sync();
+
+DELETE FROM subscription_summaries;
+
+INSERT INTO subscription_summaries (user_id, month, currency, delta)
+SELECT user_id, month, currency, SUM(delta)
+FROM (
+    SELECT user_id, date_trunc('month', start_date)::date AS month, currency, price::bigint AS delta FROM subscriptions
+    UNION ALL
+    SELECT user_id, date_trunc('month', end_date)::date, currency, -price::bigint FROM subscriptions WHERE end_date IS NOT NULL
+) d
+GROUP BY user_id, month, currency;
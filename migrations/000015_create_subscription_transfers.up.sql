@@ -0,0 +1,13 @@
+-- Журнал передачи подписок между пользователями: пишется в той же транзакции,
+-- что и смена user_id, и хранит прежнего и нового владельца.
+CREATE TABLE IF NOT EXISTS subscription_transfers (
+    id BIGSERIAL PRIMARY KEY,
+
+    subscription_id UUID NOT NULL,
+    from_user_id UUID NOT NULL,
+    to_user_id UUID NOT NULL,
+
+    transferred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_transfers_subscription_id ON subscription_transfers(subscription_id);
@@ -0,0 +1,2 @@
+-- Для удаления отправленных событий старше OUTBOX_RETENTION.
+CREATE INDEX IF NOT EXISTS idx_outbox_sent_at ON outbox(sent_at) WHERE sent_at IS NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE subscriptions ALTER COLUMN currency SET DEFAULT 'RUB';
@@ -0,0 +1,4 @@
+-- Валюту новой подписки выбирает сервис (BASE_CURRENCY, если не указана),
+-- поэтому DEFAULT 'RUB' из 000002 не нужен: вставка без валюты в обход
+-- сервиса теперь ошибка, а не молча рублёвая цена.
+ALTER TABLE subscriptions ALTER COLUMN currency DROP DEFAULT;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uq_subscriptions_user_service;
@@ -0,0 +1,4 @@
+-- Необязательная миграция для UNIQUE_POLICY=user_service: не больше одной
+-- подписки пользователя на сервис. Применяется вручную (каталог optional не
+-- читается при старте), после чистки существующих дублей.
+CREATE UNIQUE INDEX IF NOT EXISTS uq_subscriptions_user_service ON subscriptions(user_id, service_name);
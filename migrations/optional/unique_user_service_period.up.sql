@@ -0,0 +1,12 @@
+-- Необязательная миграция для UNIQUE_POLICY=user_service_period: периоды
+-- подписок пользователя на один сервис не пересекаются. Применяется вручную
+-- (каталог optional не читается при старте), после чистки пересечений.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT excl_subscriptions_user_service_period
+    EXCLUDE USING gist (
+        user_id WITH =,
+        service_name WITH =,
+        daterange(start_date, end_date, '[]') WITH &&
+    );